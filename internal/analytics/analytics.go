@@ -0,0 +1,200 @@
+package analytics
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"time"
+)
+
+// formulas used to estimate the one rep max of a set
+const (
+	FormulaEpley   = "epley"
+	FormulaBrzycki = "brzycki"
+)
+
+var Formulas = []string{FormulaEpley, FormulaBrzycki}
+
+// periods the analytics are grouped by, weeks start on monday
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+var Buckets = []string{BucketDay, BucketWeek, BucketMonth}
+
+// one rep maxes are only estimated from sets of up to this many reps, both formulas are unreliable above it
+const MaxRepsForOneRepMax = 12
+
+var (
+	ErrUnknownFormula = errors.New("unknown one rep max formula")
+	ErrUnknownBucket  = errors.New("unknown bucket")
+)
+
+// a weighted entry of a workout, sets are all performed with the same reps and weight
+type Set struct {
+	WorkoutID    int
+	PerformedAt  time.Time
+	ExerciseID   int64
+	ExerciseName string
+	MuscleGroups []string
+	Sets         int
+	Reps         int
+	Weight       float64
+}
+
+// estimates the weight that could be lifted for a single rep, rounded to two decimals
+// zero is returned for sets that can not be estimated
+func OneRepMax(formula string, weight float64, reps int) (float64, error) {
+	if !slices.Contains(Formulas, formula) {
+		return 0, ErrUnknownFormula
+	}
+
+	if weight <= 0 || reps < 1 || reps > MaxRepsForOneRepMax {
+		return 0, nil
+	}
+
+	//a single is the one rep max itself, epley would still add a thirtieth of the weight
+	if reps == 1 {
+		return round(weight), nil
+	}
+
+	if formula == FormulaBrzycki {
+		return round(weight * 36 / float64(37-reps)), nil
+	}
+
+	return round(weight * (1 + float64(reps)/30)), nil
+}
+
+// start of the bucket holding the time, computed in the location of the time
+func BucketStart(bucket string, t time.Time) (time.Time, error) {
+	year, month, day := t.Date()
+
+	switch bucket {
+	case BucketDay:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location()), nil
+	case BucketWeek:
+		//weekday counts from sunday, we count from monday
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location()), nil
+	case BucketMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location()), nil
+	default:
+		return time.Time{}, ErrUnknownBucket
+	}
+}
+
+// volume and tonnage lifted during a bucket, the volume of an exercise is credited fully to each of its muscle groups
+type VolumeBucket struct {
+	Start        time.Time          `json:"start"`
+	Volume       float64            `json:"volume"`
+	Sets         int                `json:"sets"`
+	Reps         int                `json:"reps"`
+	Exercises    map[string]float64 `json:"exercises"`
+	MuscleGroups map[string]float64 `json:"muscle_groups"`
+}
+
+// groups the volume (sets x reps x weight) of the sets by bucket, buckets without sets are left out
+func Volume(sets []Set, bucket string) ([]VolumeBucket, error) {
+	buckets := []VolumeBucket{}
+	index := map[time.Time]int{}
+
+	for _, set := range sets {
+		if set.Weight <= 0 || set.Reps < 1 || set.Sets < 1 {
+			continue
+		}
+
+		start, err := BucketStart(bucket, set.PerformedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := index[start]
+		if !ok {
+			i = len(buckets)
+			index[start] = i
+			buckets = append(buckets, VolumeBucket{Start: start, Exercises: map[string]float64{}, MuscleGroups: map[string]float64{}})
+		}
+
+		volume := float64(set.Sets*set.Reps) * set.Weight
+
+		b := &buckets[i]
+		b.Volume += volume
+		b.Sets += set.Sets
+		b.Reps += set.Sets * set.Reps
+		b.Exercises[set.ExerciseName] += volume
+
+		for _, group := range set.MuscleGroups {
+			b.MuscleGroups[group] += volume
+		}
+	}
+
+	slices.SortFunc(buckets, func(a, b VolumeBucket) int { return a.Start.Compare(b.Start) })
+
+	for i := range buckets {
+		buckets[i].Volume = round(buckets[i].Volume)
+		roundValues(buckets[i].Exercises)
+		roundValues(buckets[i].MuscleGroups)
+	}
+
+	return buckets, nil
+}
+
+// best estimated one rep max of a bucket together with the set it was estimated from
+type OneRepMaxBucket struct {
+	Start     time.Time `json:"start"`
+	Estimate  float64   `json:"estimate"`
+	Weight    float64   `json:"weight"`
+	Reps      int       `json:"reps"`
+	WorkoutID int       `json:"workout_id"`
+}
+
+// best estimate of every bucket, buckets without a set that can be estimated are left out
+func OneRepMaxes(sets []Set, formula, bucket string) ([]OneRepMaxBucket, error) {
+	buckets := []OneRepMaxBucket{}
+	index := map[time.Time]int{}
+
+	for _, set := range sets {
+		estimate, err := OneRepMax(formula, set.Weight, set.Reps)
+		if err != nil {
+			return nil, err
+		}
+
+		if estimate == 0 {
+			continue
+		}
+
+		start, err := BucketStart(bucket, set.PerformedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		candidate := OneRepMaxBucket{Start: start, Estimate: estimate, Weight: set.Weight, Reps: set.Reps, WorkoutID: set.WorkoutID}
+
+		i, ok := index[start]
+		if !ok {
+			index[start] = len(buckets)
+			buckets = append(buckets, candidate)
+			continue
+		}
+
+		if estimate > buckets[i].Estimate {
+			buckets[i] = candidate
+		}
+	}
+
+	slices.SortFunc(buckets, func(a, b OneRepMaxBucket) int { return a.Start.Compare(b.Start) })
+
+	return buckets, nil
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func roundValues(values map[string]float64) {
+	for key, value := range values {
+		values[key] = round(value)
+	}
+}
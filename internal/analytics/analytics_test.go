@@ -0,0 +1,118 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOneRepMax(t *testing.T) {
+	tests := []struct {
+		name    string
+		formula string
+		weight  float64
+		reps    int
+		want    float64
+		wantErr error
+	}{
+		{name: "epley", formula: FormulaEpley, weight: 100, reps: 5, want: 116.67},
+		{name: "brzycki", formula: FormulaBrzycki, weight: 100, reps: 5, want: 112.5},
+		{name: "epley single", formula: FormulaEpley, weight: 140, reps: 1, want: 140},
+		{name: "brzycki single", formula: FormulaBrzycki, weight: 140, reps: 1, want: 140},
+		{name: "too many reps", formula: FormulaEpley, weight: 50, reps: 13, want: 0},
+		{name: "no weight", formula: FormulaEpley, weight: 0, reps: 5, want: 0},
+		{name: "unknown formula", formula: "lombardi", weight: 100, reps: 5, wantErr: ErrUnknownFormula},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OneRepMax(tt.formula, tt.weight, tt.reps)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	//a wednesday
+	at := time.Date(2025, 3, 12, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		bucket string
+		want   time.Time
+	}{
+		{bucket: BucketDay, want: time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC)},
+		{bucket: BucketWeek, want: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{bucket: BucketMonth, want: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bucket, func(t *testing.T) {
+			got, err := BucketStart(tt.bucket, at)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("sunday belongs to the previous week", func(t *testing.T) {
+		got, err := BucketStart(BucketWeek, time.Date(2025, 3, 16, 8, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), got)
+	})
+
+	t.Run("unknown bucket", func(t *testing.T) {
+		_, err := BucketStart("year", at)
+		assert.ErrorIs(t, err, ErrUnknownBucket)
+	})
+}
+
+func TestVolume(t *testing.T) {
+	monday := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+
+	sets := []Set{
+		{PerformedAt: monday, ExerciseName: "Bench Press", MuscleGroups: []string{"chest", "triceps"}, Sets: 3, Reps: 5, Weight: 100},
+		{PerformedAt: monday.Add(48 * time.Hour), ExerciseName: "Squat", MuscleGroups: []string{"quads"}, Sets: 5, Reps: 5, Weight: 120},
+		{PerformedAt: monday.Add(7 * 24 * time.Hour), ExerciseName: "Bench Press", MuscleGroups: []string{"chest", "triceps"}, Sets: 3, Reps: 5, Weight: 102.5},
+		{PerformedAt: monday, ExerciseName: "Push Up", Sets: 3, Reps: 20},
+	}
+
+	buckets, err := Volume(sets, BucketWeek)
+	require.NoError(t, err)
+	require.Len(t, buckets, 2)
+
+	assert.Equal(t, monday.Truncate(24*time.Hour), buckets[0].Start)
+	assert.Equal(t, 4500.0, buckets[0].Volume)
+	assert.Equal(t, 8, buckets[0].Sets)
+	assert.Equal(t, 40, buckets[0].Reps)
+	assert.Equal(t, map[string]float64{"Bench Press": 1500, "Squat": 3000}, buckets[0].Exercises)
+	assert.Equal(t, map[string]float64{"chest": 1500, "triceps": 1500, "quads": 3000}, buckets[0].MuscleGroups)
+
+	assert.Equal(t, 1537.5, buckets[1].Volume)
+}
+
+func TestOneRepMaxes(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2025, 3, n, 10, 0, 0, 0, time.UTC) }
+
+	sets := []Set{
+		{WorkoutID: 1, PerformedAt: day(3), Sets: 3, Reps: 5, Weight: 100},
+		{WorkoutID: 1, PerformedAt: day(3), Sets: 1, Reps: 1, Weight: 120},
+		{WorkoutID: 2, PerformedAt: day(11), Sets: 3, Reps: 15, Weight: 80},
+		{WorkoutID: 3, PerformedAt: day(12), Sets: 3, Reps: 3, Weight: 110},
+	}
+
+	buckets, err := OneRepMaxes(sets, FormulaEpley, BucketWeek)
+	require.NoError(t, err)
+
+	assert.Equal(t, []OneRepMaxBucket{
+		{Start: day(3).Truncate(24 * time.Hour), Estimate: 120, Weight: 120, Reps: 1, WorkoutID: 1},
+		{Start: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), Estimate: 121, Weight: 110, Reps: 3, WorkoutID: 3},
+	}, buckets)
+}
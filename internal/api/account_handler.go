@@ -0,0 +1,240 @@
+package api
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+type AccountHandler struct {
+	userStore    store.UserStore
+	workoutStore store.WorkoutStore
+	tokenStore   store.TokenStore
+	// how long a deleted account is kept before the account reaper removes it, zero deletes it right away
+	deletionGracePeriod time.Duration
+	logger              *slog.Logger
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// account handler constructor
+func NewAccountHandler(userStore store.UserStore, workoutStore store.WorkoutStore, tokenStore store.TokenStore, deletionGracePeriod time.Duration, logger *slog.Logger) *AccountHandler {
+	return &AccountHandler{
+		userStore:           userStore,
+		workoutStore:        workoutStore,
+		tokenStore:          tokenStore,
+		deletionGracePeriod: deletionGracePeriod,
+		logger:              logger,
+	}
+}
+
+// profile of the user as it is written to the data export, unlike User it includes nothing that is hidden from the json of a user
+type exportedProfile struct {
+	User       *store.User `json:"user"`
+	ExportedAt time.Time   `json:"exported_at"`
+}
+
+// streams a zip archive with the profile, the workouts with their entries and the metadata of the tokens of the user
+func (ah *AccountHandler) HandleDataExport(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	//the small files are read before the response starts so a failure can still be answered with an error status
+	tokens, err := ah.tokenStore.ListTokenMetadata(r.Context(), currentUser.ID)
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "listTokenMetadata", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	now := time.Now().UTC()
+
+	w.Header().Set("Content-Type", "application/zip")
+	//the username is quoted and escaped by FormatMediaType so it cannot break out of the header value
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": currentUser.Username + "-data-export.zip"}))
+
+	archive := zip.NewWriter(w)
+
+	err = writeZipJSON(archive, "profile.json", now, exportedProfile{User: currentUser, ExportedAt: now})
+	if err == nil {
+		err = writeZipJSON(archive, "tokens.json", now, utils.Envelope{"tokens": tokens})
+	}
+
+	if err == nil {
+		err = ah.writeZipWorkouts(r.Context(), http.NewResponseController(w), archive, now, currentUser.ID)
+	}
+
+	if err == nil {
+		err = archive.Close()
+	}
+
+	//the status is already sent, the client gets a broken archive
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "writingDataExport", "error", err)
+		return
+	}
+
+	ah.logger.InfoContext(r.Context(), "personal data exported")
+}
+
+// the workouts are streamed into the archive in the layout of GET /export?format=json, the archive is flushed and the
+// write deadline extended every exportFlushEvery workouts like GET /export does
+func (ah *AccountHandler) writeZipWorkouts(ctx context.Context, controller *http.ResponseController, archive *zip.Writer, modified time.Time, userID int) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: "workouts.json", Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+
+	encoder := newExportEncoder("json", file)
+
+	err = encoder.begin()
+	if err != nil {
+		return err
+	}
+
+	written := 0
+
+	err = ah.workoutStore.ExportWorkouts(ctx, store.ExportFilter{UserID: userID}, func(workout *store.Workout) error {
+		err := encoder.encode(workout)
+		if err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushEvery != 0 {
+			return nil
+		}
+
+		err = archive.Flush()
+		if err != nil {
+			return err
+		}
+
+		return flushExport(controller, encoder)
+	})
+	if err != nil {
+		return err
+	}
+
+	return encoder.end()
+}
+
+func writeZipJSON(archive *zip.Writer, name string, modified time.Time, data interface{}) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", " ")
+
+	return encoder.Encode(data)
+}
+
+// deletes the account of the user once the password is confirmed, with a grace period the deletion is only scheduled
+// and can be cancelled with DELETE /user/deletion until the account reaper removes the account
+func (ah *AccountHandler) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	var req deleteAccountRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Password == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "the password is required to delete the account"})
+		return
+	}
+
+	matches, err := currentUser.PasswordHash.Matches(req.Password)
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "PasswordHash.Matches", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if !matches {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid credentials"})
+		return
+	}
+
+	if ah.deletionGracePeriod <= 0 {
+		err = ah.userStore.DeleteUser(r.Context(), currentUser.ID)
+		if err != nil {
+			ah.logger.ErrorContext(r.Context(), "deleteUser", "error", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+
+		ah.logger.InfoContext(r.Context(), "account deleted")
+		utils.WriteJson(w, http.StatusOK, utils.Envelope{"user": "deleted"})
+		return
+	}
+
+	//asking again does not push back a deletion that is already scheduled
+	if currentUser.DeletionScheduledAt != nil {
+		utils.WriteJson(w, http.StatusAccepted, utils.Envelope{"deletion_scheduled_at": currentUser.DeletionScheduledAt})
+		return
+	}
+
+	deleteAt := time.Now().Add(ah.deletionGracePeriod).UTC().Truncate(time.Second)
+
+	err = ah.userStore.ScheduleUserDeletion(r.Context(), currentUser.ID, &deleteAt)
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "scheduleUserDeletion", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	ah.logger.InfoContext(r.Context(), "account deletion scheduled", "deletion_scheduled_at", deleteAt)
+	utils.WriteJson(w, http.StatusAccepted, utils.Envelope{"deletion_scheduled_at": deleteAt})
+}
+
+// cancels a deletion of the account that is waiting for its grace period
+func (ah *AccountHandler) HandleCancelDeletion(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	if currentUser.DeletionScheduledAt == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "no deletion of the account is scheduled"})
+		return
+	}
+
+	err := ah.userStore.ScheduleUserDeletion(r.Context(), currentUser.ID, nil)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user does not exist"})
+		return
+	}
+
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "cancelUserDeletion", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	ah.logger.InfoContext(r.Context(), "account deletion cancelled")
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"deletion": "cancelled"})
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tokens"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// user store recording the deletions asked by the handler
+type accountUserStore struct {
+	store.UserStore
+	deleted     int
+	scheduledAt *time.Time
+	scheduled   bool
+}
+
+func (s *accountUserStore) DeleteUser(ctx context.Context, userID int) error {
+	s.deleted = userID
+	return nil
+}
+
+func (s *accountUserStore) ScheduleUserDeletion(ctx context.Context, userID int, at *time.Time) error {
+	s.scheduled = true
+	s.scheduledAt = at
+	return nil
+}
+
+type accountTokenStore struct {
+	store.TokenStore
+	tokens []*store.TokenMetadata
+}
+
+func (s *accountTokenStore) ListTokenMetadata(ctx context.Context, userID int) ([]*store.TokenMetadata, error) {
+	return s.tokens, nil
+}
+
+func accountUser(t *testing.T) *store.User {
+	user := &store.User{ID: 7, Username: "sabin", Email: "sabin@example.com"}
+	require.NoError(t, user.PasswordHash.SetWithCost("hunter22", bcrypt.MinCost))
+	return user
+}
+
+func TestHandleDataExport(t *testing.T) {
+	tokenStore := &accountTokenStore{tokens: []*store.TokenMetadata{{Scope: tokens.ScopeAuth, UserAgent: "curl"}}}
+	workoutStore := &exportWorkoutStore{workouts: exportedWorkouts()}
+	handler := NewAccountHandler(&accountUserStore{}, workoutStore, tokenStore, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	req := httptest.NewRequest(http.MethodGet, "/user/data-export", nil)
+	req = middleware.SetUser(req, accountUser(t))
+	rr := httptest.NewRecorder()
+
+	handler.HandleDataExport(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+	assert.Equal(t, 7, workoutStore.filter.UserID)
+	assert.Equal(t, "attachment; filename=sabin-data-export.zip", rr.Header().Get("Content-Disposition"))
+
+	archive, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	require.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		require.NoError(t, err)
+		files[file.Name], err = io.ReadAll(reader)
+		require.NoError(t, err)
+		reader.Close()
+	}
+
+	require.Len(t, files, 3)
+
+	var profile struct {
+		User map[string]interface{} `json:"user"`
+	}
+	require.NoError(t, json.Unmarshal(files["profile.json"], &profile))
+	assert.Equal(t, "sabin", profile.User["username"])
+	assert.NotContains(t, string(files["profile.json"]), "hunter22")
+
+	var sessions struct {
+		Tokens []store.TokenMetadata `json:"tokens"`
+	}
+	require.NoError(t, json.Unmarshal(files["tokens.json"], &sessions))
+	require.Len(t, sessions.Tokens, 1)
+	assert.Equal(t, "curl", sessions.Tokens[0].UserAgent)
+
+	var workouts struct {
+		Workouts []store.Workout `json:"workouts"`
+	}
+	require.NoError(t, json.Unmarshal(files["workouts.json"], &workouts))
+	assert.Len(t, workouts.Workouts, 2)
+}
+
+func TestHandleDataExportEscapesFilename(t *testing.T) {
+	handler := NewAccountHandler(&accountUserStore{}, &exportWorkoutStore{}, &accountTokenStore{}, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	user := accountUser(t)
+	user.Username = `sa"bin\`
+	req := httptest.NewRequest(http.MethodGet, "/user/data-export", nil)
+	req = middleware.SetUser(req, user)
+	rr := httptest.NewRecorder()
+
+	handler.HandleDataExport(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	disposition, params, err := mime.ParseMediaType(rr.Header().Get("Content-Disposition"))
+	require.NoError(t, err)
+	assert.Equal(t, "attachment", disposition)
+	assert.Equal(t, `sa"bin\-data-export.zip`, params["filename"])
+}
+
+func TestHandleDeleteAccount(t *testing.T) {
+	scheduledAt := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		body          string
+		gracePeriod   time.Duration
+		scheduledAt   *time.Time
+		wantStatus    int
+		wantDeleted   bool
+		wantScheduled bool
+	}{
+		{name: "missing password", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "wrong password", body: `{"password":"wrong"}`, wantStatus: http.StatusUnauthorized},
+		{name: "deleted right away without grace period", body: `{"password":"hunter22"}`, wantStatus: http.StatusOK, wantDeleted: true},
+		{name: "scheduled with grace period", body: `{"password":"hunter22"}`, gracePeriod: 24 * time.Hour, wantStatus: http.StatusAccepted, wantScheduled: true},
+		{name: "existing schedule is kept", body: `{"password":"hunter22"}`, gracePeriod: 24 * time.Hour, scheduledAt: &scheduledAt, wantStatus: http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userStore := &accountUserStore{}
+			handler := NewAccountHandler(userStore, nil, nil, tt.gracePeriod, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			user := accountUser(t)
+			user.DeletionScheduledAt = tt.scheduledAt
+
+			req := httptest.NewRequest(http.MethodDelete, "/user", strings.NewReader(tt.body))
+			req = middleware.SetUser(req, user)
+			rr := httptest.NewRecorder()
+
+			handler.HandleDeleteAccount(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.Equal(t, tt.wantDeleted, userStore.deleted == 7)
+			assert.Equal(t, tt.wantScheduled, userStore.scheduled)
+
+			if tt.wantScheduled {
+				require.NotNil(t, userStore.scheduledAt)
+				assert.WithinDuration(t, time.Now().Add(tt.gracePeriod), *userStore.scheduledAt, time.Minute)
+			}
+
+			if tt.scheduledAt != nil {
+				assert.Contains(t, rr.Body.String(), "2025-05-01T00:00:00Z")
+			}
+		})
+	}
+}
+
+func TestHandleCancelDeletion(t *testing.T) {
+	scheduledAt := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		scheduledAt *time.Time
+		wantStatus  int
+	}{
+		{name: "nothing scheduled", wantStatus: http.StatusNotFound},
+		{name: "scheduled deletion is cancelled", scheduledAt: &scheduledAt, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userStore := &accountUserStore{}
+			handler := NewAccountHandler(userStore, nil, nil, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			user := accountUser(t)
+			user.DeletionScheduledAt = tt.scheduledAt
+
+			req := httptest.NewRequest(http.MethodDelete, "/user/deletion", nil)
+			req = middleware.SetUser(req, user)
+			rr := httptest.NewRecorder()
+
+			handler.HandleCancelDeletion(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.Equal(t, tt.scheduledAt != nil, userStore.scheduled)
+			assert.Nil(t, userStore.scheduledAt)
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/analytics"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+const (
+	defaultAnalyticsRange = 12 * 7 * 24 * time.Hour
+	maxAnalyticsRange     = 3 * 366 * 24 * time.Hour
+)
+
+type AnalyticsHandler struct {
+	analyticsStore store.AnalyticsStore
+	exerciseStore  store.ExerciseStore
+	logger         *slog.Logger
+}
+
+func NewAnalyticsHandler(analyticsStore store.AnalyticsStore, exerciseStore store.ExerciseStore, logger *slog.Logger) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsStore: analyticsStore,
+		exerciseStore:  exerciseStore,
+		logger:         logger,
+	}
+}
+
+// parameters shared by the analytics endpoints
+type analyticsQuery struct {
+	UserID   int
+	From     time.Time
+	To       time.Time
+	Bucket   string
+	Exercise *store.Exercise
+}
+
+// reads the time range, the bucket and the exercise given by id or by name, the response is written on failure
+func (ah *AnalyticsHandler) readAnalyticsQuery(w http.ResponseWriter, r *http.Request, exerciseRequired bool) (*analyticsQuery, bool) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return nil, false
+	}
+
+	from, err := utils.ReadTimeQuery(r, "from")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+
+	to, err := utils.ReadTimeQuery(r, "to")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+
+	query := &analyticsQuery{UserID: currentUser.ID, To: time.Now().UTC(), Bucket: analytics.BucketWeek}
+	if to != nil {
+		query.To = to.UTC()
+	}
+
+	query.From = query.To.Add(-defaultAnalyticsRange)
+	if from != nil {
+		query.From = from.UTC()
+	}
+
+	if !query.From.Before(query.To) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "from must be before to"})
+		return nil, false
+	}
+
+	if query.To.Sub(query.From) > maxAnalyticsRange {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "the time range cannot be longer than 3 years"})
+		return nil, false
+	}
+
+	if bucket := r.URL.Query().Get("bucket"); bucket != "" {
+		if !slices.Contains(analytics.Buckets, bucket) {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("bucket must be one of %s", strings.Join(analytics.Buckets, ", "))})
+			return nil, false
+		}
+		query.Bucket = bucket
+	}
+
+	exercise := strings.TrimSpace(r.URL.Query().Get("exercise"))
+	if exercise == "" {
+		if exerciseRequired {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "exercise is required"})
+			return nil, false
+		}
+		return query, true
+	}
+
+	if id, err := strconv.ParseInt(exercise, 10, 64); err == nil {
+		query.Exercise, err = ah.exerciseStore.GetExerciseByID(r.Context(), id, query.UserID)
+	} else {
+		query.Exercise, err = ah.exerciseStore.GetExerciseByName(r.Context(), exercise, query.UserID)
+	}
+
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "getExercise", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, false
+	}
+
+	if query.Exercise == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "exercise does not exist"})
+		return nil, false
+	}
+
+	return query, true
+}
+
+// loads the sets of the current user matching the query, buckets are computed in utc
+func (ah *AnalyticsHandler) listSets(w http.ResponseWriter, r *http.Request, query *analyticsQuery) ([]analytics.Set, bool) {
+	filter := store.SetFilter{UserID: query.UserID, From: query.From, To: query.To}
+	if query.Exercise != nil {
+		filter.ExerciseID = query.Exercise.ID
+	}
+
+	sets, err := ah.analyticsStore.ListSets(r.Context(), filter)
+
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "listingSets", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, false
+	}
+
+	for i := range sets {
+		sets[i].PerformedAt = sets[i].PerformedAt.UTC()
+	}
+
+	return sets, true
+}
+
+// volume and tonnage per muscle group of every bucket, optionally of a single exercise
+func (ah *AnalyticsHandler) HandleVolume(w http.ResponseWriter, r *http.Request) {
+	query, ok := ah.readAnalyticsQuery(w, r, false)
+	if !ok {
+		return
+	}
+
+	sets, ok := ah.listSets(w, r, query)
+	if !ok {
+		return
+	}
+
+	buckets, err := analytics.Volume(sets, query.Bucket)
+
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "computingVolume", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	total := 0.0
+	for _, bucket := range buckets {
+		total += bucket.Volume
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"from":     query.From,
+		"to":       query.To,
+		"bucket":   query.Bucket,
+		"exercise": query.Exercise,
+		"total":    total,
+		"volume":   buckets,
+	})
+}
+
+// best estimated one rep max of an exercise in every bucket
+func (ah *AnalyticsHandler) HandleOneRepMax(w http.ResponseWriter, r *http.Request) {
+	query, ok := ah.readAnalyticsQuery(w, r, true)
+	if !ok {
+		return
+	}
+
+	formula := r.URL.Query().Get("formula")
+	if formula == "" {
+		formula = analytics.FormulaEpley
+	}
+
+	if !slices.Contains(analytics.Formulas, formula) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("formula must be one of %s", strings.Join(analytics.Formulas, ", "))})
+		return
+	}
+
+	sets, ok := ah.listSets(w, r, query)
+	if !ok {
+		return
+	}
+
+	buckets, err := analytics.OneRepMaxes(sets, formula, query.Bucket)
+
+	if err != nil {
+		ah.logger.ErrorContext(r.Context(), "computingOneRepMax", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	var best *analytics.OneRepMaxBucket
+	for i := range buckets {
+		if best == nil || buckets[i].Estimate > best.Estimate {
+			best = &buckets[i]
+		}
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"from":        query.From,
+		"to":          query.To,
+		"bucket":      query.Bucket,
+		"formula":     formula,
+		"exercise":    query.Exercise,
+		"best":        best,
+		"one_rep_max": buckets,
+	})
+}
@@ -0,0 +1,484 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/zidariu-sabin/femProject/internal/calendar"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tokens"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+const (
+	defaultCalendarRange = 28 * 24 * time.Hour
+	maxCalendarRange     = 366 * 24 * time.Hour
+	// planned workouts older than this are left out of the feed
+	feedHistory = 90 * 24 * time.Hour
+)
+
+// struct used for null field checking when creating and updating plans
+type planRequest struct {
+	TemplateID      *int       `json:"template_id"`
+	Title           *string    `json:"title"`
+	Description     *string    `json:"description"`
+	StartsAt        *time.Time `json:"starts_at"`
+	DurationMinutes *int       `json:"duration_minutes"`
+	RRule           *string    `json:"rrule"`
+}
+
+// a workout or an occurrence of a plan shown on the calendar
+type calendarItem struct {
+	Kind            string    `json:"kind"`
+	ID              int       `json:"id"`
+	Title           string    `json:"title"`
+	Status          string    `json:"status"`
+	Start           time.Time `json:"start"`
+	DurationMinutes int       `json:"duration_minutes"`
+	TemplateID      *int      `json:"template_id,omitempty"`
+}
+
+type CalendarHandler struct {
+	calendarStore store.CalendarStore
+	tokenStore    store.TokenStore
+	userStore     store.UserStore
+	logger        *slog.Logger
+}
+
+func NewCalendarHandler(calendarStore store.CalendarStore, tokenStore store.TokenStore, userStore store.UserStore, logger *slog.Logger) *CalendarHandler {
+	return &CalendarHandler{
+		calendarStore: calendarStore,
+		tokenStore:    tokenStore,
+		userStore:     userStore,
+		logger:        logger,
+	}
+}
+
+// applies the set fields of the request to the plan and validates the result, the rule is stored in its canonical form
+func applyPlanRequest(req *planRequest, plan *store.WorkoutPlan) error {
+	if req.TemplateID != nil {
+		plan.TemplateID = req.TemplateID
+		//a zero template id detaches the template
+		if *req.TemplateID == 0 {
+			plan.TemplateID = nil
+		}
+	}
+	if req.Title != nil {
+		plan.Title = *req.Title
+	}
+	if req.Description != nil {
+		plan.Description = *req.Description
+	}
+	if req.StartsAt != nil {
+		plan.StartsAt = *req.StartsAt
+	}
+	if req.DurationMinutes != nil {
+		plan.DurationMinutes = *req.DurationMinutes
+	}
+	if req.RRule != nil {
+		plan.RRule = *req.RRule
+	}
+
+	if plan.Title == "" {
+		return errors.New("title is required")
+	}
+
+	if plan.StartsAt.IsZero() {
+		return errors.New("starts_at is required")
+	}
+
+	if plan.DurationMinutes < 1 {
+		return errors.New("duration_minutes must be positive")
+	}
+
+	rule, err := calendar.ParseRule(plan.RRule)
+	if err != nil {
+		return err
+	}
+
+	plan.RRule = rule.String()
+
+	return nil
+}
+
+// workouts and plan occurrences in [from, to) in chronological order
+func calendarItems(workouts []*store.Workout, plans []*store.WorkoutPlan, from, to time.Time) []calendarItem {
+	items := []calendarItem{}
+
+	for _, workout := range workouts {
+		items = append(items, calendarItem{
+			Kind:            "workout",
+			ID:              workout.ID,
+			Title:           workout.Title,
+			Status:          workout.Status,
+			Start:           workout.PerformedAt,
+			DurationMinutes: workout.DurationMinutes,
+		})
+	}
+
+	for _, plan := range plans {
+		rule, err := calendar.ParseRule(plan.RRule)
+		if err != nil {
+			continue
+		}
+
+		for _, occurrence := range rule.Between(plan.StartsAt, from, to) {
+			items = append(items, calendarItem{
+				Kind:            "plan",
+				ID:              plan.ID,
+				Title:           plan.Title,
+				Status:          store.WorkoutPlanned,
+				Start:           occurrence,
+				DurationMinutes: plan.DurationMinutes,
+				TemplateID:      plan.TemplateID,
+			})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Start.Before(items[j].Start) })
+
+	return items
+}
+
+// events of the feed, plans keep their recurrence rule so calendar apps expand them
+func feedEvents(workouts []*store.Workout, plans []*store.WorkoutPlan, host string, now time.Time) []calendar.Event {
+	events := []calendar.Event{}
+
+	for _, workout := range workouts {
+		events = append(events, calendar.Event{
+			UID:         fmt.Sprintf("workout-%d@%s", workout.ID, host),
+			Summary:     workout.Title,
+			Description: workout.Description,
+			Start:       workout.PerformedAt,
+			Duration:    time.Duration(workout.DurationMinutes) * time.Minute,
+			Status:      calendar.StatusConfirmed,
+			Stamp:       now,
+		})
+	}
+
+	for _, plan := range plans {
+		events = append(events, calendar.Event{
+			UID:         fmt.Sprintf("plan-%d@%s", plan.ID, host),
+			Summary:     plan.Title,
+			Description: plan.Description,
+			Start:       plan.StartsAt,
+			Duration:    time.Duration(plan.DurationMinutes) * time.Minute,
+			Status:      calendar.StatusConfirmed,
+			RRule:       plan.RRule,
+			Stamp:       plan.UpdatedAt,
+		})
+	}
+
+	return events
+}
+
+// workouts and plan occurrences between the from and to params, the next four weeks by default
+func (ch *CalendarHandler) HandleCalendar(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	from, err := utils.ReadTimeQuery(r, "from")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	to, err := utils.ReadTimeQuery(r, "to")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if from == nil {
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		from = &today
+	}
+
+	if to == nil {
+		end := from.Add(defaultCalendarRange)
+		to = &end
+	}
+
+	if !to.After(*from) || to.Sub(*from) > maxCalendarRange {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "to must be after from and at most 366 days later"})
+		return
+	}
+
+	workouts, err := ch.calendarStore.ListScheduledWorkouts(r.Context(), store.ScheduleFilter{UserID: currentUser.ID, From: from, To: to})
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "listScheduledWorkouts", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	plans, err := ch.calendarStore.ListPlans(r.Context(), currentUser.ID)
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "listPlans", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"from": from, "to": to, "items": calendarItems(workouts, plans, *from, *to)})
+}
+
+// loads the plan of the id param and checks that it belongs to the current user, the response is written on failure
+func (ch *CalendarHandler) ownedPlan(w http.ResponseWriter, r *http.Request) *store.WorkoutPlan {
+	planID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ch.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid plan id"})
+		return nil
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return nil
+	}
+
+	plan, err := ch.calendarStore.GetPlanByID(r.Context(), planID)
+
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "getPlanByID", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+
+	if plan == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "plan does not exist"})
+		return nil
+	}
+
+	if plan.UserID != currentUser.ID {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized for this action"})
+		return nil
+	}
+
+	return plan
+}
+
+func (ch *CalendarHandler) HandleListPlans(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	plans, err := ch.calendarStore.ListPlans(r.Context(), currentUser.ID)
+
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "listingPlans", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"plans": plans})
+}
+
+func (ch *CalendarHandler) HandleCreatePlan(w http.ResponseWriter, r *http.Request) {
+	var req planRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ch.logger.WarnContext(r.Context(), "decodingCreatePlan", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	plan := &store.WorkoutPlan{UserID: currentUser.ID}
+
+	err = applyPlanRequest(&req, plan)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = ch.calendarStore.CreatePlan(r.Context(), plan)
+
+	if errors.Is(err, store.ErrUnknownTemplate) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "createPlan", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"plan": plan})
+}
+
+func (ch *CalendarHandler) HandleUpdatePlanById(w http.ResponseWriter, r *http.Request) {
+	plan := ch.ownedPlan(w, r)
+	if plan == nil {
+		return
+	}
+
+	var req planRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ch.logger.WarnContext(r.Context(), "decodingUpdatePlan", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	err = applyPlanRequest(&req, plan)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = ch.calendarStore.UpdatePlan(r.Context(), plan)
+
+	if errors.Is(err, store.ErrUnknownTemplate) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "plan does not exist"})
+		return
+	}
+
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "updatingPlan", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"plan": plan})
+}
+
+func (ch *CalendarHandler) HandleDeletePlanById(w http.ResponseWriter, r *http.Request) {
+	plan := ch.ownedPlan(w, r)
+	if plan == nil {
+		return
+	}
+
+	err := ch.calendarStore.DeletePlan(r.Context(), int64(plan.ID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "plan does not exist"})
+		return
+	}
+
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "deletingPlan", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"plan": "deleted"})
+}
+
+// issues a new secret feed url for the current user, the previous url stops working
+func (ch *CalendarHandler) HandleCreateFeed(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	err := ch.tokenStore.DeleteAllTokensForUser(r.Context(), currentUser.ID, tokens.ScopeCalendar)
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "deletingFeedToken", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	token, err := ch.tokenStore.CreateNewToken(r.Context(), currentUser.ID, tokens.CalendarFeedTTL, tokens.ScopeCalendar)
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "creatingFeedToken", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"feed_url": fmt.Sprintf("%s://%s/calendar/feed/%s.ics", scheme, r.Host, token.PlainText)})
+}
+
+func (ch *CalendarHandler) HandleDeleteFeed(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	err := ch.tokenStore.DeleteAllTokensForUser(r.Context(), currentUser.ID, tokens.ScopeCalendar)
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "deletingFeedToken", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"feed": "deleted"})
+}
+
+// iCalendar feed of the planned workouts and the plans of the owner of the secret in the url, it needs no authentication
+func (ch *CalendarHandler) HandleFeed(w http.ResponseWriter, r *http.Request) {
+	user, err := ch.userStore.GetUserToken(r.Context(), tokens.ScopeCalendar, chi.URLParam(r, "token"))
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "getFeedUser", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if user == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "feed does not exist"})
+		return
+	}
+
+	now := time.Now()
+	from := now.Add(-feedHistory)
+
+	workouts, err := ch.calendarStore.ListScheduledWorkouts(r.Context(), store.ScheduleFilter{UserID: user.ID, From: &from, Status: store.WorkoutPlanned})
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "listScheduledWorkouts", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	plans, err := ch.calendarStore.ListPlans(r.Context(), user.ID)
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "listPlans", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="workouts.ics"`)
+
+	err = calendar.WriteCalendar(w, user.Username+" workouts", feedEvents(workouts, plans, r.Host, now))
+	if err != nil {
+		ch.logger.ErrorContext(r.Context(), "writingFeed", "error", err)
+	}
+}
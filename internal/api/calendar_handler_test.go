@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tokens"
+)
+
+func TestCalendarItems(t *testing.T) {
+	from := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	to := from.Add(7 * 24 * time.Hour)
+
+	workouts := []*store.Workout{
+		{ID: 1, Title: "long run", Status: store.WorkoutPlanned, PerformedAt: from.Add(5*24*time.Hour + 8*time.Hour), DurationMinutes: 90},
+	}
+	plans := []*store.WorkoutPlan{
+		{ID: 2, Title: "strength", StartsAt: time.Date(2025, 3, 3, 7, 0, 0, 0, time.UTC), DurationMinutes: 60, RRule: "FREQ=WEEKLY;BYDAY=MO,TH", TemplateID: intPtr(4)},
+		{ID: 3, Title: "broken", StartsAt: from, DurationMinutes: 60, RRule: "FREQ=YEARLY"},
+	}
+
+	items := calendarItems(workouts, plans, from, to)
+
+	require.Len(t, items, 3)
+	assert.Equal(t, "plan", items[0].Kind)
+	assert.Equal(t, time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC), items[0].Start)
+	assert.Equal(t, time.Date(2025, 3, 13, 7, 0, 0, 0, time.UTC), items[1].Start)
+	assert.Equal(t, "workout", items[2].Kind)
+	assert.Equal(t, 1, items[2].ID)
+}
+
+func TestApplyPlanRequest(t *testing.T) {
+	startsAt := time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		req      planRequest
+		wantRule string
+		wantErr  string
+	}{
+		{
+			name:     "rule is stored canonically",
+			req:      planRequest{Title: stringPtr("strength"), StartsAt: &startsAt, DurationMinutes: intPtr(60), RRule: stringPtr("RRULE:freq=weekly;byday=th,mo")},
+			wantRule: "FREQ=WEEKLY;BYDAY=MO,TH",
+		},
+		{
+			name:    "missing start",
+			req:     planRequest{Title: stringPtr("strength"), DurationMinutes: intPtr(60), RRule: stringPtr("FREQ=DAILY")},
+			wantErr: "starts_at is required",
+		},
+		{
+			name:    "invalid rule",
+			req:     planRequest{Title: stringPtr("strength"), StartsAt: &startsAt, DurationMinutes: intPtr(60), RRule: stringPtr("FREQ=HOURLY")},
+			wantErr: "invalid recurrence rule: FREQ must be DAILY, WEEKLY or MONTHLY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &store.WorkoutPlan{}
+
+			err := applyPlanRequest(&tt.req, plan)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRule, plan.RRule)
+		})
+	}
+}
+
+// user store that only resolves the calendar token "secret"
+type feedUserStore struct {
+	store.UserStore
+}
+
+func (s *feedUserStore) GetUserToken(ctx context.Context, scope, tokenPlainText string) (*store.User, error) {
+	if scope == tokens.ScopeCalendar && tokenPlainText == "secret" {
+		return &store.User{ID: 1, Username: "runner"}, nil
+	}
+	return nil, nil
+}
+
+type feedCalendarStore struct {
+	store.CalendarStore
+}
+
+func (s *feedCalendarStore) ListScheduledWorkouts(ctx context.Context, filter store.ScheduleFilter) ([]*store.Workout, error) {
+	return []*store.Workout{{ID: 7, Title: "tempo run", Status: filter.Status, PerformedAt: time.Now().Add(24 * time.Hour), DurationMinutes: 45}}, nil
+}
+
+func (s *feedCalendarStore) ListPlans(ctx context.Context, userID int) ([]*store.WorkoutPlan, error) {
+	return []*store.WorkoutPlan{}, nil
+}
+
+func TestHandleFeed(t *testing.T) {
+	handler := NewCalendarHandler(&feedCalendarStore{}, nil, &feedUserStore{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	router := chi.NewRouter()
+	router.Get("/calendar/feed/{token}.ics", handler.HandleFeed)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{name: "known secret", path: "/calendar/feed/secret.ics", wantStatus: http.StatusOK},
+		{name: "unknown secret", path: "/calendar/feed/guess.ics", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, "text/calendar; charset=utf-8", rec.Header().Get("Content-Type"))
+				assert.Contains(t, rec.Body.String(), "SUMMARY:tempo run")
+			}
+		})
+	}
+}
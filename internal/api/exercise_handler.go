@@ -0,0 +1,325 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+const (
+	defaultExerciseListLimit = 50
+	maxExerciseListLimit     = 200
+)
+
+// struct used for null field checking when creating and updating exercises
+type exerciseRequest struct {
+	Name         *string  `json:"name"`
+	Aliases      []string `json:"aliases"`
+	MuscleGroups []string `json:"muscle_groups"`
+	Equipment    *string  `json:"equipment"`
+	MovementType *string  `json:"movement_type"`
+}
+
+type ExerciseHandler struct {
+	exerciseStore store.ExerciseStore
+	logger        *slog.Logger
+}
+
+func NewExerciseHandler(exerciseStore store.ExerciseStore, logger *slog.Logger) *ExerciseHandler {
+	return &ExerciseHandler{
+		exerciseStore: exerciseStore,
+		logger:        logger,
+	}
+}
+
+// applies the set fields of the request to the exercise and validates the result
+func (eh *ExerciseHandler) applyExerciseRequest(req *exerciseRequest, exercise *store.Exercise) error {
+	if req.Name != nil {
+		exercise.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Aliases != nil {
+		exercise.Aliases = cleanStrings(req.Aliases, false)
+	}
+	if req.MuscleGroups != nil {
+		exercise.MuscleGroups = cleanStrings(req.MuscleGroups, true)
+	}
+	if req.Equipment != nil {
+		exercise.Equipment = strings.ToLower(strings.TrimSpace(*req.Equipment))
+	}
+	if req.MovementType != nil {
+		exercise.MovementType = strings.ToLower(strings.TrimSpace(*req.MovementType))
+	}
+
+	if exercise.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if len(exercise.Name) > 255 {
+		return errors.New("name cannot be greater than 255 characters")
+	}
+
+	if exercise.MovementType != "" && !slices.Contains(store.MovementTypes, exercise.MovementType) {
+		return fmt.Errorf("movement_type must be one of %s", strings.Join(store.MovementTypes, ", "))
+	}
+
+	return nil
+}
+
+// trims the values and drops the empty and duplicated ones
+func cleanStrings(values []string, lower bool) []string {
+	cleaned := []string{}
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if lower {
+			value = strings.ToLower(value)
+		}
+
+		if value != "" && !slices.Contains(cleaned, value) {
+			cleaned = append(cleaned, value)
+		}
+	}
+
+	return cleaned
+}
+
+// lists the catalog together with the custom exercises of the current user
+func (eh *ExerciseHandler) HandleListExercises(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	query := r.URL.Query()
+
+	filter := store.ExerciseFilter{
+		UserID:       currentUser.ID,
+		Query:        strings.TrimSpace(query.Get("q")),
+		MuscleGroup:  strings.ToLower(query.Get("muscle_group")),
+		MovementType: strings.ToLower(query.Get("movement_type")),
+		Equipment:    strings.ToLower(query.Get("equipment")),
+	}
+
+	page, err := utils.ReadIntQuery(r, "page")
+	if err != nil || (page != nil && *page < 1) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "page must be a positive number"})
+		return
+	}
+
+	limit, err := utils.ReadIntQuery(r, "limit")
+	if err != nil || (limit != nil && (*limit < 1 || *limit > maxExerciseListLimit)) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("limit must be between 1 and %d", maxExerciseListLimit)})
+		return
+	}
+
+	currentPage, pageSize := 1, defaultExerciseListLimit
+	if page != nil {
+		currentPage = *page
+	}
+	if limit != nil {
+		pageSize = *limit
+	}
+
+	filter.Limit, filter.Offset = pageSize, (currentPage-1)*pageSize
+
+	exercises, err := eh.exerciseStore.ListExercises(r.Context(), filter)
+
+	if err != nil {
+		eh.logger.ErrorContext(r.Context(), "listingExercises", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"exercises": exercises, "page": currentPage, "limit": pageSize})
+}
+
+// the custom exercises of other users are reported as missing
+func (eh *ExerciseHandler) HandleGetExerciseById(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		eh.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	exercise, err := eh.exerciseStore.GetExerciseByID(r.Context(), exerciseID, currentUser.ID)
+
+	if err != nil {
+		eh.logger.ErrorContext(r.Context(), "getExerciseByID", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if exercise == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "exercise does not exist"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (eh *ExerciseHandler) HandleCreateExercise(w http.ResponseWriter, r *http.Request) {
+	var req exerciseRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		eh.logger.WarnContext(r.Context(), "decodingCreateExercise", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	exercise := &store.Exercise{CreatedBy: &currentUser.ID}
+
+	err = eh.applyExerciseRequest(&req, exercise)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = eh.exerciseStore.CreateExercise(r.Context(), exercise)
+
+	if errors.Is(err, store.ErrExerciseNameTaken) {
+		utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		eh.logger.ErrorContext(r.Context(), "createExercise", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"exercise": exercise})
+}
+
+// loads the exercise of the id param and checks that the current user created it, the response is written on failure
+func (eh *ExerciseHandler) ownedExercise(w http.ResponseWriter, r *http.Request) *store.Exercise {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		eh.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return nil
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return nil
+	}
+
+	exercise, err := eh.exerciseStore.GetExerciseByID(r.Context(), exerciseID, currentUser.ID)
+
+	if err != nil {
+		eh.logger.ErrorContext(r.Context(), "getExerciseByID", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+
+	if exercise == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "exercise does not exist"})
+		return nil
+	}
+
+	//exercises of the default catalog have no creator so nobody can change them
+	if exercise.CreatedBy == nil || *exercise.CreatedBy != currentUser.ID {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized for this action"})
+		return nil
+	}
+
+	return exercise
+}
+
+func (eh *ExerciseHandler) HandleUpdateExerciseById(w http.ResponseWriter, r *http.Request) {
+	exercise := eh.ownedExercise(w, r)
+	if exercise == nil {
+		return
+	}
+
+	var req exerciseRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		eh.logger.WarnContext(r.Context(), "decodingUpdateExercise", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	err = eh.applyExerciseRequest(&req, exercise)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = eh.exerciseStore.UpdateExercise(r.Context(), exercise)
+
+	if errors.Is(err, store.ErrExerciseNameTaken) {
+		utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "exercise does not exist"})
+		return
+	}
+
+	if err != nil {
+		eh.logger.ErrorContext(r.Context(), "updatingExercise", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+func (eh *ExerciseHandler) HandleDeleteExerciseById(w http.ResponseWriter, r *http.Request) {
+	exercise := eh.ownedExercise(w, r)
+	if exercise == nil {
+		return
+	}
+
+	err := eh.exerciseStore.DeleteExercise(r.Context(), exercise.ID)
+
+	if errors.Is(err, store.ErrExerciseInUse) {
+		utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "exercise does not exist"})
+		return
+	}
+
+	if err != nil {
+		eh.logger.ErrorContext(r.Context(), "deletingExercise", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"exercise": "deleted"})
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+// formats of the export and their content types
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"json":   "application/json",
+	"ndjson": "application/x-ndjson",
+}
+
+// the response is flushed every exportFlushEvery workouts, a client that does not read a batch within exportWriteTimeout is dropped
+const (
+	exportFlushEvery   = 100
+	exportWriteTimeout = 30 * time.Second
+)
+
+type ExportHandler struct {
+	workoutStore store.WorkoutStore
+	logger       *slog.Logger
+}
+
+// export handler constructor
+func NewExportHandler(workoutStore store.WorkoutStore, logger *slog.Logger) *ExportHandler {
+	return &ExportHandler{
+		workoutStore: workoutStore,
+		logger:       logger,
+	}
+}
+
+// writes one workout at a time in one of the export formats
+type exportEncoder interface {
+	begin() error
+	encode(workout *store.Workout) error
+	end() error
+}
+
+func newExportEncoder(format string, w io.Writer) exportEncoder {
+	switch format {
+	case "csv":
+		return &csvExportEncoder{writer: csv.NewWriter(w)}
+	case "ndjson":
+		return &ndjsonExportEncoder{encoder: json.NewEncoder(w)}
+	}
+
+	return &jsonExportEncoder{w: w}
+}
+
+// streams the whole training log of the user, optionally limited to the workouts performed between from and to
+func (eh *ExportHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "format must be one of csv, json, ndjson"})
+		return
+	}
+
+	filter := store.ExportFilter{UserID: currentUser.ID}
+
+	var err error
+	filter.From, err = utils.ReadTimeQuery(r, "from")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	filter.To, err = utils.ReadTimeQuery(r, "to")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "to must be after from"})
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	encoder := newExportEncoder(format, w)
+	written := 0
+	started := false
+
+	//the response starts with the first workout so a failing query can still be answered with an error status
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="workouts.`+format+`"`)
+		return encoder.begin()
+	}
+
+	err = eh.workoutStore.ExportWorkouts(r.Context(), filter, func(workout *store.Workout) error {
+		err := start()
+		if err != nil {
+			return err
+		}
+
+		err = encoder.encode(workout)
+		if err != nil {
+			return err
+		}
+
+		written++
+		if written%exportFlushEvery == 0 {
+			return flushExport(controller, encoder)
+		}
+
+		return nil
+	})
+
+	if err != nil && !started {
+		eh.logger.ErrorContext(r.Context(), "exportWorkouts", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if err == nil {
+		err = start()
+	}
+
+	if err == nil {
+		err = encoder.end()
+	}
+
+	//the status is already sent, the client sees a truncated file
+	if err != nil {
+		eh.logger.ErrorContext(r.Context(), "exportWorkouts", "error", err, "written", written)
+		return
+	}
+
+	eh.logger.InfoContext(r.Context(), "workouts exported", "format", format, "workouts", written)
+}
+
+// pushes the buffered rows to the client and gives it exportWriteTimeout to read the next batch
+func flushExport(controller *http.ResponseController, encoder exportEncoder) error {
+	if csvEncoder, ok := encoder.(*csvExportEncoder); ok {
+		csvEncoder.writer.Flush()
+		err := csvEncoder.writer.Error()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	if err != nil && err != http.ErrNotSupported {
+		return err
+	}
+
+	err = controller.Flush()
+	if err != nil && err != http.ErrNotSupported {
+		return err
+	}
+
+	return nil
+}
+
+// a json document {"workouts": [...]} written one workout at a time
+type jsonExportEncoder struct {
+	w       io.Writer
+	written bool
+}
+
+func (e *jsonExportEncoder) begin() error {
+	_, err := io.WriteString(e.w, `{"workouts": [`)
+	return err
+}
+
+func (e *jsonExportEncoder) encode(workout *store.Workout) error {
+	js, err := json.Marshal(workout)
+	if err != nil {
+		return err
+	}
+
+	separator := "\n"
+	if e.written {
+		separator = ",\n"
+	}
+	e.written = true
+
+	_, err = io.WriteString(e.w, separator+string(js))
+	return err
+}
+
+func (e *jsonExportEncoder) end() error {
+	_, err := io.WriteString(e.w, "\n]}\n")
+	return err
+}
+
+// one json workout per line
+type ndjsonExportEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonExportEncoder) begin() error {
+	return nil
+}
+
+func (e *ndjsonExportEncoder) encode(workout *store.Workout) error {
+	return e.encoder.Encode(workout)
+}
+
+func (e *ndjsonExportEncoder) end() error {
+	return nil
+}
+
+// one row per entry with the columns of its workout repeated, workouts without entries get a row with empty entry columns
+type csvExportEncoder struct {
+	writer *csv.Writer
+}
+
+var csvExportHeader = []string{
+	"workout_id", "performed_at", "title", "description", "status", "duration_minutes", "calories_burned",
+	"entry_id", "order_index", "exercise_id", "exercise_name", "entry_type", "sets", "reps", "weight", "duration_seconds",
+	"distance", "distance_unit", "avg_heart_rate", "max_heart_rate", "elevation_gain_meters", "pace_seconds_per_km", "notes",
+}
+
+func (e *csvExportEncoder) begin() error {
+	return e.writer.Write(csvExportHeader)
+}
+
+func (e *csvExportEncoder) encode(workout *store.Workout) error {
+	workoutColumns := []string{
+		strconv.Itoa(workout.ID),
+		workout.PerformedAt.UTC().Format(time.RFC3339),
+		csvText(workout.Title),
+		csvText(workout.Description),
+		workout.Status,
+		strconv.Itoa(workout.DurationMinutes),
+		strconv.Itoa(workout.CaloriesBurned),
+	}
+
+	if len(workout.Entries) == 0 {
+		return e.writer.Write(append(workoutColumns, make([]string, len(csvExportHeader)-len(workoutColumns))...))
+	}
+
+	for _, entry := range workout.Entries {
+		row := append(workoutColumns[:len(workoutColumns):len(workoutColumns)],
+			strconv.Itoa(entry.ID),
+			strconv.Itoa(entry.OrderIndex),
+			strconv.FormatInt(entry.ExerciseID, 10),
+			csvText(entry.ExerciseName),
+			entry.EntryType,
+			strconv.Itoa(entry.Sets),
+			csvInt(entry.Reps),
+			csvFloat(entry.Weight),
+			csvInt(entry.DurationSeconds),
+			csvFloat(entry.Distance),
+			csvString(entry.DistanceUnit),
+			csvInt(entry.AvgHeartRate),
+			csvInt(entry.MaxHeartRate),
+			csvFloat(entry.ElevationGainMeters),
+			csvFloat(entry.PaceSecondsPerKm),
+			csvText(entry.Notes),
+		)
+
+		err := e.writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *csvExportEncoder) end() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// text starting like a formula is quoted with an apostrophe so spreadsheets do not evaluate it
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func csvInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+func csvFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
+
+func csvString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+)
+
+// workout store exporting a fixed list of workouts, or failing with err before the first one
+type exportWorkoutStore struct {
+	store.WorkoutStore
+	workouts []*store.Workout
+	err      error
+	filter   store.ExportFilter
+}
+
+func (s *exportWorkoutStore) ExportWorkouts(ctx context.Context, filter store.ExportFilter, fn func(*store.Workout) error) error {
+	s.filter = filter
+	if s.err != nil {
+		return s.err
+	}
+
+	for _, workout := range s.workouts {
+		err := fn(workout)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func exportedWorkouts() []*store.Workout {
+	performedAt := time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)
+	pace := 300.0
+
+	return []*store.Workout{
+		{
+			ID: 1, UserID: 7, Title: "=HYPERLINK(\"x\")", DurationMinutes: 60, PerformedAt: performedAt, Status: store.WorkoutCompleted,
+			Entries: []store.WorkoutEntry{
+				{ID: 10, ExerciseID: 3, ExerciseName: "Bench Press", EntryType: store.EntryStrength, Sets: 3, Reps: intPtr(5), Weight: floatPtr(82.5), OrderIndex: 1, Notes: "paused, last set"},
+				{ID: 11, ExerciseID: 4, ExerciseName: "Running", EntryType: store.EntryCardio, Sets: 1, DurationSeconds: intPtr(1500), Distance: floatPtr(5), DistanceUnit: stringPtr("km"), PaceSecondsPerKm: &pace, OrderIndex: 2},
+			},
+		},
+		{ID: 2, UserID: 7, Title: "rest day walk", DurationMinutes: 20, PerformedAt: performedAt.Add(24 * time.Hour), Status: store.WorkoutCompleted, Entries: []store.WorkoutEntry{}},
+	}
+}
+
+func TestHandleExport(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		storeErr        error
+		wantStatus      int
+		wantContentType string
+		check           func(t *testing.T, body string)
+	}{
+		{
+			name:            "json is the default format",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			check: func(t *testing.T, body string) {
+				var export struct {
+					Workouts []store.Workout `json:"workouts"`
+				}
+				require.NoError(t, json.Unmarshal([]byte(body), &export))
+				require.Len(t, export.Workouts, 2)
+				assert.Len(t, export.Workouts[0].Entries, 2)
+				assert.Equal(t, "rest day walk", export.Workouts[1].Title)
+			},
+		},
+		{
+			name:            "ndjson has one workout per line",
+			query:           "?format=ndjson",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/x-ndjson",
+			check: func(t *testing.T, body string) {
+				lines := strings.Split(strings.TrimSpace(body), "\n")
+				require.Len(t, lines, 2)
+
+				var workout store.Workout
+				require.NoError(t, json.Unmarshal([]byte(lines[1]), &workout))
+				assert.Equal(t, 2, workout.ID)
+			},
+		},
+		{
+			name:            "csv has one row per entry",
+			query:           "?format=csv&from=2025-03-01&to=2025-04-01",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			check: func(t *testing.T, body string) {
+				lines := strings.Split(strings.TrimSpace(body), "\n")
+				require.Len(t, lines, 4)
+				assert.True(t, strings.HasPrefix(lines[0], "workout_id,performed_at,title"))
+				assert.Equal(t, `1,2025-03-10T07:00:00Z,"'=HYPERLINK(""x"")",,completed,60,0,10,1,3,Bench Press,strength,3,5,82.5,,,,,,,,"paused, last set"`, lines[1])
+				assert.Equal(t, `1,2025-03-10T07:00:00Z,"'=HYPERLINK(""x"")",,completed,60,0,11,2,4,Running,cardio,1,,,1500,5,km,,,,300,`, lines[2])
+				assert.Equal(t, `2,2025-03-11T07:00:00Z,rest day walk,,completed,20,0,,,,,,,,,,,,,,,,`, lines[3])
+			},
+		},
+		{
+			name:       "unknown format",
+			query:      "?format=xlsx",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "range ending before it starts",
+			query:      "?from=2025-04-01&to=2025-03-01",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "failing query before the first workout",
+			storeErr:   errors.New("connection refused"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workoutStore := &exportWorkoutStore{workouts: exportedWorkouts(), err: tt.storeErr}
+			handler := NewExportHandler(workoutStore, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			req := httptest.NewRequest(http.MethodGet, "/export"+tt.query, nil)
+			req = middleware.SetUser(req, &store.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			handler.HandleExport(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code, rr.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, 7, workoutStore.filter.UserID)
+			assert.Equal(t, tt.wantContentType, rr.Header().Get("Content-Type"))
+			tt.check(t, rr.Body.String())
+		})
+	}
+}
+
+func TestHandleExportWithoutWorkouts(t *testing.T) {
+	handler := NewExportHandler(&exportWorkoutStore{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	req := middleware.SetUser(httptest.NewRequest(http.MethodGet, "/export", nil), &store.User{ID: 7})
+	rr := httptest.NewRecorder()
+
+	handler.HandleExport(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"workouts": []}`, rr.Body.String())
+}
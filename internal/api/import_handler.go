@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/zidariu-sabin/femProject/internal/importer"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+// largest csv file accepted by the import
+const maxCSVImportBytes = 10 << 20
+
+type ImportHandler struct {
+	workoutStore store.WorkoutStore
+	logger       *slog.Logger
+}
+
+// import handler constructor
+func NewImportHandler(workoutStore store.WorkoutStore, logger *slog.Logger) *ImportHandler {
+	return &ImportHandler{
+		workoutStore: workoutStore,
+		logger:       logger,
+	}
+}
+
+// imports a csv file in the generic layout or in the layout of a supported app, detected from the header unless format is set,
+// with dry_run=true only the validation report is returned, otherwise every workout is created or none when a row is invalid
+func (ih *ImportHandler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid dry_run parameter"})
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxCSVImportBytes)
+
+	var workouts []*store.Workout
+	var report *importer.Report
+
+	file, err := uploadedFile(r)
+	if err == nil {
+		defer file.Close()
+		workouts, report, err = importer.Parse(file, r.URL.Query().Get("format"))
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		utils.WriteJson(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "the file is larger than 10MB"})
+		return
+	}
+
+	if err != nil {
+		ih.logger.WarnContext(r.Context(), "parsingImport", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid csv file: " + err.Error()})
+		return
+	}
+
+	if dryRun {
+		utils.WriteJson(w, http.StatusOK, utils.Envelope{"report": report, "dry_run": true})
+		return
+	}
+
+	if len(report.Errors) > 0 {
+		utils.WriteJson(w, http.StatusUnprocessableEntity, utils.Envelope{"error": "the file has invalid rows, nothing was imported", "report": report})
+		return
+	}
+
+	for _, workout := range workouts {
+		workout.UserID = currentUser.ID
+	}
+
+	err = ih.workoutStore.ImportWorkouts(r.Context(), workouts)
+
+	//a value the importer let through that the database still refuses
+	if errors.Is(err, store.ErrInvalidImport) {
+		utils.WriteJson(w, http.StatusUnprocessableEntity, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		ih.logger.ErrorContext(r.Context(), "importWorkouts", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	workoutIDs := make([]int, len(workouts))
+	for i, workout := range workouts {
+		workoutIDs[i] = workout.ID
+	}
+
+	ih.logger.InfoContext(r.Context(), "workouts imported", "format", report.Format, "workouts", len(workouts))
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"report": report, "workout_ids": workoutIDs})
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+)
+
+// workout store keeping the workouts of the last import, or failing with err
+type importCSVStore struct {
+	store.WorkoutStore
+	imported []*store.Workout
+	err      error
+}
+
+func (s *importCSVStore) ImportWorkouts(ctx context.Context, workouts []*store.Workout) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	for i, workout := range workouts {
+		workout.ID = i + 1
+	}
+	s.imported = workouts
+	return nil
+}
+
+const importCSV = `performed_at,title,exercise_name,sets,reps,weight
+2025-03-10,push,bench press,3,5,80
+2025-03-10,push,overhead press,3,8,40
+2025-03-12,pull,barbell row,3,8,60
+`
+
+func TestHandleImport(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		body          string
+		storeErr      error
+		wantStatus    int
+		wantImported  int
+		wantRowErrors int
+	}{
+		{
+			name:         "workouts are created",
+			body:         importCSV,
+			wantStatus:   http.StatusCreated,
+			wantImported: 2,
+		},
+		{
+			name:       "dry run only returns the report",
+			query:      "?dry_run=true",
+			body:       importCSV,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:          "invalid rows stop the whole import",
+			body:          importCSV + "2025-03-13,legs,squat,0,5,100\n",
+			wantStatus:    http.StatusUnprocessableEntity,
+			wantRowErrors: 1,
+		},
+		{
+			name:          "dry run reports the invalid rows",
+			query:         "?dry_run=1",
+			body:          importCSV + "2025-03-13,legs,squat,0,5,100\n",
+			wantStatus:    http.StatusOK,
+			wantRowErrors: 1,
+		},
+		{
+			name:       "values the database refuses",
+			body:       importCSV,
+			storeErr:   store.ErrInvalidImport,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "unknown layout",
+			body:       "a,b\n1,2\n",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid dry_run",
+			query:      "?dry_run=maybe",
+			body:       importCSV,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workoutStore := &importCSVStore{err: tt.storeErr}
+			handler := NewImportHandler(workoutStore, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			req := httptest.NewRequest(http.MethodPost, "/import"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "text/csv")
+			req = middleware.SetUser(req, &store.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			handler.HandleImport(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code, rr.Body.String())
+			require.Len(t, workoutStore.imported, tt.wantImported)
+
+			for _, workout := range workoutStore.imported {
+				assert.Equal(t, 7, workout.UserID)
+			}
+
+			var response struct {
+				Report struct {
+					Workouts int               `json:"workouts"`
+					Errors   []json.RawMessage `json:"errors"`
+				} `json:"report"`
+				WorkoutIDs []int `json:"workout_ids"`
+			}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Len(t, response.Report.Errors, tt.wantRowErrors)
+			assert.Len(t, response.WorkoutIDs, tt.wantImported)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/zidariu-sabin/femProject/internal/mailer"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tokens"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+// bcrypt ignores everything after the first 72 bytes of a password
+const (
+	minPasswordLength = 8
+	maxPasswordBytes  = 72
+)
+
+type PasswordHandler struct {
+	userStore  store.UserStore
+	tokenStore store.TokenStore
+	mailer     mailer.Mailer
+	bcryptCost int
+	logger     *slog.Logger
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type passwordResetTokenRequest struct {
+	Email string `json:"email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// password handler constructor
+func NewPasswordHandler(userStore store.UserStore, tokenStore store.TokenStore, m mailer.Mailer, bcryptCost int, logger *slog.Logger) *PasswordHandler {
+	return &PasswordHandler{
+		userStore:  userStore,
+		tokenStore: tokenStore,
+		mailer:     m,
+		bcryptCost: bcryptCost,
+		logger:     logger,
+	}
+}
+
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return errors.New("password must be at least 8 characters long")
+	}
+
+	if len(password) > maxPasswordBytes {
+		return errors.New("password cannot be longer than 72 bytes")
+	}
+
+	return nil
+}
+
+// changes the password of the current user once the current password is confirmed, every other session is logged out
+func (ph *PasswordHandler) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	var req changePasswordRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.logger.WarnContext(r.Context(), "decodingChangePasswordRequest", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	if req.CurrentPassword == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "the current password is required"})
+		return
+	}
+
+	err = validatePassword(req.NewPassword)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	matches, err := currentUser.PasswordHash.Matches(req.CurrentPassword)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "PasswordHash.Matches", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if !matches {
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid credentials"})
+		return
+	}
+
+	ph.setPassword(w, r, currentUser, req.NewPassword, middleware.GetToken(r))
+}
+
+// emails a password reset token to the owner of the email, the response is the same whether the email
+// belongs to an account or not so the endpoint cannot be used to find out who is registered
+func (ph *PasswordHandler) HandleCreatePasswordResetToken(w http.ResponseWriter, r *http.Request) {
+	var req passwordResetTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Email == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "email is required"})
+		return
+	}
+
+	accepted := utils.Envelope{"message": "if the email belongs to an account, a password reset token was sent to it"}
+
+	user, err := ph.userStore.GetUserByEmail(r.Context(), req.Email)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "getUserByEmail", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if user == nil {
+		utils.WriteJson(w, http.StatusAccepted, accepted)
+		return
+	}
+
+	//only the latest reset token is valid
+	err = ph.tokenStore.DeleteAllTokensForUser(r.Context(), user.ID, tokens.ScopePasswordReset)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "deletingPasswordResetTokens", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	token, err := ph.tokenStore.CreateNewToken(r.Context(), user.ID, tokens.PasswordResetTTL, tokens.ScopePasswordReset)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "creatingPasswordResetToken", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	//the mailer sends in the background, waiting for the delivery or reporting its failure would tell that the email is registered
+	err = ph.mailer.Send(r.Context(), mailer.PasswordResetMessage(user.Email, user.Username, token.PlainText, tokens.PasswordResetTTL))
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "sendingPasswordResetEmail", "error", err)
+	}
+
+	utils.WriteJson(w, http.StatusAccepted, accepted)
+}
+
+// sets a new password with a token from a password reset email, every session of the user is logged out
+func (ph *PasswordHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Token == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "the password reset token is required"})
+		return
+	}
+
+	err = validatePassword(req.Password)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	user, err := ph.userStore.GetUserToken(r.Context(), tokens.ScopePasswordReset, req.Token)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "getUserToken", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if user == nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid or expired password reset token"})
+		return
+	}
+
+	ph.setPassword(w, r, user, req.Password, "")
+}
+
+// hashes and saves the new password, the session of keepToken stays logged in
+func (ph *PasswordHandler) setPassword(w http.ResponseWriter, r *http.Request, user *store.User, newPassword, keepToken string) {
+	err := user.PasswordHash.SetWithCost(newPassword, ph.bcryptCost)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "hashingPassword", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	err = ph.userStore.UpdatePassword(r.Context(), user, keepToken)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user does not exist"})
+		return
+	}
+
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "updatingPassword", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	ph.logger.InfoContext(r.Context(), "password changed", "user_id", user.ID)
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"password": "changed"})
+}
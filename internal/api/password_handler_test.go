@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/mailer"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tokens"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// user store with a single user reachable by email or by a token of one scope, shared by the password reset and
+// the activation tests
+type emailUserStore struct {
+	store.UserStore
+	user  *store.User
+	scope string
+}
+
+func (s *emailUserStore) GetUserByEmail(ctx context.Context, email string) (*store.User, error) {
+	if s.user != nil && s.user.Email == email {
+		return s.user, nil
+	}
+	return nil, nil
+}
+
+func (s *emailUserStore) GetUserToken(ctx context.Context, scope, tokenPlainText string) (*store.User, error) {
+	if scope == s.scope && tokenPlainText == "MAILEDTOKEN" {
+		return s.user, nil
+	}
+	return nil, nil
+}
+
+// token store handing out MAILEDTOKEN and recording the scopes it cleared
+type emailTokenStore struct {
+	store.TokenStore
+	deletedScopes []string
+	created       []*tokens.Token
+}
+
+func (s *emailTokenStore) DeleteAllTokensForUser(ctx context.Context, userID int, scope string) error {
+	s.deletedScopes = append(s.deletedScopes, scope)
+	return nil
+}
+
+func (s *emailTokenStore) CreateNewToken(ctx context.Context, userID int, ttl time.Duration, scope string) (*tokens.Token, error) {
+	token := &tokens.Token{PlainText: "MAILEDTOKEN", UserID: userID, Expiry: time.Now().Add(ttl), Scope: scope}
+	s.created = append(s.created, token)
+	return token, nil
+}
+
+type passwordUserStore struct {
+	emailUserStore
+	updated   *store.User
+	keptToken string
+}
+
+func (s *passwordUserStore) UpdatePassword(ctx context.Context, user *store.User, currentTokenPlainText string) error {
+	s.updated = user
+	s.keptToken = currentTokenPlainText
+	return nil
+}
+
+func newPasswordHandler(userStore *passwordUserStore, tokenStore *emailTokenStore, m mailer.Mailer) *PasswordHandler {
+	return NewPasswordHandler(userStore, tokenStore, m, bcrypt.MinCost, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestHandleChangePassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantUpdated bool
+	}{
+		{name: "missing current password", body: `{"new_password":"correct horse"}`, wantStatus: http.StatusBadRequest},
+		{name: "new password too short", body: `{"current_password":"hunter22","new_password":"short"}`, wantStatus: http.StatusBadRequest},
+		{name: "wrong current password", body: `{"current_password":"wrong","new_password":"correct horse"}`, wantStatus: http.StatusUnauthorized},
+		{name: "password changed", body: `{"current_password":"hunter22","new_password":"correct horse"}`, wantStatus: http.StatusOK, wantUpdated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userStore := &passwordUserStore{}
+			handler := newPasswordHandler(userStore, &emailTokenStore{}, mailer.NewMemoryMailer())
+
+			req := httptest.NewRequest(http.MethodPut, "/user/password", strings.NewReader(tt.body))
+			req = middleware.SetUser(req, accountUser(t))
+			req = middleware.SetToken(req, "CURRENTTOKEN")
+			rr := httptest.NewRecorder()
+
+			handler.HandleChangePassword(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			require.Equal(t, tt.wantUpdated, userStore.updated != nil)
+
+			if tt.wantUpdated {
+				assert.Equal(t, "CURRENTTOKEN", userStore.keptToken)
+				matches, err := userStore.updated.PasswordHash.Matches("correct horse")
+				require.NoError(t, err)
+				assert.True(t, matches)
+			}
+		})
+	}
+}
+
+func TestHandleCreatePasswordResetToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantEmail  bool
+	}{
+		{name: "missing email", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown email gets the same answer", body: `{"email":"nobody@example.com"}`, wantStatus: http.StatusAccepted},
+		{name: "reset token is emailed", body: `{"email":"sabin@example.com"}`, wantStatus: http.StatusAccepted, wantEmail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userStore := &passwordUserStore{emailUserStore: emailUserStore{user: accountUser(t), scope: tokens.ScopePasswordReset}}
+			tokenStore := &emailTokenStore{}
+			m := mailer.NewMemoryMailer()
+			handler := newPasswordHandler(userStore, tokenStore, m)
+
+			req := httptest.NewRequest(http.MethodPost, "/tokens/password-reset", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.HandleCreatePasswordResetToken(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.NotContains(t, rr.Body.String(), "MAILEDTOKEN")
+
+			messages := m.Messages()
+			if !tt.wantEmail {
+				assert.Empty(t, messages)
+				assert.Empty(t, tokenStore.created)
+				return
+			}
+
+			assert.Equal(t, []string{tokens.ScopePasswordReset}, tokenStore.deletedScopes)
+			require.Len(t, tokenStore.created, 1)
+			assert.Equal(t, tokens.ScopePasswordReset, tokenStore.created[0].Scope)
+
+			require.Len(t, messages, 1)
+			assert.Equal(t, "sabin@example.com", messages[0].To)
+			assert.Contains(t, messages[0].Body, "MAILEDTOKEN")
+		})
+	}
+}
+
+func TestHandleResetPassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantUpdated bool
+	}{
+		{name: "missing token", body: `{"password":"correct horse"}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid token", body: `{"token":"GUESSED","password":"correct horse"}`, wantStatus: http.StatusBadRequest},
+		{name: "password too long", body: `{"token":"MAILEDTOKEN","password":"` + strings.Repeat("a", 73) + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "password reset", body: `{"token":"MAILEDTOKEN","password":"correct horse"}`, wantStatus: http.StatusOK, wantUpdated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userStore := &passwordUserStore{emailUserStore: emailUserStore{user: accountUser(t), scope: tokens.ScopePasswordReset}}
+			handler := newPasswordHandler(userStore, &emailTokenStore{}, mailer.NewMemoryMailer())
+
+			req := httptest.NewRequest(http.MethodPut, "/users/password", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.HandleResetPassword(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			require.Equal(t, tt.wantUpdated, userStore.updated != nil)
+
+			if tt.wantUpdated {
+				//a reset logs out every session
+				assert.Empty(t, userStore.keptToken)
+				matches, err := userStore.updated.PasswordHash.Matches("correct horse")
+				require.NoError(t, err)
+				assert.True(t, matches)
+			}
+		})
+	}
+}
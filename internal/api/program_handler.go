@@ -0,0 +1,524 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+// struct used for null field checking when creating and updating programs
+type programRequest struct {
+	Title       *string            `json:"title"`
+	Description *string            `json:"description"`
+	Weeks       *int               `json:"weeks"`
+	Days        []store.ProgramDay `json:"days"`
+}
+
+type enrollRequest struct {
+	StartDate string `json:"start_date"`
+}
+
+// the session planned for a date of an enrollment, status is one of
+// not_started, finished, rest, planned or completed
+type plannedSession struct {
+	Date       string                 `json:"date"`
+	Week       int                    `json:"week,omitempty"`
+	Day        int                    `json:"day,omitempty"`
+	Status     string                 `json:"status"`
+	ProgramDay *store.ProgramDay      `json:"program_day,omitempty"`
+	Template   *store.WorkoutTemplate `json:"template,omitempty"`
+	WorkoutID  *int                   `json:"workout_id,omitempty"`
+}
+
+type ProgramHandler struct {
+	programStore  store.ProgramStore
+	templateStore store.TemplateStore
+	workoutStore  store.WorkoutStore
+	logger        *slog.Logger
+}
+
+func NewProgramHandler(programStore store.ProgramStore, templateStore store.TemplateStore, workoutStore store.WorkoutStore, logger *slog.Logger) *ProgramHandler {
+	return &ProgramHandler{
+		programStore:  programStore,
+		templateStore: templateStore,
+		workoutStore:  workoutStore,
+		logger:        logger,
+	}
+}
+
+// applies the set fields of the request to the program and validates the result
+func applyProgramRequest(req *programRequest, program *store.Program) error {
+	if req.Title != nil {
+		program.Title = *req.Title
+	}
+	if req.Description != nil {
+		program.Description = *req.Description
+	}
+	if req.Weeks != nil {
+		program.Weeks = *req.Weeks
+	}
+	//we don't use a pointer here because accessing an empty array gives a slice that is equal to nil
+	if req.Days != nil {
+		program.Days = req.Days
+	}
+
+	if program.Title == "" {
+		return errors.New("title is required")
+	}
+
+	if program.Weeks < 1 {
+		return errors.New("a program needs at least one week")
+	}
+
+	planned := map[[2]int]bool{}
+	for _, day := range program.Days {
+		if day.Week < 1 || day.Week > program.Weeks {
+			return fmt.Errorf("week must be between 1 and %d", program.Weeks)
+		}
+
+		if day.Day < 1 || day.Day > 7 {
+			return errors.New("day must be between 1 and 7")
+		}
+
+		if day.TemplateID < 1 {
+			return errors.New("every day needs a template_id")
+		}
+
+		if planned[[2]int{day.Week, day.Day}] {
+			return fmt.Errorf("week %d day %d is planned twice", day.Week, day.Day)
+		}
+		planned[[2]int{day.Week, day.Day}] = true
+	}
+
+	return nil
+}
+
+// loads the program of the id param and checks that it belongs to the current user, the response is written on failure
+func (ph *ProgramHandler) ownedProgram(w http.ResponseWriter, r *http.Request) (*store.Program, *store.User) {
+	programID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid program id"})
+		return nil, nil
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return nil, nil
+	}
+
+	program, err := ph.programStore.GetProgramByID(r.Context(), programID)
+
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "getProgramByID", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, nil
+	}
+
+	if program == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "program does not exist"})
+		return nil, nil
+	}
+
+	if program.UserID != currentUser.ID {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized for this action"})
+		return nil, nil
+	}
+
+	return program, currentUser
+}
+
+// loads the enrollment of the id param with its program and checks that it belongs to the current user, the response is written on failure
+func (ph *ProgramHandler) ownedEnrollment(w http.ResponseWriter, r *http.Request) (*store.Enrollment, *store.Program, *store.User) {
+	enrollmentID, err := utils.ReadIDParam(r)
+	if err != nil {
+		ph.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid enrollment id"})
+		return nil, nil, nil
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return nil, nil, nil
+	}
+
+	enrollment, err := ph.programStore.GetEnrollmentByID(r.Context(), enrollmentID)
+
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "getEnrollmentByID", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, nil, nil
+	}
+
+	if enrollment == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "enrollment does not exist"})
+		return nil, nil, nil
+	}
+
+	if enrollment.UserID != currentUser.ID {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized for this action"})
+		return nil, nil, nil
+	}
+
+	program, err := ph.programStore.GetProgramByID(r.Context(), int64(enrollment.ProgramID))
+
+	if err != nil || program == nil {
+		ph.logger.ErrorContext(r.Context(), "getProgramByID", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, nil, nil
+	}
+
+	return enrollment, program, currentUser
+}
+
+func (ph *ProgramHandler) HandleListPrograms(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	programs, err := ph.programStore.ListPrograms(r.Context(), currentUser.ID)
+
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "listingPrograms", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"programs": programs})
+}
+
+func (ph *ProgramHandler) HandleGetProgramById(w http.ResponseWriter, r *http.Request) {
+	program, _ := ph.ownedProgram(w, r)
+	if program == nil {
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"program": program})
+}
+
+func (ph *ProgramHandler) HandleCreateProgram(w http.ResponseWriter, r *http.Request) {
+	var req programRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.logger.WarnContext(r.Context(), "decodingCreateProgram", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	program := &store.Program{UserID: currentUser.ID, Days: []store.ProgramDay{}}
+
+	err = applyProgramRequest(&req, program)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = ph.programStore.CreateProgram(r.Context(), program)
+
+	if errors.Is(err, store.ErrUnknownTemplate) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "createProgram", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"program": program})
+}
+
+func (ph *ProgramHandler) HandleUpdateProgramById(w http.ResponseWriter, r *http.Request) {
+	program, _ := ph.ownedProgram(w, r)
+	if program == nil {
+		return
+	}
+
+	var req programRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		ph.logger.WarnContext(r.Context(), "decodingUpdateProgram", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	err = applyProgramRequest(&req, program)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = ph.programStore.UpdateProgram(r.Context(), program)
+
+	if errors.Is(err, store.ErrUnknownTemplate) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "program does not exist"})
+		return
+	}
+
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "updatingProgram", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"program": program})
+}
+
+func (ph *ProgramHandler) HandleDeleteProgramById(w http.ResponseWriter, r *http.Request) {
+	program, _ := ph.ownedProgram(w, r)
+	if program == nil {
+		return
+	}
+
+	err := ph.programStore.DeleteProgram(r.Context(), int64(program.ID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "program does not exist"})
+		return
+	}
+
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "deletingProgram", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"program": "deleted"})
+}
+
+// enrolls the current user in the program, the start date defaults to today
+func (ph *ProgramHandler) HandleEnroll(w http.ResponseWriter, r *http.Request) {
+	program, currentUser := ph.ownedProgram(w, r)
+	if program == nil {
+		return
+	}
+
+	var req enrollRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		ph.logger.WarnContext(r.Context(), "decodingEnroll", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	startDate := time.Now()
+	if req.StartDate != "" {
+		startDate, err = time.Parse(time.DateOnly, req.StartDate)
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "start_date must be a date like 2006-01-02"})
+			return
+		}
+	}
+
+	year, month, day := startDate.Date()
+	enrollment := &store.Enrollment{
+		ProgramID: program.ID,
+		UserID:    currentUser.ID,
+		StartDate: time.Date(year, month, day, 0, 0, 0, 0, time.UTC),
+	}
+
+	err = ph.programStore.CreateEnrollment(r.Context(), enrollment)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "createEnrollment", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"enrollment": enrollment})
+}
+
+func (ph *ProgramHandler) HandleListEnrollments(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	enrollments, err := ph.programStore.ListEnrollments(r.Context(), currentUser.ID)
+
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "listingEnrollments", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"enrollments": enrollments})
+}
+
+// the enrollment with its program and the adherence up to today
+func (ph *ProgramHandler) HandleGetEnrollmentById(w http.ResponseWriter, r *http.Request) {
+	enrollment, program, _ := ph.ownedEnrollment(w, r)
+	if enrollment == nil {
+		return
+	}
+
+	completed, err := ph.programStore.CompletedDays(r.Context(), enrollment.ID)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "completedDays", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"enrollment": enrollment,
+		"program":    program,
+		"adherence":  program.Adherence(enrollment.StartDate, completed, time.Now()),
+	})
+}
+
+// the session planned for today or for the date query param
+func (ph *ProgramHandler) HandleToday(w http.ResponseWriter, r *http.Request) {
+	enrollment, program, _ := ph.ownedEnrollment(w, r)
+	if enrollment == nil {
+		return
+	}
+
+	session, ok := ph.plannedSession(w, r, enrollment, program)
+	if !ok {
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"session": session})
+}
+
+// creates a workout from the template planned for today or for the date query param, linked back to the planned day,
+// the body takes the same overrides as starting a template
+func (ph *ProgramHandler) HandleStartToday(w http.ResponseWriter, r *http.Request) {
+	enrollment, program, currentUser := ph.ownedEnrollment(w, r)
+	if enrollment == nil {
+		return
+	}
+
+	session, ok := ph.plannedSession(w, r, enrollment, program)
+	if !ok {
+		return
+	}
+
+	if session.Template == nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("no session is planned for %s", session.Date)})
+		return
+	}
+
+	var req startTemplateRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		ph.logger.WarnContext(r.Context(), "decodingStartToday", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	workout := session.Template.NewWorkout(currentUser.ID)
+	workout.EnrollmentID = &enrollment.ID
+	workout.ProgramDayID = &session.ProgramDay.ID
+
+	err = applyStartOverrides(&req, workout)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	createdWorkout, err := ph.workoutStore.CreateWorkout(r.Context(), workout)
+
+	if errors.Is(err, store.ErrUnknownExercise) || errors.Is(err, store.ErrExerciseRequired) || errors.Is(err, store.ErrInvalidProgramDay) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "startingProgramDay", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	ph.logger.InfoContext(r.Context(), "workout created from program", "workout_id", createdWorkout.ID, "enrollment_id", enrollment.ID)
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
+}
+
+// works out the session of the enrollment for the date query param, the response is written on failure
+func (ph *ProgramHandler) plannedSession(w http.ResponseWriter, r *http.Request, enrollment *store.Enrollment, program *store.Program) (*plannedSession, bool) {
+	date, err := utils.ReadTimeQuery(r, "date")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+
+	if date == nil {
+		now := time.Now()
+		date = &now
+	}
+
+	session := &plannedSession{Date: date.Format(time.DateOnly)}
+
+	week, day, ok := program.Position(enrollment.StartDate, *date)
+	if !ok {
+		session.Status = "finished"
+		if date.Before(enrollment.StartDate) {
+			session.Status = "not_started"
+		}
+		return session, true
+	}
+
+	session.Week, session.Day = week, day
+	session.ProgramDay = program.DayAt(week, day)
+
+	if session.ProgramDay == nil {
+		session.Status = "rest"
+		return session, true
+	}
+
+	session.Template, err = ph.templateStore.GetTemplateByID(r.Context(), int64(session.ProgramDay.TemplateID))
+	if err != nil || session.Template == nil {
+		ph.logger.ErrorContext(r.Context(), "getTemplateByID", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, false
+	}
+
+	completed, err := ph.programStore.CompletedDays(r.Context(), enrollment.ID)
+	if err != nil {
+		ph.logger.ErrorContext(r.Context(), "completedDays", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, false
+	}
+
+	session.Status = "planned"
+	if workoutID, done := completed[session.ProgramDay.ID]; done {
+		session.Status = "completed"
+		session.WorkoutID = &workoutID
+	}
+
+	return session, true
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zidariu-sabin/femProject/internal/store"
+)
+
+func TestApplyProgramRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     programRequest
+		wantErr string
+	}{
+		{
+			name: "valid program",
+			req: programRequest{
+				Title: stringPtr("beginner strength"),
+				Weeks: intPtr(4),
+				Days:  []store.ProgramDay{{Week: 1, Day: 1, TemplateID: 1}, {Week: 4, Day: 7, TemplateID: 2}},
+			},
+		},
+		{
+			name:    "missing title",
+			req:     programRequest{Weeks: intPtr(4)},
+			wantErr: "title is required",
+		},
+		{
+			name:    "no weeks",
+			req:     programRequest{Title: stringPtr("empty"), Weeks: intPtr(0)},
+			wantErr: "a program needs at least one week",
+		},
+		{
+			name:    "week after the end",
+			req:     programRequest{Title: stringPtr("short"), Weeks: intPtr(2), Days: []store.ProgramDay{{Week: 3, Day: 1, TemplateID: 1}}},
+			wantErr: "week must be between 1 and 2",
+		},
+		{
+			name:    "invalid day",
+			req:     programRequest{Title: stringPtr("short"), Weeks: intPtr(2), Days: []store.ProgramDay{{Week: 1, Day: 8, TemplateID: 1}}},
+			wantErr: "day must be between 1 and 7",
+		},
+		{
+			name:    "missing template",
+			req:     programRequest{Title: stringPtr("short"), Weeks: intPtr(2), Days: []store.ProgramDay{{Week: 1, Day: 2}}},
+			wantErr: "every day needs a template_id",
+		},
+		{
+			name: "day planned twice",
+			req: programRequest{
+				Title: stringPtr("short"),
+				Weeks: intPtr(2),
+				Days:  []store.ProgramDay{{Week: 1, Day: 2, TemplateID: 1}, {Week: 1, Day: 2, TemplateID: 2}},
+			},
+			wantErr: "week 1 day 2 is planned twice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := &store.Program{}
+
+			err := applyProgramRequest(&tt.req, program)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, *tt.req.Title, program.Title)
+			assert.Len(t, program.Days, len(tt.req.Days))
+		})
+	}
+}
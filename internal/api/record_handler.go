@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+const (
+	defaultRecordListLimit = 100
+	maxRecordListLimit     = 500
+)
+
+type RecordHandler struct {
+	recordStore   store.RecordStore
+	exerciseStore store.ExerciseStore
+	logger        *slog.Logger
+}
+
+func NewRecordHandler(recordStore store.RecordStore, exerciseStore store.ExerciseStore, logger *slog.Logger) *RecordHandler {
+	return &RecordHandler{
+		recordStore:   recordStore,
+		exerciseStore: exerciseStore,
+		logger:        logger,
+	}
+}
+
+// timeline of every personal record of the current user, optionally of a single record type
+func (rh *RecordHandler) HandleListRecords(w http.ResponseWriter, r *http.Request) {
+	rh.listRecords(w, r, 0)
+}
+
+// timeline of the personal records of the current user for one exercise
+func (rh *RecordHandler) HandleListExerciseRecords(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := utils.ReadIDParam(r)
+	if err != nil {
+		rh.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	exercise, err := rh.exerciseStore.GetExerciseByID(r.Context(), exerciseID, currentUser.ID)
+
+	if err != nil {
+		rh.logger.ErrorContext(r.Context(), "getExerciseByID", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if exercise == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "exercise does not exist"})
+		return
+	}
+
+	rh.listRecords(w, r, exerciseID)
+}
+
+func (rh *RecordHandler) listRecords(w http.ResponseWriter, r *http.Request, exerciseID int64) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	recordType := r.URL.Query().Get("type")
+	if recordType != "" && !slices.Contains(store.RecordTypes, recordType) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("type must be one of %s", strings.Join(store.RecordTypes, ", "))})
+		return
+	}
+
+	page, err := utils.ReadIntQuery(r, "page")
+	if err != nil || (page != nil && *page < 1) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "page must be a positive number"})
+		return
+	}
+
+	limit, err := utils.ReadIntQuery(r, "limit")
+	if err != nil || (limit != nil && (*limit < 1 || *limit > maxRecordListLimit)) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("limit must be between 1 and %d", maxRecordListLimit)})
+		return
+	}
+
+	currentPage, pageSize := 1, defaultRecordListLimit
+	if page != nil {
+		currentPage = *page
+	}
+	if limit != nil {
+		pageSize = *limit
+	}
+
+	records, err := rh.recordStore.ListRecords(r.Context(), store.RecordFilter{
+		UserID:     currentUser.ID,
+		ExerciseID: exerciseID,
+		RecordType: recordType,
+		Limit:      pageSize,
+		Offset:     (currentPage - 1) * pageSize,
+	})
+
+	if err != nil {
+		rh.logger.ErrorContext(r.Context(), "listingRecords", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"records": records, "page": currentPage, "limit": pageSize})
+}
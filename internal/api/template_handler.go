@@ -0,0 +1,377 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+// struct used for null field checking when creating and updating templates
+type templateRequest struct {
+	Title           *string              `json:"title"`
+	Description     *string              `json:"description"`
+	DurationMinutes *int                 `json:"duration_minutes"`
+	CaloriesBurned  *int                 `json:"calories_burned"`
+	Entries         []store.WorkoutEntry `json:"entries"`
+}
+
+// changes applied to the copied entry with the same order index when a template is started
+type entryOverride struct {
+	OrderIndex      int      `json:"order_index"`
+	Sets            *int     `json:"sets"`
+	Reps            *int     `json:"reps"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	Weight          *float64 `json:"weight"`
+	Notes           *string  `json:"notes"`
+}
+
+type startTemplateRequest struct {
+	Title           *string         `json:"title"`
+	Description     *string         `json:"description"`
+	DurationMinutes *int            `json:"duration_minutes"`
+	CaloriesBurned  *int            `json:"calories_burned"`
+	Entries         []entryOverride `json:"entries"`
+}
+
+type TemplateHandler struct {
+	templateStore store.TemplateStore
+	workoutStore  store.WorkoutStore
+	logger        *slog.Logger
+}
+
+func NewTemplateHandler(templateStore store.TemplateStore, workoutStore store.WorkoutStore, logger *slog.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		templateStore: templateStore,
+		workoutStore:  workoutStore,
+		logger:        logger,
+	}
+}
+
+// applies the set fields of the request to the template and validates the result
+func (th *TemplateHandler) applyTemplateRequest(req *templateRequest, template *store.WorkoutTemplate) error {
+	if req.Title != nil {
+		template.Title = *req.Title
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	if req.DurationMinutes != nil {
+		template.DurationMinutes = *req.DurationMinutes
+	}
+	if req.CaloriesBurned != nil {
+		template.CaloriesBurned = *req.CaloriesBurned
+	}
+	//we don't use a pointer here because accessing an empty array gives a slice that is equal to nil
+	if req.Entries != nil {
+		template.Entries = req.Entries
+	}
+
+	if template.Title == "" {
+		return errors.New("title is required")
+	}
+
+	if template.DurationMinutes < 0 || template.CaloriesBurned < 0 {
+		return errors.New("duration_minutes and calories_burned cannot be negative")
+	}
+
+	for i := range template.Entries {
+		err := store.ValidateEntry(&template.Entries[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loads the template of the id param and checks that it belongs to the current user, the response is written on failure
+func (th *TemplateHandler) ownedTemplate(w http.ResponseWriter, r *http.Request) (*store.WorkoutTemplate, *store.User) {
+	templateID, err := utils.ReadIDParam(r)
+	if err != nil {
+		th.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return nil, nil
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return nil, nil
+	}
+
+	template, err := th.templateStore.GetTemplateByID(r.Context(), templateID)
+
+	if err != nil {
+		th.logger.ErrorContext(r.Context(), "getTemplateByID", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, nil
+	}
+
+	if template == nil {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "template does not exist"})
+		return nil, nil
+	}
+
+	if template.UserID != currentUser.ID {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized for this action"})
+		return nil, nil
+	}
+
+	return template, currentUser
+}
+
+func (th *TemplateHandler) HandleListTemplates(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	templates, err := th.templateStore.ListTemplates(r.Context(), currentUser.ID)
+
+	if err != nil {
+		th.logger.ErrorContext(r.Context(), "listingTemplates", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"templates": templates})
+}
+
+func (th *TemplateHandler) HandleGetTemplateById(w http.ResponseWriter, r *http.Request) {
+	template, _ := th.ownedTemplate(w, r)
+	if template == nil {
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req templateRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.logger.WarnContext(r.Context(), "decodingCreateTemplate", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	template := &store.WorkoutTemplate{UserID: currentUser.ID, Entries: []store.WorkoutEntry{}}
+
+	err = th.applyTemplateRequest(&req, template)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = th.templateStore.CreateTemplate(r.Context(), template)
+
+	if errors.Is(err, store.ErrUnknownExercise) || errors.Is(err, store.ErrExerciseRequired) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		th.logger.ErrorContext(r.Context(), "createTemplate", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleUpdateTemplateById(w http.ResponseWriter, r *http.Request) {
+	template, _ := th.ownedTemplate(w, r)
+	if template == nil {
+		return
+	}
+
+	var req templateRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		th.logger.WarnContext(r.Context(), "decodingUpdateTemplate", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	err = th.applyTemplateRequest(&req, template)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	err = th.templateStore.UpdateTemplate(r.Context(), template)
+
+	if errors.Is(err, store.ErrUnknownExercise) || errors.Is(err, store.ErrExerciseRequired) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "template does not exist"})
+		return
+	}
+
+	if err != nil {
+		th.logger.ErrorContext(r.Context(), "updatingTemplate", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleDeleteTemplateById(w http.ResponseWriter, r *http.Request) {
+	template, _ := th.ownedTemplate(w, r)
+	if template == nil {
+		return
+	}
+
+	err := th.templateStore.DeleteTemplate(r.Context(), int64(template.ID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "template does not exist"})
+		return
+	}
+
+	if errors.Is(err, store.ErrTemplateInUse) {
+		utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		th.logger.ErrorContext(r.Context(), "deletingTemplate", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"template": "deleted"})
+}
+
+// creates a workout for the current user from the template, the body with the overrides is optional
+func (th *TemplateHandler) HandleStartTemplate(w http.ResponseWriter, r *http.Request) {
+	template, currentUser := th.ownedTemplate(w, r)
+	if template == nil {
+		return
+	}
+
+	var req startTemplateRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		th.logger.WarnContext(r.Context(), "decodingStartTemplate", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	workout := template.NewWorkout(currentUser.ID)
+
+	err = applyStartOverrides(&req, workout)
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	createdWorkout, err := th.workoutStore.CreateWorkout(r.Context(), workout)
+
+	if errors.Is(err, store.ErrUnknownExercise) || errors.Is(err, store.ErrExerciseRequired) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		th.logger.ErrorContext(r.Context(), "startingTemplate", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	th.logger.InfoContext(r.Context(), "workout created from template", "workout_id", createdWorkout.ID, "template_id", template.ID)
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
+}
+
+// applies the overrides of the start request to the workout copied from a template
+func applyStartOverrides(req *startTemplateRequest, workout *store.Workout) error {
+	if req.Title != nil {
+		workout.Title = *req.Title
+	}
+	if req.Description != nil {
+		workout.Description = *req.Description
+	}
+	if req.DurationMinutes != nil {
+		workout.DurationMinutes = *req.DurationMinutes
+	}
+	if req.CaloriesBurned != nil {
+		workout.CaloriesBurned = *req.CaloriesBurned
+	}
+
+	for _, override := range req.Entries {
+		var entry *store.WorkoutEntry
+		for i := range workout.Entries {
+			if workout.Entries[i].OrderIndex == override.OrderIndex {
+				entry = &workout.Entries[i]
+				break
+			}
+		}
+
+		if entry == nil {
+			return fmt.Errorf("the template has no entry with order_index %d", override.OrderIndex)
+		}
+
+		if override.Reps != nil && override.DurationSeconds != nil {
+			return errors.New("an entry cannot have both reps and duration_seconds")
+		}
+
+		if override.Sets != nil && *override.Sets < 1 {
+			return errors.New("every entry needs at least one set")
+		}
+
+		if override.Sets != nil {
+			entry.Sets = *override.Sets
+		}
+		//reps and duration exclude each other outside of cardio entries so setting one clears the other and the type is inferred again,
+		//a cardio entry keeps its type when only its duration changes
+		if override.Reps != nil {
+			entry.Reps, entry.DurationSeconds, entry.EntryType = override.Reps, nil, ""
+		}
+		if override.DurationSeconds != nil {
+			entry.DurationSeconds = override.DurationSeconds
+			if entry.EntryType != store.EntryCardio {
+				entry.Reps, entry.EntryType = nil, ""
+			}
+		}
+		if override.Weight != nil {
+			entry.Weight = override.Weight
+		}
+		if override.Notes != nil {
+			entry.Notes = *override.Notes
+		}
+	}
+
+	for i := range workout.Entries {
+		err := store.ValidateEntry(&workout.Entries[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
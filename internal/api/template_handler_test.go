@@ -0,0 +1,110 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/store"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func TestApplyStartOverrides(t *testing.T) {
+	template := &store.WorkoutTemplate{
+		Title:           "push day",
+		DurationMinutes: 60,
+		Entries: []store.WorkoutEntry{
+			{ID: 7, ExerciseID: 1, ExerciseName: "Bench Press", Sets: 3, Reps: intPtr(5), Weight: floatPtr(100), OrderIndex: 1},
+			{ID: 8, ExerciseID: 27, ExerciseName: "Plank", Sets: 1, DurationSeconds: intPtr(60), OrderIndex: 2},
+			{ID: 9, ExerciseID: 40, ExerciseName: "Rowing", EntryType: store.EntryCardio, Sets: 1, DurationSeconds: intPtr(600), OrderIndex: 3},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		req     startTemplateRequest
+		check   func(t *testing.T, workout *store.Workout)
+		wantErr string
+	}{
+		{
+			name: "no overrides copies the template",
+			check: func(t *testing.T, workout *store.Workout) {
+				assert.Equal(t, "push day", workout.Title)
+				assert.Equal(t, 42, workout.UserID)
+				require.Len(t, workout.Entries, 3)
+				assert.Zero(t, workout.Entries[0].ID)
+				assert.Equal(t, int64(1), workout.Entries[0].ExerciseID)
+			},
+		},
+		{
+			name: "overrides fields and entries",
+			req: startTemplateRequest{
+				Title:   stringPtr("heavy push day"),
+				Entries: []entryOverride{{OrderIndex: 1, Weight: floatPtr(105), Reps: intPtr(3)}, {OrderIndex: 2, Notes: stringPtr("hollow body")}},
+			},
+			check: func(t *testing.T, workout *store.Workout) {
+				assert.Equal(t, "heavy push day", workout.Title)
+				assert.Equal(t, 105.0, *workout.Entries[0].Weight)
+				assert.Equal(t, 3, *workout.Entries[0].Reps)
+				assert.Equal(t, "hollow body", workout.Entries[1].Notes)
+			},
+		},
+		{
+			name: "duration of a cardio entry without a distance",
+			req:  startTemplateRequest{Entries: []entryOverride{{OrderIndex: 3, DurationSeconds: intPtr(900)}}},
+			check: func(t *testing.T, workout *store.Workout) {
+				assert.Equal(t, store.EntryCardio, workout.Entries[2].EntryType)
+				assert.Equal(t, 900, *workout.Entries[2].DurationSeconds)
+			},
+		},
+		{
+			name: "duration of a strength entry makes it timed",
+			req:  startTemplateRequest{Entries: []entryOverride{{OrderIndex: 1, DurationSeconds: intPtr(45)}}},
+			check: func(t *testing.T, workout *store.Workout) {
+				assert.Equal(t, store.EntryTimed, workout.Entries[0].EntryType)
+				assert.Nil(t, workout.Entries[0].Reps)
+			},
+		},
+		{
+			name:    "unknown entry",
+			req:     startTemplateRequest{Entries: []entryOverride{{OrderIndex: 9, Sets: intPtr(4)}}},
+			wantErr: "the template has no entry with order_index 9",
+		},
+		{
+			name:    "reps and duration",
+			req:     startTemplateRequest{Entries: []entryOverride{{OrderIndex: 2, Reps: intPtr(4), DurationSeconds: intPtr(30)}}},
+			wantErr: "an entry cannot have both reps and duration_seconds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workout := template.NewWorkout(42)
+
+			err := applyStartOverrides(&tt.req, workout)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			tt.check(t, workout)
+
+			//the template itself is never changed
+			assert.Equal(t, 100.0, *template.Entries[0].Weight)
+			assert.Equal(t, 7, template.Entries[0].ID)
+		})
+	}
+}
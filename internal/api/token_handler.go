@@ -1,20 +1,27 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/zidariu-sabin/femProject/internal/metrics"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
 	"github.com/zidariu-sabin/femProject/internal/store"
 	"github.com/zidariu-sabin/femProject/internal/tokens"
 	"github.com/zidariu-sabin/femProject/internal/utils"
 )
 
 type TokenHandler struct {
-	tokenStore store.TokenStore
-	userStore  store.UserStore
-	logger     *log.Logger
+	tokenStore      store.TokenStore
+	userStore       store.UserStore
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	metrics         *metrics.Metrics
+	logger          *slog.Logger
 }
 
 type createTokenRequest struct {
@@ -22,11 +29,18 @@ type createTokenRequest struct {
 	Password string `json:"password"`
 }
 
-func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, logger *log.Logger) *TokenHandler {
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, accessTokenTTL, refreshTokenTTL time.Duration, m *metrics.Metrics, logger *slog.Logger) *TokenHandler {
 	return &TokenHandler{
-		tokenStore: tokenStore,
-		userStore:  userStore,
-		logger:     logger,
+		tokenStore:      tokenStore,
+		userStore:       userStore,
+		accessTokenTTL:  accessTokenTTL,
+		refreshTokenTTL: refreshTokenTTL,
+		metrics:         m,
+		logger:          logger,
 	}
 }
 
@@ -37,40 +51,134 @@ func (th *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request
 	err := json.NewDecoder(r.Body).Decode(&req)
 
 	if err != nil {
-		th.logger.Printf("ERROR: handleCreateToken: %v ", err)
+		th.logger.WarnContext(r.Context(), "handleCreateToken", "error", err)
 		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
 	}
 
 	//get user details
-	user, err := th.userStore.GetUserByUsername(req.Username)
+	user, err := th.userStore.GetUserByUsername(r.Context(), req.Username)
 
-	if err != nil || user == nil {
-		th.logger.Printf("ERROR: GetByUsername: %v", err)
+	if err != nil {
+		th.logger.ErrorContext(r.Context(), "GetByUsername", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
 
+	if user == nil {
+		th.metrics.AuthenticationFailed("invalid_credentials")
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid credentials"})
+		return
+	}
+
 	//validate password
 	passwordDoMatches, err := user.PasswordHash.Matches(req.Password)
 
 	if err != nil {
-		th.logger.Printf("ERROR: PasswordHash.Matches: %v", err)
+		th.logger.ErrorContext(r.Context(), "PasswordHash.Matches", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
 
 	if !passwordDoMatches {
+		th.metrics.AuthenticationFailed("invalid_credentials")
 		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid credentials"})
 		return
 	}
 
-	token, err := th.tokenStore.CreateNewToken(user.ID, 24*time.Hour, tokens.ScopeAuth)
+	pair, err := th.tokenStore.CreateTokenPair(r.Context(), user.ID, th.accessTokenTTL, th.refreshTokenTTL, middleware.GetClientInfo(r))
+
+	if err != nil {
+		th.logger.ErrorContext(r.Context(), "creatingToken", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"auth_token": pair.Access, "refresh_token": pair.Refresh})
+}
+
+// exchanges a refresh token for a new access and refresh token, every refresh token can only be used once
+func (th *TokenHandler) HandleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req refreshTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+
+	if err != nil || req.RefreshToken == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
+		return
+	}
+
+	pair, err := th.tokenStore.RotateRefreshToken(r.Context(), req.RefreshToken, th.accessTokenTTL, th.refreshTokenTTL, middleware.GetClientInfo(r))
+
+	if errors.Is(err, store.ErrTokenReused) {
+		th.metrics.AuthenticationFailed("refresh_token_reused")
+		th.logger.WarnContext(r.Context(), "refresh token reuse detected, token family revoked")
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "refresh token already used, please log in again"})
+		return
+	}
+
+	if errors.Is(err, store.ErrInvalidToken) {
+		th.metrics.AuthenticationFailed("invalid_refresh_token")
+		utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid or expired refresh token"})
+		return
+	}
+
+	if err != nil {
+		th.logger.ErrorContext(r.Context(), "rotatingRefreshToken", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"auth_token": pair.Access, "refresh_token": pair.Refresh})
+}
+
+// logs out the session of the token used for the request
+func (th *TokenHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	err := th.tokenStore.DeleteTokenFamily(r.Context(), currentUser.ID, middleware.GetToken(r))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "session does not exist"})
+		return
+	}
+
+	if err != nil {
+		th.logger.ErrorContext(r.Context(), "deletingTokenFamily", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"session": "logged out"})
+}
+
+// logs out every session of the current user
+func (th *TokenHandler) HandleLogoutAll(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	for _, scope := range []string{tokens.ScopeAuth, tokens.ScopeRefresh} {
+		err := th.tokenStore.DeleteAllTokensForUser(r.Context(), currentUser.ID, scope)
+
+		if err != nil {
+			th.logger.ErrorContext(r.Context(), "deletingAllTokensForUser", "error", err)
+			utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"sessions": "logged out"})
+}
+
+func (th *TokenHandler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	sessions, err := th.tokenStore.ListSessions(r.Context(), currentUser.ID, middleware.GetToken(r))
 
 	if err != nil {
-		th.logger.Printf("ERROR: creatingToken: %v", err)
+		th.logger.ErrorContext(r.Context(), "listingSessions", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
 
-	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"auth_token": token})
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"sessions": sessions})
 }
@@ -1,13 +1,19 @@
 package api
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"regexp"
+	"strings"
 
+	"github.com/zidariu-sabin/femProject/internal/mailer"
 	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tokens"
 	"github.com/zidariu-sabin/femProject/internal/utils"
 )
 
@@ -19,15 +25,29 @@ type registerUserRequest struct {
 	Bio      string `json:"bio"`
 }
 
+type activateUserRequest struct {
+	Token string `json:"token"`
+}
+
+type activationTokenRequest struct {
+	Email string `json:"email"`
+}
+
 type UserHandler struct {
-	userStore store.UserStore
-	logger    *log.Logger
+	userStore  store.UserStore
+	tokenStore store.TokenStore
+	mailer     mailer.Mailer
+	bcryptCost int
+	logger     *slog.Logger
 }
 
-func NewUserHandler(userStore store.UserStore, logger *log.Logger) *UserHandler {
+func NewUserHandler(userStore store.UserStore, tokenStore store.TokenStore, m mailer.Mailer, bcryptCost int, logger *slog.Logger) *UserHandler {
 	return &UserHandler{
-		userStore: userStore,
-		logger:    logger,
+		userStore:  userStore,
+		tokenStore: tokenStore,
+		mailer:     m,
+		bcryptCost: bcryptCost,
+		logger:     logger,
 	}
 }
 
@@ -59,7 +79,7 @@ func (uh *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request
 	err := json.NewDecoder(r.Body).Decode(&req)
 
 	if err != nil {
-		uh.logger.Printf("ERROR: decodingRegisterRequest: %v", err)
+		uh.logger.WarnContext(r.Context(), "decodingRegisterRequest", "error", err)
 		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
 		return
 	}
@@ -81,40 +101,129 @@ func (uh *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request
 	}
 
 	//password logic
-	err = user.PasswordHash.Set(req.Password)
+	err = user.PasswordHash.SetWithCost(req.Password, uh.bcryptCost)
 
 	if err != nil {
-		uh.logger.Printf("ERROR: hashingPassword: %v", err)
+		uh.logger.ErrorContext(r.Context(), "hashingPassword", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
 
-	err = uh.userStore.CreateUser(user)
+	err = uh.userStore.CreateUser(r.Context(), user)
 
 	if err != nil {
-		uh.logger.Printf("ERROR: registeringUser: %v", err)
+		uh.logger.ErrorContext(r.Context(), "registeringUser", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
 
+	//the account exists either way, a failed email can be sent again through POST /tokens/activation
+	err = uh.sendActivationToken(r.Context(), user)
+	if err != nil {
+		uh.logger.ErrorContext(r.Context(), "sendingActivationToken", "error", err)
+	}
+
 	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"user": user})
 }
 
+// replaces the activation tokens of the user with a new one and emails it, the mailer sends in the background so
+// neither registration nor POST /tokens/activation wait for the delivery
+func (uh *UserHandler) sendActivationToken(ctx context.Context, user *store.User) error {
+	err := uh.tokenStore.DeleteAllTokensForUser(ctx, user.ID, tokens.ScopeActivation)
+	if err != nil {
+		return err
+	}
+
+	token, err := uh.tokenStore.CreateNewToken(ctx, user.ID, tokens.ActivationTTL, tokens.ScopeActivation)
+	if err != nil {
+		return err
+	}
+
+	return uh.mailer.Send(ctx, mailer.ActivationMessage(user.Email, user.Username, token.PlainText, tokens.ActivationTTL))
+}
+
+// verifies the email of the user with the token of the activation email
+func (uh *UserHandler) HandleActivateUser(w http.ResponseWriter, r *http.Request) {
+	var req activateUserRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Token == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "the activation token is required"})
+		return
+	}
+
+	user, err := uh.userStore.GetUserToken(r.Context(), tokens.ScopeActivation, req.Token)
+	if err != nil {
+		uh.logger.ErrorContext(r.Context(), "getUserToken", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if user == nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid or expired activation token"})
+		return
+	}
+
+	err = uh.userStore.ActivateUser(r.Context(), user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "user does not exist"})
+		return
+	}
+
+	if err != nil {
+		uh.logger.ErrorContext(r.Context(), "activatingUser", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	user.Activated = true
+
+	uh.logger.InfoContext(r.Context(), "user activated", "user_id", user.ID)
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"user": user})
+}
+
+// sends a new activation email, like the password reset the answer does not tell whether the email is registered
+func (uh *UserHandler) HandleCreateActivationToken(w http.ResponseWriter, r *http.Request) {
+	var req activationTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil || req.Email == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "email is required"})
+		return
+	}
+
+	user, err := uh.userStore.GetUserByEmail(r.Context(), req.Email)
+	if err != nil {
+		uh.logger.ErrorContext(r.Context(), "getUserByEmail", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if user != nil && !user.Activated {
+		err = uh.sendActivationToken(r.Context(), user)
+		if err != nil {
+			uh.logger.ErrorContext(r.Context(), "sendingActivationToken", "error", err)
+		}
+	}
+
+	utils.WriteJson(w, http.StatusAccepted, utils.Envelope{"message": "if the email belongs to an account waiting for activation, an activation token was sent to it"})
+}
+
 func (uh *UserHandler) HandleGetUserByUsername(w http.ResponseWriter, r *http.Request) {
 	var req registerUserRequest
 
 	err := json.NewDecoder(r.Body).Decode(&req)
 
 	if err != nil {
-		uh.logger.Printf("ERROR: decodingGetUserByUsernameRequest: %v", err)
+		uh.logger.WarnContext(r.Context(), "decodingGetUserByUsernameRequest", "error", err)
 		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
 		return
 	}
 
-	user, err := uh.userStore.GetUserByUsername(req.Username)
+	user, err := uh.userStore.GetUserByUsername(r.Context(), req.Username)
 
 	if err != nil {
-		uh.logger.Printf("ERROR: gettingUserByUsername: %v", err)
+		uh.logger.ErrorContext(r.Context(), "gettingUserByUsername", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
@@ -126,6 +235,51 @@ func (uh *UserHandler) HandleGetUserByUsername(w http.ResponseWriter, r *http.Re
 	utils.WriteJson(w, http.StatusOK, utils.Envelope{"user": user})
 }
 
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
 func (uh *UserHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	if query == "" {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "search query is required"})
+		return
+	}
+
+	if len(query) > 50 {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "search query cannot be greater than 50 characters"})
+		return
+	}
+
+	page, err := utils.ReadIntQuery(r, "page")
+	if err != nil || (page != nil && *page < 1) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "page must be a positive number"})
+		return
+	}
+
+	limit, err := utils.ReadIntQuery(r, "limit")
+	if err != nil || (limit != nil && (*limit < 1 || *limit > maxSearchLimit)) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("limit must be between 1 and %d", maxSearchLimit)})
+		return
+	}
+
+	currentPage, pageSize := 1, defaultSearchLimit
+	if page != nil {
+		currentPage = *page
+	}
+	if limit != nil {
+		pageSize = *limit
+	}
+
+	users, err := uh.userStore.SearchUsers(r.Context(), query, pageSize, (currentPage-1)*pageSize)
+
+	if err != nil {
+		uh.logger.ErrorContext(r.Context(), "searchingUsers", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
 
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"users": users, "page": currentPage, "limit": pageSize})
 }
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/mailer"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tokens"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// user store keeping a single registered user and whether it was activated
+type activationUserStore struct {
+	emailUserStore
+	activated int
+}
+
+func (s *activationUserStore) CreateUser(ctx context.Context, user *store.User) error {
+	user.ID = 7
+	s.user = user
+	return nil
+}
+
+func (s *activationUserStore) ActivateUser(ctx context.Context, userID int) error {
+	s.activated = userID
+	return nil
+}
+
+func newActivationHandler(userStore *activationUserStore, tokenStore *emailTokenStore, m mailer.Mailer) *UserHandler {
+	return NewUserHandler(userStore, tokenStore, m, bcrypt.MinCost, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestHandleRegisterUserSendsActivationToken(t *testing.T) {
+	userStore := &activationUserStore{emailUserStore: emailUserStore{scope: tokens.ScopeActivation}}
+	tokenStore := &emailTokenStore{}
+	m := mailer.NewMemoryMailer()
+	handler := newActivationHandler(userStore, tokenStore, m)
+
+	body := `{"username":"sabin","email":"sabin@example.com","password":"correct horse"}`
+	req := httptest.NewRequest(http.MethodPost, "/user", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	handler.HandleRegisterUser(rr, req)
+
+	require.Equal(t, http.StatusCreated, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"activated": false`)
+	assert.NotContains(t, rr.Body.String(), "MAILEDTOKEN")
+
+	require.Len(t, tokenStore.created, 1)
+	assert.Equal(t, tokens.ScopeActivation, tokenStore.created[0].Scope)
+	assert.Equal(t, 7, tokenStore.created[0].UserID)
+
+	messages := m.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "sabin@example.com", messages[0].To)
+	assert.Contains(t, messages[0].Body, "MAILEDTOKEN")
+}
+
+func TestHandleActivateUser(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantStatus    int
+		wantActivated bool
+	}{
+		{name: "missing token", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "invalid token", body: `{"token":"GUESSED"}`, wantStatus: http.StatusBadRequest},
+		{name: "user activated", body: `{"token":"MAILEDTOKEN"}`, wantStatus: http.StatusOK, wantActivated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userStore := &activationUserStore{emailUserStore: emailUserStore{user: &store.User{ID: 7, Username: "sabin"}, scope: tokens.ScopeActivation}}
+			handler := newActivationHandler(userStore, &emailTokenStore{}, mailer.NewMemoryMailer())
+
+			req := httptest.NewRequest(http.MethodPut, "/users/activated", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.HandleActivateUser(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.Equal(t, tt.wantActivated, userStore.activated == 7)
+
+			if tt.wantActivated {
+				assert.Contains(t, rr.Body.String(), `"activated": true`)
+			}
+		})
+	}
+}
+
+func TestHandleCreateActivationToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		activated  bool
+		wantStatus int
+		wantEmail  bool
+	}{
+		{name: "unknown email", email: "nobody@example.com", wantStatus: http.StatusAccepted},
+		{name: "already activated", email: "sabin@example.com", activated: true, wantStatus: http.StatusAccepted},
+		{name: "waiting for activation", email: "sabin@example.com", wantStatus: http.StatusAccepted, wantEmail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &store.User{ID: 7, Username: "sabin", Email: "sabin@example.com", Activated: tt.activated}
+			userStore := &activationUserStore{emailUserStore: emailUserStore{user: user, scope: tokens.ScopeActivation}}
+			tokenStore := &emailTokenStore{}
+			m := mailer.NewMemoryMailer()
+			handler := newActivationHandler(userStore, tokenStore, m)
+
+			req := httptest.NewRequest(http.MethodPost, "/tokens/activation", strings.NewReader(`{"email":"`+tt.email+`"}`))
+			rr := httptest.NewRecorder()
+
+			handler.HandleCreateActivationToken(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+			assert.Equal(t, tt.wantEmail, len(m.Messages()) == 1)
+
+			if tt.wantEmail {
+				//the previous activation tokens stop working
+				assert.Equal(t, []string{tokens.ScopeActivation}, tokenStore.deletedScopes)
+			}
+		})
+	}
+}
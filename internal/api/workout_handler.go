@@ -4,8 +4,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/zidariu-sabin/femProject/internal/middleware"
 	"github.com/zidariu-sabin/femProject/internal/store"
@@ -14,11 +18,11 @@ import (
 
 type WorkoutHandler struct {
 	workoutStore store.WorkoutStore
-	logger       *log.Logger
+	logger       *slog.Logger
 }
 
 // workout handler constructor
-func NewWorkoutHandler(workoutStore store.WorkoutStore, logger *log.Logger) *WorkoutHandler {
+func NewWorkoutHandler(workoutStore store.WorkoutStore, logger *slog.Logger) *WorkoutHandler {
 	return &WorkoutHandler{
 		workoutStore: workoutStore,
 		logger:       logger,
@@ -29,15 +33,15 @@ func (wh *WorkoutHandler) HandleGetWorkoutById(w http.ResponseWriter, r *http.Re
 	workoutID, err := utils.ReadIDParam(r)
 
 	if err != nil {
-		wh.logger.Printf("ERROR: readIDParam: %v", err)
+		wh.logger.WarnContext(r.Context(), "readIDParam", "error", err)
 		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
 		return
 	}
 
-	workout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	workout, err := wh.workoutStore.GetWorkoutByID(r.Context(), workoutID)
 
 	if err != nil {
-		wh.logger.Printf("ERROR: workoutstore.GetWorkoutByID: %v", err)
+		wh.logger.ErrorContext(r.Context(), "workoutstore.GetWorkoutByID", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server errors "})
 		return
 	}
@@ -45,7 +49,7 @@ func (wh *WorkoutHandler) HandleGetWorkoutById(w http.ResponseWriter, r *http.Re
 	err = utils.WriteJson(w, http.StatusOK, utils.Envelope{"workout": workout})
 
 	if err != nil {
-		wh.logger.Printf("ERROR: formattingJsonData: %v", err)
+		wh.logger.ErrorContext(r.Context(), "formattingJsonData", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 	}
 }
@@ -55,7 +59,7 @@ func (wh *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Req
 	//decoding request data to a struct from json format using defined json tags in store
 	err := json.NewDecoder(r.Body).Decode(&workout)
 	if err != nil {
-		wh.logger.Printf("ERROR: decodingCreateWorkout: %v", err)
+		wh.logger.WarnContext(r.Context(), "decodingCreateWorkout", "error", err)
 		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
 		return
 	}
@@ -69,18 +73,36 @@ func (wh *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Req
 
 	workout.UserID = currentUser.ID
 
-	createdWorkout, err := wh.workoutStore.CreateWorkout(&workout)
+	if workout.Status != "" && !slices.Contains(store.WorkoutStatuses, workout.Status) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "status must be one of " + strings.Join(store.WorkoutStatuses, ", ")})
+		return
+	}
+
+	for i := range workout.Entries {
+		err = store.ValidateEntry(&workout.Entries[i])
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+
+	createdWorkout, err := wh.workoutStore.CreateWorkout(r.Context(), &workout)
+
+	if errors.Is(err, store.ErrUnknownExercise) || errors.Is(err, store.ErrExerciseRequired) || errors.Is(err, store.ErrInvalidProgramDay) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
 
 	if err != nil {
-		wh.logger.Printf("ERROR: createWorkout: %v", err)
+		wh.logger.ErrorContext(r.Context(), "createWorkout", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
 
-	wh.logger.Printf(" createWorkout: %v", createdWorkout)
+	wh.logger.InfoContext(r.Context(), "workout created", "workout_id", createdWorkout.ID)
 	err = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
 	if err != nil {
-		wh.logger.Printf("ERROR: formattingJsonData: %v", err)
+		wh.logger.ErrorContext(r.Context(), "formattingJsonData", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
@@ -90,15 +112,15 @@ func (wh *WorkoutHandler) HandleCreateWorkout(w http.ResponseWriter, r *http.Req
 func (wh *WorkoutHandler) HandleUpdateWorkoutById(w http.ResponseWriter, r *http.Request) {
 	workoutID, err := utils.ReadIDParam(r)
 	if err != nil {
-		wh.logger.Printf("ERROR: readIdParam: %v", err)
+		wh.logger.WarnContext(r.Context(), "readIdParam", "error", err)
 		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
 		return
 	}
 
-	existingWorkout, err := wh.workoutStore.GetWorkoutByID(workoutID)
+	existingWorkout, err := wh.workoutStore.GetWorkoutByID(r.Context(), workoutID)
 
 	if err != nil {
-		wh.logger.Printf("ERROR: getWorkoutId: %v", err)
+		wh.logger.ErrorContext(r.Context(), "getWorkoutId", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
@@ -114,13 +136,15 @@ func (wh *WorkoutHandler) HandleUpdateWorkoutById(w http.ResponseWriter, r *http
 		Description     *string              `json:"description"`
 		DurationMinutes *int                 `json:"duration_minutes"`
 		CaloriesBurned  *int                 `json:"calories_burned"`
+		PerformedAt     *time.Time           `json:"performed_at"`
+		Status          *string              `json:"status"`
 		Entries         []store.WorkoutEntry `json:"entries"`
 	}
 
 	err = json.NewDecoder(r.Body).Decode(&updateWorkoutRequest)
 
 	if err != nil {
-		wh.logger.Printf("ERROR: decodingUpdateRequest: %v", err)
+		wh.logger.WarnContext(r.Context(), "decodingUpdateRequest", "error", err)
 		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request"})
 		return
 	}
@@ -138,11 +162,29 @@ func (wh *WorkoutHandler) HandleUpdateWorkoutById(w http.ResponseWriter, r *http
 	if updateWorkoutRequest.CaloriesBurned != nil {
 		existingWorkout.CaloriesBurned = *updateWorkoutRequest.CaloriesBurned
 	}
+	if updateWorkoutRequest.PerformedAt != nil {
+		existingWorkout.PerformedAt = *updateWorkoutRequest.PerformedAt
+	}
+	if updateWorkoutRequest.Status != nil {
+		if !slices.Contains(store.WorkoutStatuses, *updateWorkoutRequest.Status) {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "status must be one of " + strings.Join(store.WorkoutStatuses, ", ")})
+			return
+		}
+		existingWorkout.Status = *updateWorkoutRequest.Status
+	}
 	//we don't use a pointer here because accessing an empty array gives a slice that is equal to nil
 	if updateWorkoutRequest.Entries != nil {
 		existingWorkout.Entries = updateWorkoutRequest.Entries
 	}
 
+	for i := range existingWorkout.Entries {
+		err = store.ValidateEntry(&existingWorkout.Entries[i])
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+
 	currentUser := middleware.GetUser(r)
 
 	if currentUser == nil || currentUser == store.AnonymousUser {
@@ -150,7 +192,7 @@ func (wh *WorkoutHandler) HandleUpdateWorkoutById(w http.ResponseWriter, r *http
 		return
 	}
 
-	workoutOwnerID, err := wh.workoutStore.GetWorkoutOwner(workoutID)
+	workoutOwnerID, err := wh.workoutStore.GetWorkoutOwner(r.Context(), workoutID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "workout does not exist"})
@@ -164,17 +206,22 @@ func (wh *WorkoutHandler) HandleUpdateWorkoutById(w http.ResponseWriter, r *http
 		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized for this action"})
 	}
 
-	err = wh.workoutStore.UpdateWorkout(existingWorkout)
+	err = wh.workoutStore.UpdateWorkout(r.Context(), existingWorkout)
+
+	if errors.Is(err, store.ErrUnknownExercise) || errors.Is(err, store.ErrExerciseRequired) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
 
 	if err != nil {
-		wh.logger.Printf("ERROR: updatingWorkout: %v", err)
+		wh.logger.ErrorContext(r.Context(), "updatingWorkout", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
 
 	err = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"workout": existingWorkout})
 	if err != nil {
-		wh.logger.Printf("ERROR: formattingJsonData: %v", err)
+		wh.logger.ErrorContext(r.Context(), "formattingJsonData", "error", err)
 		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
 		return
 	}
@@ -183,7 +230,7 @@ func (wh *WorkoutHandler) HandleUpdateWorkoutById(w http.ResponseWriter, r *http
 func (wh *WorkoutHandler) HandleDeleteWorkoutById(w http.ResponseWriter, r *http.Request) {
 	workoutID, err := utils.ReadIDParam(r)
 	if err != nil {
-		wh.logger.Printf("ERROR: readIdParam: %v", err)
+		wh.logger.WarnContext(r.Context(), "readIdParam", "error", err)
 		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
 		return
 	}
@@ -195,7 +242,7 @@ func (wh *WorkoutHandler) HandleDeleteWorkoutById(w http.ResponseWriter, r *http
 		return
 	}
 
-	workoutOwnerID, err := wh.workoutStore.GetWorkoutOwner(workoutID)
+	workoutOwnerID, err := wh.workoutStore.GetWorkoutOwner(r.Context(), workoutID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "workout does not exist"})
@@ -209,7 +256,7 @@ func (wh *WorkoutHandler) HandleDeleteWorkoutById(w http.ResponseWriter, r *http
 		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized for this action"})
 	}
 
-	err = wh.workoutStore.DeleteWorkout(workoutID)
+	err = wh.workoutStore.DeleteWorkout(r.Context(), workoutID)
 
 	if err == sql.ErrNoRows {
 		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout does not exist"})
@@ -223,3 +270,81 @@ func (wh *WorkoutHandler) HandleDeleteWorkoutById(w http.ResponseWriter, r *http
 
 	utils.WriteJson(w, http.StatusOK, utils.Envelope{"workout": "deleted"})
 }
+
+func (wh *WorkoutHandler) HandleListWorkouts(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	query := r.URL.Query()
+
+	filter := store.WorkoutFilter{
+		UserID: currentUser.ID,
+		Title:  query.Get("title"),
+		Status: query.Get("status"),
+		Sort:   query.Get("sort"),
+		Cursor: query.Get("cursor"),
+	}
+
+	if filter.Status != "" && !slices.Contains(store.WorkoutStatuses, filter.Status) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "status must be one of " + strings.Join(store.WorkoutStatuses, ", ")})
+		return
+	}
+
+	var err error
+	//every optional parameter is parsed the same way, a slice keeps the order so the first invalid one is always reported
+	for _, param := range []struct {
+		key    string
+		target **int
+	}{
+		{"min_duration", &filter.MinDuration},
+		{"max_duration", &filter.MaxDuration},
+		{"min_calories", &filter.MinCalories},
+		{"max_calories", &filter.MaxCalories},
+	} {
+		*param.target, err = utils.ReadIntQuery(r, param.key)
+		if err != nil {
+			utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+
+	filter.From, err = utils.ReadTimeQuery(r, "from")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	filter.To, err = utils.ReadTimeQuery(r, "to")
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	limit, err := utils.ReadIntQuery(r, "limit")
+	if err != nil || (limit != nil && (*limit < 1 || *limit > store.MaxWorkoutListLimit)) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("limit must be between 1 and %d", store.MaxWorkoutListLimit)})
+		return
+	}
+	if limit != nil {
+		filter.Limit = *limit
+	}
+
+	workouts, nextCursor, err := wh.workoutStore.ListWorkouts(r.Context(), filter)
+
+	if errors.Is(err, store.ErrInvalidCursor) || errors.Is(err, store.ErrInvalidSort) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		wh.logger.ErrorContext(r.Context(), "listWorkouts", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{"workouts": workouts, "next_cursor": nextCursor})
+}
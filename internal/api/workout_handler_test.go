@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+)
+
+// workout store whose queries only return once the context of the call is done
+type blockingWorkoutStore struct {
+	store.WorkoutStore
+	started chan struct{}
+	err     chan error
+}
+
+func (s *blockingWorkoutStore) ListWorkouts(ctx context.Context, filter store.WorkoutFilter) ([]*store.Workout, string, error) {
+	close(s.started)
+	<-ctx.Done()
+	s.err <- ctx.Err()
+	return nil, "", ctx.Err()
+}
+
+func TestCancelledRequestAbortsStoreCall(t *testing.T) {
+	workoutStore := &blockingWorkoutStore{started: make(chan struct{}), err: make(chan error, 1)}
+	handler := NewWorkoutHandler(workoutStore, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/workouts", nil).WithContext(ctx)
+	req = middleware.SetUser(req, &store.User{ID: 1})
+
+	done := make(chan struct{})
+	go func() {
+		handler.HandleListWorkouts(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	<-workoutStore.started
+	cancel()
+
+	select {
+	case err := <-workoutStore.err:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("store call was not aborted")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler did not return")
+	}
+}
+
+func TestListWorkoutsReportsFirstInvalidParameter(t *testing.T) {
+	handler := NewWorkoutHandler(&blockingWorkoutStore{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	//the same parameter is reported on every run
+	for range 20 {
+		req := httptest.NewRequest(http.MethodGet, "/workouts?max_calories=a&min_duration=b&max_duration=c", nil)
+		req = middleware.SetUser(req, &store.User{ID: 1})
+		rr := httptest.NewRecorder()
+
+		handler.HandleListWorkouts(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "invalid min_duration parameter")
+	}
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tracks"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+// largest gpx or tcx file accepted by the import, a few hours of one second recording fit easily
+const maxImportBytes = 20 << 20
+
+// exercises used for the cardio entry of an imported track when the request does not name one
+var sportExercises = map[string]string{
+	tracks.SportRunning: "Running",
+	tracks.SportCycling: "Cycling",
+	tracks.SportOther:   "Running",
+}
+
+// imports a gpx or tcx file sent either as the "file" field of a multipart form or as the raw request body,
+// the title and exercise of the workout can be given as form fields or query parameters
+func (wh *WorkoutHandler) HandleImportWorkout(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var track *tracks.Track
+
+	file, err := uploadedFile(r)
+	if err == nil {
+		defer file.Close()
+		track, err = tracks.Parse(file)
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		utils.WriteJson(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "the file is larger than 20MB"})
+		return
+	}
+
+	if err != nil {
+		wh.logger.WarnContext(r.Context(), "parsingTrack", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid track file: " + err.Error()})
+		return
+	}
+
+	workout := importedWorkout(track, r.FormValue("title"), r.FormValue("exercise"))
+	workout.UserID = currentUser.ID
+
+	err = store.ValidateEntry(&workout.Entries[0])
+	if err != nil {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	createdWorkout, err := wh.workoutStore.CreateWorkout(r.Context(), workout)
+
+	if errors.Is(err, store.ErrUnknownExercise) || errors.Is(err, store.ErrExerciseRequired) {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	if err != nil {
+		wh.logger.ErrorContext(r.Context(), "importWorkout", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	wh.logger.InfoContext(r.Context(), "workout imported", "workout_id", createdWorkout.ID, "route_points", len(createdWorkout.Route))
+	utils.WriteJson(w, http.StatusCreated, utils.Envelope{"workout": createdWorkout})
+}
+
+// the file of an upload, sent either as the "file" field of a multipart form or as the raw request body
+func uploadedFile(r *http.Request) (io.ReadCloser, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.Body, nil
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// builds a completed workout with a single cardio entry summarizing the track, only points with a position are kept for the route
+func importedWorkout(track *tracks.Track, title, exercise string) *store.Workout {
+	summary := track.Summary()
+
+	exercise = strings.TrimSpace(exercise)
+	if exercise == "" {
+		exercise = sportExercises[track.Sport]
+	}
+
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = strings.TrimSpace(track.Name)
+	}
+	if title == "" {
+		title = exercise
+	}
+
+	entry := store.WorkoutEntry{
+		ExerciseName: exercise,
+		EntryType:    store.EntryCardio,
+		Sets:         1,
+		OrderIndex:   1,
+		AvgHeartRate: summary.AvgHeartRate,
+		MaxHeartRate: summary.MaxHeartRate,
+	}
+
+	if summary.DistanceMeters > 0 {
+		distance, unit := math.Round(summary.DistanceMeters)/1000, "km"
+		entry.Distance, entry.DistanceUnit = &distance, &unit
+	}
+
+	if summary.DurationSeconds > 0 {
+		entry.DurationSeconds = &summary.DurationSeconds
+	}
+
+	if summary.ElevationGainMeters > 0 {
+		elevationGain := math.Round(summary.ElevationGainMeters*10) / 10
+		entry.ElevationGainMeters = &elevationGain
+	}
+
+	workout := &store.Workout{
+		Title:           title,
+		DurationMinutes: int(math.Ceil(float64(summary.DurationSeconds) / 60)),
+		Status:          store.WorkoutCompleted,
+		Entries:         []store.WorkoutEntry{entry},
+	}
+
+	if summary.StartTime != nil {
+		workout.PerformedAt = *summary.StartTime
+	}
+
+	for _, point := range track.Points {
+		if !point.HasPosition() {
+			continue
+		}
+
+		workout.Route = append(workout.Route, store.RoutePoint{
+			Latitude:   *point.Latitude,
+			Longitude:  *point.Longitude,
+			Elevation:  point.Elevation,
+			RecordedAt: point.Time,
+			HeartRate:  point.HeartRate,
+		})
+	}
+
+	return workout
+}
+
+// returns the route of a workout as a GeoJSON feature, the times and heart rates of the points are properties of the feature
+func (wh *WorkoutHandler) HandleGetWorkoutRoute(w http.ResponseWriter, r *http.Request) {
+	workoutID, err := utils.ReadIDParam(r)
+	if err != nil {
+		wh.logger.WarnContext(r.Context(), "readIDParam", "error", err)
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+
+	currentUser := middleware.GetUser(r)
+
+	if currentUser == nil || currentUser == store.AnonymousUser {
+		utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "you must be logged in"})
+		return
+	}
+
+	workoutOwnerID, err := wh.workoutStore.GetWorkoutOwner(r.Context(), workoutID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout does not exist"})
+		return
+	}
+
+	if err != nil {
+		wh.logger.ErrorContext(r.Context(), "getWorkoutOwner", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if workoutOwnerID != currentUser.ID {
+		utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized for this action"})
+		return
+	}
+
+	points, err := wh.workoutStore.GetWorkoutRoute(r.Context(), workoutID)
+	if err != nil {
+		wh.logger.ErrorContext(r.Context(), "getWorkoutRoute", "error", err)
+		utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	if len(points) == 0 {
+		utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout has no route"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/geo+json")
+	err = json.NewEncoder(w).Encode(routeFeature(workoutID, points))
+	if err != nil {
+		wh.logger.ErrorContext(r.Context(), "writingRoute", "error", err)
+	}
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONLineString      `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONLineString struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+// positions are [longitude, latitude] with the elevation as a third value when every point has one,
+// GeoJSON does not allow mixing positions with and without an elevation
+func routeFeature(workoutID int64, points []store.RoutePoint) geoJSONFeature {
+	hasElevation := true
+	for _, point := range points {
+		if point.Elevation == nil {
+			hasElevation = false
+			break
+		}
+	}
+
+	coordinates := make([][]float64, len(points))
+	times := make([]*string, len(points))
+	heartRates := make([]*int, len(points))
+
+	for i, point := range points {
+		coordinates[i] = []float64{point.Longitude, point.Latitude}
+		if hasElevation {
+			coordinates[i] = append(coordinates[i], *point.Elevation)
+		}
+
+		if point.RecordedAt != nil {
+			recordedAt := point.RecordedAt.UTC().Format(time.RFC3339)
+			times[i] = &recordedAt
+		}
+
+		heartRates[i] = point.HeartRate
+	}
+
+	return geoJSONFeature{
+		Type:     "Feature",
+		Geometry: geoJSONLineString{Type: "LineString", Coordinates: coordinates},
+		Properties: map[string]interface{}{
+			"workout_id":  workoutID,
+			"times":       times,
+			"heart_rates": heartRates,
+		},
+	}
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tracks"
+)
+
+const importGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1">
+	<trk>
+		<name>Park loop</name>
+		<type>running</type>
+		<trkseg>
+			<trkpt lat="44.4268" lon="26.1025"><ele>70</ele><time>2025-03-10T07:00:00Z</time></trkpt>
+			<trkpt lat="44.4358" lon="26.1025"><ele>80</ele><time>2025-03-10T07:05:01Z</time></trkpt>
+		</trkseg>
+	</trk>
+</gpx>`
+
+// workout store keeping the workout it was asked to create
+type importWorkoutStore struct {
+	store.WorkoutStore
+	created *store.Workout
+}
+
+func (s *importWorkoutStore) CreateWorkout(ctx context.Context, workout *store.Workout) (*store.Workout, error) {
+	workout.ID = 1
+	s.created = workout
+	return workout, nil
+}
+
+func TestImportedWorkout(t *testing.T) {
+	lat, lon := 44.4268, 26.1025
+	start := time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		track        *tracks.Track
+		title        string
+		exercise     string
+		wantTitle    string
+		wantExercise string
+		wantRoute    int
+	}{
+		{
+			name:         "defaults come from the track",
+			track:        &tracks.Track{Name: "Evening ride", Sport: tracks.SportCycling, Points: []tracks.Point{{Latitude: &lat, Longitude: &lon, Time: &start}}},
+			wantTitle:    "Evening ride",
+			wantExercise: "Cycling",
+			wantRoute:    1,
+		},
+		{
+			name:         "request overrides the title and exercise",
+			track:        &tracks.Track{Name: "Evening ride", Sport: tracks.SportCycling, Points: []tracks.Point{{Latitude: &lat, Longitude: &lon}}},
+			title:        " commute ",
+			exercise:     "Spinning",
+			wantTitle:    "commute",
+			wantExercise: "Spinning",
+			wantRoute:    1,
+		},
+		{
+			name:         "points without a position are not part of the route",
+			track:        &tracks.Track{Sport: tracks.SportOther, Points: []tracks.Point{{Time: &start}}},
+			wantTitle:    "Running",
+			wantExercise: "Running",
+			wantRoute:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workout := importedWorkout(tt.track, tt.title, tt.exercise)
+
+			assert.Equal(t, tt.wantTitle, workout.Title)
+			assert.Equal(t, store.WorkoutCompleted, workout.Status)
+			require.Len(t, workout.Entries, 1)
+			assert.Equal(t, tt.wantExercise, workout.Entries[0].ExerciseName)
+			assert.Equal(t, store.EntryCardio, workout.Entries[0].EntryType)
+			assert.Len(t, workout.Route, tt.wantRoute)
+		})
+	}
+}
+
+func TestHandleImportWorkout(t *testing.T) {
+	multipartBody := func() (*bytes.Buffer, string) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		require.NoError(t, writer.WriteField("title", "tempo run"))
+		part, err := writer.CreateFormFile("file", "run.gpx")
+		require.NoError(t, err)
+		_, err = part.Write([]byte(importGPX))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		return body, writer.FormDataContentType()
+	}
+
+	tests := []struct {
+		name       string
+		request    func() *http.Request
+		wantStatus int
+		wantTitle  string
+	}{
+		{
+			name: "raw gpx body",
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/workouts/import", strings.NewReader(importGPX))
+				req.Header.Set("Content-Type", "application/gpx+xml")
+				return req
+			},
+			wantStatus: http.StatusCreated,
+			wantTitle:  "Park loop",
+		},
+		{
+			name: "multipart upload with a title",
+			request: func() *http.Request {
+				body, contentType := multipartBody()
+				req := httptest.NewRequest(http.MethodPost, "/workouts/import", body)
+				req.Header.Set("Content-Type", contentType)
+				return req
+			},
+			wantStatus: http.StatusCreated,
+			wantTitle:  "tempo run",
+		},
+		{
+			name: "not a track file",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/workouts/import", strings.NewReader(`{"title": "run"}`))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "point out of range",
+			request: func() *http.Request {
+				gpx := `<gpx><trk><trkseg><trkpt lat="44.43" lon="206.10"></trkpt></trkseg></trk></gpx>`
+				return httptest.NewRequest(http.MethodPost, "/workouts/import", strings.NewReader(gpx))
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workoutStore := &importWorkoutStore{}
+			handler := NewWorkoutHandler(workoutStore, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			req := middleware.SetUser(tt.request(), &store.User{ID: 7})
+			rr := httptest.NewRecorder()
+
+			handler.HandleImportWorkout(rr, req)
+
+			require.Equal(t, tt.wantStatus, rr.Code, rr.Body.String())
+			if tt.wantStatus != http.StatusCreated {
+				assert.Nil(t, workoutStore.created)
+				return
+			}
+
+			workout := workoutStore.created
+			assert.Equal(t, 7, workout.UserID)
+			assert.Equal(t, tt.wantTitle, workout.Title)
+			assert.Equal(t, 6, workout.DurationMinutes)
+			assert.True(t, workout.PerformedAt.Equal(time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)))
+			assert.Len(t, workout.Route, 2)
+
+			entry := workout.Entries[0]
+			assert.Equal(t, "Running", entry.ExerciseName)
+			assert.Equal(t, 1.001, *entry.Distance)
+			assert.Equal(t, "km", *entry.DistanceUnit)
+			assert.Equal(t, 301, *entry.DurationSeconds)
+			assert.Equal(t, 10.0, *entry.ElevationGainMeters)
+		})
+	}
+}
+
+func TestRouteFeature(t *testing.T) {
+	recordedAt := time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)
+	elevation := 70.0
+	heartRate := 120
+
+	feature := routeFeature(3, []store.RoutePoint{
+		{Seq: 1, Latitude: 44.4268, Longitude: 26.1025, Elevation: &elevation, RecordedAt: &recordedAt, HeartRate: &heartRate},
+		{Seq: 2, Latitude: 44.4358, Longitude: 26.1025},
+	})
+
+	js, err := json.Marshal(feature)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"type": "Feature",
+		"geometry": {"type": "LineString", "coordinates": [[26.1025, 44.4268], [26.1025, 44.4358]]},
+		"properties": {"workout_id": 3, "times": ["2025-03-10T07:00:00Z", null], "heart_rates": [120, null]}
+	}`, string(js))
+}
@@ -2,30 +2,86 @@ package app
 
 //resources used within the application
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/zidariu-sabin/femProject/internal/api"
+	"github.com/zidariu-sabin/femProject/internal/config"
+	"github.com/zidariu-sabin/femProject/internal/jobs"
+	"github.com/zidariu-sabin/femProject/internal/logging"
+	"github.com/zidariu-sabin/femProject/internal/mailer"
+	"github.com/zidariu-sabin/femProject/internal/metrics"
 	"github.com/zidariu-sabin/femProject/internal/middleware"
 	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tracing"
 	"github.com/zidariu-sabin/femProject/migrations"
 )
 
+// spans still pending on exit are dropped after this time
+const tracingShutdownTimeout = 5 * time.Second
+
 type Application struct {
-	Logger         *log.Logger
-	WorkoutHandler *api.WorkoutHandler
-	UserHandler    *api.UserHandler
-	TokenHandler   *api.TokenHandler
-	Middleware     *middleware.UserMiddleware
-	DB             *sql.DB
+	Config           *config.Config
+	Logger           *slog.Logger
+	WorkoutHandler   *api.WorkoutHandler
+	ExerciseHandler  *api.ExerciseHandler
+	RecordHandler    *api.RecordHandler
+	AnalyticsHandler *api.AnalyticsHandler
+	TemplateHandler  *api.TemplateHandler
+	ProgramHandler   *api.ProgramHandler
+	CalendarHandler  *api.CalendarHandler
+	ExportHandler    *api.ExportHandler
+	AccountHandler   *api.AccountHandler
+	ImportHandler    *api.ImportHandler
+	UserHandler      *api.UserHandler
+	TokenHandler     *api.TokenHandler
+	PasswordHandler  *api.PasswordHandler
+	Middleware       *middleware.UserMiddleware
+	DB               *sql.DB
+	Metrics          *metrics.Metrics
+	Jobs             *jobs.Runner
+	Mailer           *mailer.AsyncMailer
+	stopJobs         context.CancelFunc
+	stopTracing      func(context.Context) error
+	startedAt        time.Time
+	shuttingDown     atomic.Bool
 }
 
 // implementing logging
-func NewApplication() (*Application, error) {
-	pgDB, err := store.Open()
+func NewApplication(cfg *config.Config) (*Application, error) {
+	logger, err := logging.New(os.Stdout, cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	stopTracing, err := tracing.Setup(context.Background(), cfg.TracingExporter, cfg.OTLPEndpoint, buildVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	//the tracer provider is shut down when a later step fails
+	started := false
+	defer func() {
+		if !started {
+			shutdownTracing(stopTracing, logger)
+		}
+	}()
+
+	appMailer, err := mailer.New(cfg.MailerBackend, cfg.MailSender, mailer.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+	}, cfg.MailDir)
+	if err != nil {
+		return nil, err
+	}
+
+	pgDB, err := store.Open(cfg.DatabaseDSN)
 
 	if err != nil {
 		return nil, err
@@ -34,36 +90,110 @@ func NewApplication() (*Application, error) {
 	// "." means we are going to use the base of the directory
 	err = store.MigrateFs(pgDB, migrations.FS, ".")
 	if err != nil {
-		panic(err)
+		pgDB.Close()
+		return nil, err
 	}
 
-	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
+	logger.Info("connected to database")
+
+	appMetrics := metrics.New(pgDB)
 
 	//stores
-	workoutStore := store.NewPostgresWorkoutStore(pgDB)
-	userStore := store.NewPostgresUserStore(pgDB)
-	tokenStore := store.NewPostgresTokenStore(pgDB)
+	workoutStore := store.NewPostgresWorkoutStore(pgDB, cfg.QueryTimeout, appMetrics)
+	userStore := store.NewPostgresUserStore(pgDB, cfg.QueryTimeout)
+	tokenStore := store.NewPostgresTokenStore(pgDB, cfg.QueryTimeout, appMetrics)
+	exerciseStore := store.NewPostgresExerciseStore(pgDB, cfg.QueryTimeout)
+	recordStore := store.NewPostgresRecordStore(pgDB, cfg.QueryTimeout)
+	analyticsStore := store.NewPostgresAnalyticsStore(pgDB, cfg.QueryTimeout)
+	templateStore := store.NewPostgresTemplateStore(pgDB, cfg.QueryTimeout)
+	programStore := store.NewPostgresProgramStore(pgDB, cfg.QueryTimeout)
+	calendarStore := store.NewPostgresCalendarStore(pgDB, cfg.QueryTimeout)
+
+	//emails of the endpoints anyone can call are sent in the background, a synchronous send would make them slower
+	//for registered emails than for unknown ones
+	asyncMailer := mailer.NewAsyncMailer(appMailer, logger)
 
 	//handlers
 	workoutHandler := api.NewWorkoutHandler(workoutStore, logger)
-	userHandler := api.NewUserHandler(userStore, logger)
-	tokenHandler := api.NewTokenHandler(tokenStore, userStore, logger)
-	middlewareHandler := middleware.NewUserMiddleware(userStore)
+	exerciseHandler := api.NewExerciseHandler(exerciseStore, logger)
+	recordHandler := api.NewRecordHandler(recordStore, exerciseStore, logger)
+	analyticsHandler := api.NewAnalyticsHandler(analyticsStore, exerciseStore, logger)
+	templateHandler := api.NewTemplateHandler(templateStore, workoutStore, logger)
+	programHandler := api.NewProgramHandler(programStore, templateStore, workoutStore, logger)
+	calendarHandler := api.NewCalendarHandler(calendarStore, tokenStore, userStore, logger)
+	exportHandler := api.NewExportHandler(workoutStore, logger)
+	importHandler := api.NewImportHandler(workoutStore, logger)
+	accountHandler := api.NewAccountHandler(userStore, workoutStore, tokenStore, cfg.AccountDeletionGracePeriod, logger)
+	userHandler := api.NewUserHandler(userStore, tokenStore, asyncMailer, cfg.BcryptCost, logger)
+	passwordHandler := api.NewPasswordHandler(userStore, tokenStore, asyncMailer, cfg.BcryptCost, logger)
+	tokenHandler := api.NewTokenHandler(tokenStore, userStore, cfg.AccessTokenTTL, cfg.RefreshTokenTTL, appMetrics, logger)
+	middlewareHandler := middleware.NewUserMiddleware(userStore, tokenStore, appMetrics, logger)
+
+	//background jobs
+	jobRunner := jobs.NewRunner(jobs.RealClock{}, logger)
+	jobRunner.Register("token reaper", cfg.TokenReaperInterval, jobs.TokenReaper(tokenStore, logger))
+	jobRunner.Register("account reaper", cfg.AccountReaperInterval, jobs.AccountReaper(userStore, logger))
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobRunner.Start(jobsCtx)
 
 	app := &Application{
-		Logger:         logger,
-		WorkoutHandler: workoutHandler,
-		UserHandler:    userHandler,
-		TokenHandler:   tokenHandler,
-		Middleware:     middlewareHandler,
-		DB:             pgDB,
+		Config:           cfg,
+		Logger:           logger,
+		WorkoutHandler:   workoutHandler,
+		ExerciseHandler:  exerciseHandler,
+		RecordHandler:    recordHandler,
+		AnalyticsHandler: analyticsHandler,
+		TemplateHandler:  templateHandler,
+		ProgramHandler:   programHandler,
+		CalendarHandler:  calendarHandler,
+		ExportHandler:    exportHandler,
+		ImportHandler:    importHandler,
+		AccountHandler:   accountHandler,
+		UserHandler:      userHandler,
+		TokenHandler:     tokenHandler,
+		PasswordHandler:  passwordHandler,
+		Middleware:       middlewareHandler,
+		DB:               pgDB,
+		Metrics:          appMetrics,
+		Jobs:             jobRunner,
+		Mailer:           asyncMailer,
+		stopJobs:         stopJobs,
+		stopTracing:      stopTracing,
+		startedAt:        time.Now(),
 	}
 
+	started = true
+
 	return app, nil
 }
 
-// we want to have the request as a pointer in order to persist the incoming data in comparison to the data that we send back
+// cancels the background jobs and waits for the running ones to return
+func (a *Application) StopJobs() {
+	a.stopJobs()
+	a.Jobs.Wait()
+}
+
+// releases the resources of the application once the server stopped serving requests
+func (a *Application) Close() error {
+	a.StopJobs()
 
-func (a *Application) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Status is available\n")
+	//the emails of the last requests are still delivered
+	a.Mailer.Wait()
+
+	//pending spans are flushed before exiting
+	shutdownTracing(a.stopTracing, a.Logger)
+
+	return a.DB.Close()
+}
+
+// flushes the pending spans, an unreachable collector cannot hold up the exit for longer than tracingShutdownTimeout
+func shutdownTracing(stop func(context.Context) error, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer cancel()
+
+	err := stop(ctx)
+	if err != nil {
+		logger.Error("stopping tracing", "error", err)
+	}
 }
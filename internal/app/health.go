@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/utils"
+)
+
+// build version of the application, set at build time with
+// go build -ldflags "-X github.com/zidariu-sabin/femProject/internal/app.Version=v1.2.3"
+var Version = ""
+
+// how long the readiness probe waits for the database
+const readinessTimeout = 2 * time.Second
+
+// falls back to the vcs revision embedded by the go toolchain when no version was set at build time
+func buildVersion() string {
+	if Version != "" {
+		return Version
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+
+	return "dev"
+}
+
+// marks the application as shutting down so the readiness probe fails and load balancers stop sending traffic
+func (a *Application) BeginShutdown() {
+	a.shuttingDown.Store(true)
+}
+
+// we want to have the request as a pointer in order to persist the incoming data in comparison to the data that we send back
+// liveness only reports that the process is able to serve requests, it does not check any dependency
+func (a *Application) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"status":  "alive",
+		"version": buildVersion(),
+		"uptime":  time.Since(a.startedAt).Round(time.Second).String(),
+	})
+}
+
+// readiness checks the database and fails while the application is shutting down
+func (a *Application) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	status := http.StatusOK
+	checks := utils.Envelope{}
+
+	if a.shuttingDown.Load() {
+		status = http.StatusServiceUnavailable
+		checks["shutdown"] = "in progress"
+	}
+
+	database := utils.Envelope{"status": "up"}
+
+	err := a.DB.PingContext(ctx)
+	if err != nil {
+		a.Logger.ErrorContext(ctx, "readiness database ping", "error", err)
+		status = http.StatusServiceUnavailable
+		database["status"] = "down"
+	} else {
+		version, err := store.MigrationVersion(ctx, a.DB)
+		if err != nil {
+			a.Logger.ErrorContext(ctx, "readiness migration version", "error", err)
+			status = http.StatusServiceUnavailable
+			database["status"] = "down"
+		} else {
+			database["migration_version"] = version
+		}
+	}
+
+	stats := a.DB.Stats()
+	database["pool"] = utils.Envelope{
+		"max_open_connections": stats.MaxOpenConnections,
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"wait_count":           stats.WaitCount,
+		"wait_duration":        stats.WaitDuration.String(),
+	}
+	checks["database"] = database
+
+	state := "ready"
+	if status != http.StatusOK {
+		state = "unavailable"
+	}
+
+	utils.WriteJson(w, status, utils.Envelope{
+		"status":  state,
+		"version": buildVersion(),
+		"uptime":  time.Since(a.startedAt).Round(time.Second).String(),
+		"checks":  checks,
+	})
+}
@@ -0,0 +1,150 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "weekly by day", value: "RRULE:FREQ=WEEKLY;BYDAY=FR,MO,WE", want: "FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+		{name: "daily with interval and count", value: "freq=daily;interval=2;count=10", want: "FREQ=DAILY;INTERVAL=2;COUNT=10"},
+		{name: "monthly until a date", value: "FREQ=MONTHLY;UNTIL=20250630", want: "FREQ=MONTHLY;UNTIL=20250630T235959Z"},
+		{name: "missing freq", value: "COUNT=3", wantErr: true},
+		{name: "yearly", value: "FREQ=YEARLY", wantErr: true},
+		{name: "byday on a daily rule", value: "FREQ=DAILY;BYDAY=MO", wantErr: true},
+		{name: "count and until", value: "FREQ=DAILY;COUNT=2;UNTIL=20250630", wantErr: true},
+		{name: "unsupported part", value: "FREQ=WEEKLY;BYSETPOS=1", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule(tt.value)
+
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidRule)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rule.String())
+		})
+	}
+}
+
+func TestRuleBetween(t *testing.T) {
+	//a wednesday
+	start := time.Date(2025, 3, 12, 7, 30, 0, 0, time.UTC)
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2025, month, d, 7, 30, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		rule string
+		from time.Time
+		to   time.Time
+		want []time.Time
+	}{
+		{
+			name: "weekly by day skips days before the start",
+			rule: "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			from: start,
+			to:   day(time.March, 20),
+			want: []time.Time{day(time.March, 12), day(time.March, 14), day(time.March, 17), day(time.March, 19)},
+		},
+		{
+			name: "weekly by day starts with a start off the days",
+			rule: "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=3",
+			from: start,
+			to:   day(time.April, 1),
+			want: []time.Time{day(time.March, 12), day(time.March, 13), day(time.March, 18)},
+		},
+		{
+			name: "every other week",
+			rule: "FREQ=WEEKLY;INTERVAL=2",
+			from: start,
+			to:   day(time.April, 10),
+			want: []time.Time{day(time.March, 12), day(time.March, 26), day(time.April, 9)},
+		},
+		{
+			name: "count is counted from the start",
+			rule: "FREQ=DAILY;COUNT=5",
+			from: day(time.March, 14),
+			to:   day(time.April, 1),
+			want: []time.Time{day(time.March, 14), day(time.March, 15), day(time.March, 16)},
+		},
+		{
+			name: "until includes the last day",
+			rule: "FREQ=DAILY;INTERVAL=3;UNTIL=20250318",
+			from: start,
+			to:   day(time.April, 1),
+			want: []time.Time{day(time.March, 12), day(time.March, 15), day(time.March, 18)},
+		},
+		{
+			name: "monthly skips months without the day",
+			rule: "FREQ=MONTHLY;COUNT=3",
+			from: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			to:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want: []time.Time{
+				time.Date(2025, 1, 31, 7, 30, 0, 0, time.UTC),
+				time.Date(2025, 3, 31, 7, 30, 0, 0, time.UTC),
+				time.Date(2025, 5, 31, 7, 30, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParseRule(tt.rule)
+			require.NoError(t, err)
+
+			ruleStart := start
+			if rule.Freq == FreqMonthly {
+				ruleStart = time.Date(2025, 1, 31, 7, 30, 0, 0, time.UTC)
+			}
+
+			assert.Equal(t, tt.want, rule.Between(ruleStart, tt.from, tt.to))
+		})
+	}
+}
+
+func TestWriteCalendar(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := WriteCalendar(&buf, "workouts", []Event{
+		{
+			UID:         "plan-1@workouts",
+			Summary:     "Leg day, heavy; squats",
+			Description: strings.Repeat("long description ", 10),
+			Start:       time.Date(2025, 3, 12, 7, 30, 0, 0, time.UTC),
+			Duration:    time.Hour,
+			RRule:       "FREQ=WEEKLY;BYDAY=MO,WE",
+			Status:      StatusConfirmed,
+			Stamp:       time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+	})
+	require.NoError(t, err)
+
+	out := buf.String()
+	assert.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\n"))
+	assert.Contains(t, out, "DTSTART:20250312T073000Z\r\n")
+	assert.Contains(t, out, "DTEND:20250312T083000Z\r\n")
+	assert.Contains(t, out, "RRULE:FREQ=WEEKLY;BYDAY=MO,WE\r\n")
+	assert.Contains(t, out, `SUMMARY:Leg day\, heavy\; squats`)
+
+	for _, line := range strings.Split(out, "\r\n") {
+		assert.LessOrEqual(t, len(line), maxLineLength)
+	}
+}
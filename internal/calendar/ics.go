@@ -0,0 +1,86 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// statuses of an event as defined by RFC 5545
+const (
+	StatusTentative = "TENTATIVE"
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// lines longer than this many octets are folded
+const maxLineLength = 75
+
+// a VEVENT of a feed, times are written in utc
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	Duration    time.Duration
+	Status      string
+	//recurrence rule of the event, empty for a single event
+	RRule string
+	//last modification of the event
+	Stamp time.Time
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// writes the events as an iCalendar stream that calendar apps can subscribe to
+func WriteCalendar(w io.Writer, name string, events []Event) error {
+	writer := bufio.NewWriter(w)
+
+	writeLine(writer, "BEGIN:VCALENDAR")
+	writeLine(writer, "VERSION:2.0")
+	writeLine(writer, "PRODID:-//GoWorkoutTracker//Workouts//EN")
+	writeLine(writer, "CALSCALE:GREGORIAN")
+	writeLine(writer, "METHOD:PUBLISH")
+	writeLine(writer, "X-WR-CALNAME:"+textEscaper.Replace(name))
+
+	for _, event := range events {
+		writeLine(writer, "BEGIN:VEVENT")
+		writeLine(writer, "UID:"+event.UID)
+		writeLine(writer, "DTSTAMP:"+event.Stamp.UTC().Format(utcLayout))
+		writeLine(writer, "DTSTART:"+event.Start.UTC().Format(utcLayout))
+		writeLine(writer, "DTEND:"+event.Start.Add(event.Duration).UTC().Format(utcLayout))
+		if event.RRule != "" {
+			writeLine(writer, "RRULE:"+event.RRule)
+		}
+		writeLine(writer, "SUMMARY:"+textEscaper.Replace(event.Summary))
+		if event.Description != "" {
+			writeLine(writer, "DESCRIPTION:"+textEscaper.Replace(event.Description))
+		}
+		if event.Status != "" {
+			writeLine(writer, "STATUS:"+event.Status)
+		}
+		writeLine(writer, "END:VEVENT")
+	}
+
+	writeLine(writer, "END:VCALENDAR")
+
+	return writer.Flush()
+}
+
+// writes a content line ending in CRLF, folding it without splitting utf-8 characters
+func writeLine(w *bufio.Writer, line string) {
+	length := 0
+	for _, r := range line {
+		size := len(string(r))
+		if length+size > maxLineLength {
+			fmt.Fprint(w, "\r\n ")
+			//the leading space of a continuation line counts towards its length
+			length = 1
+		}
+		w.WriteRune(r)
+		length += size
+	}
+	fmt.Fprint(w, "\r\n")
+}
@@ -0,0 +1,225 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frequencies of the supported subset of RFC 5545 recurrence rules
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+)
+
+// a recurrence is never expanded past this many periods so a rule cannot keep a request busy
+const maxPeriods = 100000
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// recurrence rule with FREQ, INTERVAL, BYDAY (weekly rules only), COUNT and UNTIL,
+// weeks start on monday and the time of day of the occurrences is the one of the start
+type Rule struct {
+	Freq     string
+	Interval int
+	ByDay    []time.Weekday
+	Count    int
+	Until    *time.Time
+}
+
+// parses the value of an RRULE property, with or without the "RRULE:" prefix
+func ParseRule(value string) (*Rule, error) {
+	rule := &Rule{Interval: 1}
+
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	if value == "" {
+		return nil, fmt.Errorf("%w: the rule is empty", ErrInvalidRule)
+	}
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("%w: %q is not a key=value pair", ErrInvalidRule, part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+			if rule.Freq != FreqDaily && rule.Freq != FreqWeekly && rule.Freq != FreqMonthly {
+				return nil, fmt.Errorf("%w: FREQ must be DAILY, WEEKLY or MONTHLY", ErrInvalidRule)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL must be a positive number", ErrInvalidRule)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("%w: COUNT must be a positive number", ErrInvalidRule)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseRuleTime(val)
+			if err != nil {
+				return nil, fmt.Errorf("%w: UNTIL must be a date or a utc date time", ErrInvalidRule)
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(val), ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("%w: unknown BYDAY value %q", ErrInvalidRule, day)
+				}
+				if !slices.Contains(rule.ByDay, weekday) {
+					rule.ByDay = append(rule.ByDay, weekday)
+				}
+			}
+		case "WKST":
+			if strings.ToUpper(val) != "MO" {
+				return nil, fmt.Errorf("%w: weeks start on monday", ErrInvalidRule)
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s is not supported", ErrInvalidRule, key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+
+	if len(rule.ByDay) > 0 && rule.Freq != FreqWeekly {
+		return nil, fmt.Errorf("%w: BYDAY is only supported for weekly rules", ErrInvalidRule)
+	}
+
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot be combined", ErrInvalidRule)
+	}
+
+	slices.SortFunc(rule.ByDay, func(a, b time.Weekday) int { return mondayOffset(a) - mondayOffset(b) })
+
+	return rule, nil
+}
+
+// canonical form of the rule, used when the rule is stored and written to a feed
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+
+	if len(r.ByDay) > 0 {
+		days := []string{}
+		for _, weekday := range r.ByDay {
+			days = append(days, strings.ToUpper(weekday.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(utcLayout))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// occurrences of the rule starting at start that fall in [from, to), the start is the first occurrence
+// and counts towards COUNT even when it is not on one of the BYDAY days, as the rfc requires
+func (r *Rule) Between(start, from, to time.Time) []time.Time {
+	occurrences := []time.Time{}
+	count := 0
+
+	for period := 0; period < maxPeriods; period++ {
+		candidates := r.period(start, period)
+		if period == 0 && !slices.ContainsFunc(candidates, start.Equal) {
+			candidates = append([]time.Time{start}, candidates...)
+		}
+
+		for _, occurrence := range candidates {
+			if occurrence.Before(start) {
+				continue
+			}
+
+			count++
+			if (r.Count > 0 && count > r.Count) || (r.Until != nil && occurrence.After(*r.Until)) || !occurrence.Before(to) {
+				return occurrences
+			}
+
+			if !occurrence.Before(from) {
+				occurrences = append(occurrences, occurrence)
+			}
+		}
+	}
+
+	return occurrences
+}
+
+// candidate occurrences of the nth period of the rule in chronological order
+func (r *Rule) period(start time.Time, n int) []time.Time {
+	switch r.Freq {
+	case FreqDaily:
+		return []time.Time{start.AddDate(0, 0, n*r.Interval)}
+	case FreqWeekly:
+		if len(r.ByDay) == 0 {
+			return []time.Time{start.AddDate(0, 0, 7*n*r.Interval)}
+		}
+
+		weekStart := start.AddDate(0, 0, 7*n*r.Interval-mondayOffset(start.Weekday()))
+		occurrences := []time.Time{}
+		for _, weekday := range r.ByDay {
+			occurrences = append(occurrences, weekStart.AddDate(0, 0, mondayOffset(weekday)))
+		}
+		return occurrences
+	default:
+		//months without the day of the start are skipped as the rfc requires
+		occurrence := start.AddDate(0, n*r.Interval, 0)
+		if occurrence.Day() != start.Day() {
+			return nil
+		}
+		return []time.Time{occurrence}
+	}
+}
+
+func mondayOffset(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
+
+const (
+	utcLayout  = "20060102T150405Z"
+	dateLayout = "20060102"
+)
+
+func parseRuleTime(value string) (time.Time, error) {
+	t, err := time.Parse(utcLayout, value)
+	if err == nil {
+		return t, nil
+	}
+
+	t, err = time.Parse(dateLayout, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	//a date includes the whole day
+	return t.Add(24*time.Hour - time.Second), nil
+}
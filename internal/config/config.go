@@ -0,0 +1,316 @@
+package config
+
+//runtime configuration of the application
+//values are resolved in the order defaults < config file < environment variables < flags
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/mailer"
+	"github.com/zidariu-sabin/femProject/internal/store"
+	"github.com/zidariu-sabin/femProject/internal/tokens"
+	"github.com/zidariu-sabin/femProject/internal/tracing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Config struct {
+	Port            int
+	DatabaseDSN     string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	BcryptCost      int
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	// time between failing the readiness probe and closing the listener, lets load balancers stop routing to the instance
+	ShutdownDrainDelay  time.Duration
+	QueryTimeout        time.Duration
+	TokenReaperInterval time.Duration
+	// zero deletes accounts right away
+	AccountDeletionGracePeriod time.Duration
+	AccountReaperInterval      time.Duration
+	LogLevel                   string
+	TracingExporter            string
+	OTLPEndpoint               string
+	MailerBackend              string
+	MailSender                 string
+	// directory of the file mailer
+	MailDir      string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+func Default() *Config {
+	return &Config{
+		Port:                  8080,
+		AccessTokenTTL:        tokens.DefaultAccessTokenTTL,
+		RefreshTokenTTL:       tokens.DefaultRefreshTokenTTL,
+		BcryptCost:            store.DefaultBcryptCost,
+		ReadTimeout:           10 * time.Second,
+		WriteTimeout:          30 * time.Second,
+		IdleTimeout:           time.Minute,
+		ShutdownTimeout:       15 * time.Second,
+		ShutdownDrainDelay:    5 * time.Second,
+		QueryTimeout:          5 * time.Second,
+		TokenReaperInterval:   time.Hour,
+		AccountReaperInterval: time.Hour,
+		LogLevel:              "info",
+		TracingExporter:       tracing.ExporterNone,
+		OTLPEndpoint:          "localhost:4318",
+		MailerBackend:         mailer.BackendFile,
+		MailSender:            "femProject <no-reply@localhost>",
+		MailDir:               "mail",
+		SMTPPort:              587,
+	}
+}
+
+// a single configuration value, the key is used both in the config file and as the flag name
+type setting struct {
+	key   string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+func intSetting(key, env, usage string, field func(c *Config) *int) setting {
+	return setting{key: key, env: env, usage: usage, set: func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid number %q", key, value)
+		}
+		*field(c) = i
+		return nil
+	}}
+}
+
+func durationSetting(key, env, usage string, field func(c *Config) *time.Duration) setting {
+	return setting{key: key, env: env, usage: usage, set: func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: invalid duration %q", key, value)
+		}
+		*field(c) = d
+		return nil
+	}}
+}
+
+func stringSetting(key, env, usage string, field func(c *Config) *string) setting {
+	return setting{key: key, env: env, usage: usage, set: func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+var settings = []setting{
+	intSetting("port", "PORT", "go backend port", func(c *Config) *int { return &c.Port }),
+	stringSetting("database-dsn", "DATABASE_DSN", "postgres connection string", func(c *Config) *string { return &c.DatabaseDSN }),
+	durationSetting("access-token-ttl", "ACCESS_TOKEN_TTL", "lifetime of access tokens", func(c *Config) *time.Duration { return &c.AccessTokenTTL }),
+	durationSetting("refresh-token-ttl", "REFRESH_TOKEN_TTL", "lifetime of refresh tokens", func(c *Config) *time.Duration { return &c.RefreshTokenTTL }),
+	intSetting("bcrypt-cost", "BCRYPT_COST", "cost used when hashing passwords", func(c *Config) *int { return &c.BcryptCost }),
+	durationSetting("read-timeout", "READ_TIMEOUT", "http server read timeout", func(c *Config) *time.Duration { return &c.ReadTimeout }),
+	durationSetting("write-timeout", "WRITE_TIMEOUT", "http server write timeout", func(c *Config) *time.Duration { return &c.WriteTimeout }),
+	durationSetting("idle-timeout", "IDLE_TIMEOUT", "http server idle timeout", func(c *Config) *time.Duration { return &c.IdleTimeout }),
+	durationSetting("shutdown-timeout", "SHUTDOWN_TIMEOUT", "how long in-flight requests are drained on shutdown", func(c *Config) *time.Duration { return &c.ShutdownTimeout }),
+	durationSetting("shutdown-drain-delay", "SHUTDOWN_DRAIN_DELAY", "how long the server keeps accepting requests after reporting not ready on shutdown", func(c *Config) *time.Duration { return &c.ShutdownDrainDelay }),
+	durationSetting("query-timeout", "QUERY_TIMEOUT", "how long a store call may run against the database", func(c *Config) *time.Duration { return &c.QueryTimeout }),
+	durationSetting("token-reaper-interval", "TOKEN_REAPER_INTERVAL", "how often expired tokens are deleted", func(c *Config) *time.Duration { return &c.TokenReaperInterval }),
+	durationSetting("account-deletion-grace-period", "ACCOUNT_DELETION_GRACE_PERIOD", "how long a deleted account can be restored, zero deletes it right away", func(c *Config) *time.Duration { return &c.AccountDeletionGracePeriod }),
+	durationSetting("account-reaper-interval", "ACCOUNT_REAPER_INTERVAL", "how often accounts whose grace period ended are deleted", func(c *Config) *time.Duration { return &c.AccountReaperInterval }),
+	stringSetting("log-level", "LOG_LEVEL", "one of debug, info, warn, error", func(c *Config) *string { return &c.LogLevel }),
+	stringSetting("tracing-exporter", "TRACING_EXPORTER", "one of none, stdout, otlp", func(c *Config) *string { return &c.TracingExporter }),
+	stringSetting("otlp-endpoint", "OTEL_EXPORTER_OTLP_ENDPOINT", "host:port of the otlp http collector", func(c *Config) *string { return &c.OTLPEndpoint }),
+	stringSetting("mailer-backend", "MAILER_BACKEND", "one of smtp, file", func(c *Config) *string { return &c.MailerBackend }),
+	stringSetting("mail-sender", "MAIL_SENDER", "from address of the emails sent to users", func(c *Config) *string { return &c.MailSender }),
+	stringSetting("mail-dir", "MAIL_DIR", "directory the file mailer writes emails to", func(c *Config) *string { return &c.MailDir }),
+	stringSetting("smtp-host", "SMTP_HOST", "host of the smtp server", func(c *Config) *string { return &c.SMTPHost }),
+	intSetting("smtp-port", "SMTP_PORT", "port of the smtp server", func(c *Config) *int { return &c.SMTPPort }),
+	stringSetting("smtp-username", "SMTP_USERNAME", "smtp username, empty when the server does not require authentication", func(c *Config) *string { return &c.SMTPUsername }),
+	stringSetting("smtp-password", "SMTP_PASSWORD", "smtp password", func(c *Config) *string { return &c.SMTPPassword }),
+}
+
+// loads the configuration from the optional config file, the environment and the command line arguments
+// the config file is given with the -config flag or the CONFIG_FILE environment variable
+// the result is not validated so the tests can load the configuration without the values needed to run the server
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("femProject", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a json config file")
+
+	//flags are read as strings so they can share the parsing of the other sources
+	flagValues := map[string]*string{}
+	for _, s := range settings {
+		flagValues[s.key] = fs.String(s.key, "", fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		err = cfg.loadFile(*configFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.env)
+		if !ok {
+			continue
+		}
+
+		err = s.set(cfg, value)
+		if err != nil {
+			return nil, fmt.Errorf("env %w", err)
+		}
+	}
+
+	//only flags that were passed explicitly override the other sources
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.key == f.Name && flagErr == nil {
+				flagErr = s.set(cfg, *flagValues[s.key])
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, fmt.Errorf("flag %w", flagErr)
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	values := map[string]interface{}{}
+	err = json.Unmarshal(content, &values)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	for key, value := range values {
+		found := false
+
+		for _, s := range settings {
+			if s.key != key {
+				continue
+			}
+
+			found = true
+			err = s.set(c, fmt.Sprint(value))
+			if err != nil {
+				return fmt.Errorf("config file %w", err)
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("config file %s: unknown key %q", path, key)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port must be between 1 and 65535"))
+	}
+
+	if c.DatabaseDSN == "" {
+		errs = append(errs, errors.New("database dsn is required (set DATABASE_DSN)"))
+	}
+
+	if c.AccessTokenTTL <= 0 || c.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("token ttls must be positive"))
+	} else if c.AccessTokenTTL >= c.RefreshTokenTTL {
+		errs = append(errs, errors.New("access token ttl must be shorter than the refresh token ttl"))
+	}
+
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		errs = append(errs, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost))
+	}
+
+	if c.ReadTimeout <= 0 || c.WriteTimeout <= 0 || c.IdleTimeout <= 0 || c.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server timeouts must be positive"))
+	}
+
+	if c.ShutdownDrainDelay < 0 {
+		errs = append(errs, errors.New("shutdown drain delay cannot be negative"))
+	}
+
+	if c.QueryTimeout <= 0 {
+		errs = append(errs, errors.New("query timeout must be positive"))
+	}
+
+	if c.TokenReaperInterval <= 0 {
+		errs = append(errs, errors.New("token reaper interval must be positive"))
+	}
+
+	if c.AccountDeletionGracePeriod < 0 {
+		errs = append(errs, errors.New("account deletion grace period cannot be negative"))
+	}
+
+	if c.AccountReaperInterval <= 0 {
+		errs = append(errs, errors.New("account reaper interval must be positive"))
+	}
+
+	if !contains(logLevels, c.LogLevel) {
+		errs = append(errs, fmt.Errorf("log level must be one of %s", strings.Join(logLevels, ", ")))
+	}
+
+	if !contains(tracing.Exporters, c.TracingExporter) {
+		errs = append(errs, fmt.Errorf("tracing exporter must be one of %s", strings.Join(tracing.Exporters, ", ")))
+	}
+
+	if c.TracingExporter == tracing.ExporterOTLP && c.OTLPEndpoint == "" {
+		errs = append(errs, errors.New("otlp endpoint is required with the otlp tracing exporter"))
+	}
+
+	if !contains(mailer.Backends, c.MailerBackend) {
+		errs = append(errs, fmt.Errorf("mailer backend must be one of %s", strings.Join(mailer.Backends, ", ")))
+	}
+
+	if c.MailSender == "" {
+		errs = append(errs, errors.New("mail sender is required"))
+	}
+
+	if c.MailerBackend == mailer.BackendFile && c.MailDir == "" {
+		errs = append(errs, errors.New("mail dir is required with the file mailer"))
+	}
+
+	if c.MailerBackend == mailer.BackendSMTP && (c.SMTPHost == "" || c.SMTPPort < 1 || c.SMTPPort > 65535) {
+		errs = append(errs, errors.New("smtp host and a port between 1 and 65535 are required with the smtp mailer"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `{"port": 9000, "database-dsn": "file-dsn", "bcrypt-cost": 10, "access-token-ttl": "5m"}`)
+
+	t.Setenv("DATABASE_DSN", "env-dsn")
+	t.Setenv("BCRYPT_COST", "11")
+
+	cfg, err := Load([]string{"-config", path, "-bcrypt-cost", "13"})
+	require.NoError(t, err)
+
+	//file overrides defaults
+	assert.Equal(t, 9000, cfg.Port)
+	assert.Equal(t, 5*time.Minute, cfg.AccessTokenTTL)
+	//env overrides the file
+	assert.Equal(t, "env-dsn", cfg.DatabaseDSN)
+	//flags override the env
+	assert.Equal(t, 13, cfg.BcryptCost)
+	//untouched values keep their defaults
+	assert.Equal(t, Default().RefreshTokenTTL, cfg.RefreshTokenTTL)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+	}{
+		{name: "invalid flag value", args: []string{"-port", "abc"}},
+		{name: "invalid env duration", env: map[string]string{"ACCESS_TOKEN_TTL": "soon"}},
+		{name: "unknown file key", file: `{"prot": 8080}`},
+		{name: "malformed file", file: `{"port": `},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeConfigFile(t, tt.file))
+			}
+
+			_, err := Load(args)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		cfg := Default()
+		cfg.DatabaseDSN = "dsn"
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr bool
+	}{
+		{name: "defaults with dsn", modify: func(cfg *Config) {}, wantErr: false},
+		{name: "missing dsn", modify: func(cfg *Config) { cfg.DatabaseDSN = "" }, wantErr: true},
+		{name: "port out of range", modify: func(cfg *Config) { cfg.Port = 70000 }, wantErr: true},
+		{name: "access ttl longer than refresh", modify: func(cfg *Config) { cfg.AccessTokenTTL = 48 * time.Hour; cfg.RefreshTokenTTL = time.Hour }, wantErr: true},
+		{name: "bcrypt cost too high", modify: func(cfg *Config) { cfg.BcryptCost = 40 }, wantErr: true},
+		{name: "negative shutdown drain delay", modify: func(cfg *Config) { cfg.ShutdownDrainDelay = -time.Second }, wantErr: true},
+		{name: "no shutdown drain delay", modify: func(cfg *Config) { cfg.ShutdownDrainDelay = 0 }, wantErr: false},
+		{name: "unknown log level", modify: func(cfg *Config) { cfg.LogLevel = "verbose" }, wantErr: true},
+		{name: "unknown mailer backend", modify: func(cfg *Config) { cfg.MailerBackend = "pigeon" }, wantErr: true},
+		{name: "smtp mailer without host", modify: func(cfg *Config) { cfg.MailerBackend = "smtp" }, wantErr: true},
+		{name: "negative account deletion grace period", modify: func(cfg *Config) { cfg.AccountDeletionGracePeriod = -time.Hour }, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/store"
+)
+
+// supported layouts, the generic layout comes first so files exported by this api are always read with it
+var Adapters = []Adapter{
+	{Name: "generic", matches: matchesGeneric, read: readGeneric},
+	{Name: "strong", perSet: true, matches: matchesStrong, read: readStrong},
+	{Name: "hevy", perSet: true, matches: matchesHevy, read: readHevy},
+}
+
+// times without a zone are read as utc
+func parseTime(column, value string, layouts ...string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%s is required", column)
+	}
+
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%s is not a valid time", column)
+}
+
+// the layout of GET /export?format=csv, one row per entry with the workout columns repeated,
+// the id, entry_id and computed columns of the export are ignored
+func matchesGeneric(header columns) bool {
+	return header.has("performed_at") && header.has("title") && header.has("exercise_name")
+}
+
+func readGeneric(row columns) (record, error) {
+	performedAt, err := parseTime("performed_at", row.get("performed_at"), time.RFC3339, time.DateTime, time.DateOnly)
+	if err != nil {
+		return record{}, err
+	}
+
+	rec := record{
+		key:         row.get("workout_id"),
+		performedAt: performedAt,
+		title:       unquoteText(row.get("title")),
+		description: unquoteText(row.get("description")),
+		status:      row.get("status"),
+	}
+
+	if rec.key == "" {
+		rec.key = row.get("performed_at") + "|" + rec.title
+	}
+
+	var errs []error
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	duration, err := parseInt("duration_minutes", row.get("duration_minutes"), false)
+	collect(err)
+	if duration != nil {
+		rec.durationMinutes = *duration
+	}
+
+	calories, err := parseInt("calories_burned", row.get("calories_burned"), false)
+	collect(err)
+	if calories != nil {
+		rec.caloriesBurned = *calories
+	}
+
+	rec.entry.ExerciseName = unquoteText(row.get("exercise_name"))
+	if rec.entry.ExerciseName == "" {
+		return rec, errors.Join(errs...)
+	}
+
+	rec.entry.EntryType = row.get("entry_type")
+	rec.entry.Notes = unquoteText(row.get("notes"))
+
+	sets, err := parseInt("sets", row.get("sets"), false)
+	collect(err)
+	rec.entry.Sets = 1
+	if sets != nil {
+		rec.entry.Sets = *sets
+	}
+
+	rec.entry.Reps, err = parseInt("reps", row.get("reps"), false)
+	collect(err)
+	rec.entry.Weight, err = parseFloat("weight", row.get("weight"), false)
+	collect(err)
+	rec.entry.DurationSeconds, err = parseInt("duration_seconds", row.get("duration_seconds"), false)
+	collect(err)
+	rec.entry.Distance, err = parseFloat("distance", row.get("distance"), false)
+	collect(err)
+	rec.entry.DistanceUnit = parseString(row.get("distance_unit"))
+	rec.entry.AvgHeartRate, err = parseInt("avg_heart_rate", row.get("avg_heart_rate"), false)
+	collect(err)
+	rec.entry.MaxHeartRate, err = parseInt("max_heart_rate", row.get("max_heart_rate"), false)
+	collect(err)
+	rec.entry.ElevationGainMeters, err = parseFloat("elevation_gain_meters", row.get("elevation_gain_meters"), false)
+	collect(err)
+
+	return rec, errors.Join(errs...)
+}
+
+// the export prefixes text that starts like a spreadsheet formula with an apostrophe
+func unquoteText(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// the export of the Strong app, one row per set with the workout duration written as "1h 5m"
+func matchesStrong(header columns) bool {
+	return header.has("Workout Name") && header.has("Exercise Name") && header.has("Set Order")
+}
+
+// kilograms in a pound, Strong exports the weights in the unit the user picked while the training log is in kilograms
+const kilogramsPerPound = 0.45359237
+
+var strongDuration = regexp.MustCompile(`^(?:(\d+)h)?\s*(?:(\d+)m(?:in)?)?\s*(?:(\d+)s)?$`)
+
+func readStrong(row columns) (record, error) {
+	performedAt, err := parseTime("Date", row.get("Date"), time.DateTime, "2006-01-02 15:04", time.RFC3339)
+	if err != nil {
+		return record{}, err
+	}
+
+	rec := record{
+		key:         row.get("Date") + "|" + row.get("Workout Name"),
+		performedAt: performedAt,
+		title:       row.get("Workout Name"),
+		description: row.get("Workout Notes"),
+	}
+
+	if duration := row.get("Duration"); duration != "" {
+		match := strongDuration.FindStringSubmatch(duration)
+		if match == nil {
+			return record{}, errors.New("Duration must look like 1h 5m")
+		}
+
+		hours, _ := strconv.Atoi(match[1])
+		minutes, _ := strconv.Atoi(match[2])
+		seconds, _ := strconv.Atoi(match[3])
+		rec.durationMinutes = hours*60 + minutes
+		if seconds > 0 {
+			rec.durationMinutes++
+		}
+	}
+
+	rec.entry = store.WorkoutEntry{ExerciseName: row.get("Exercise Name"), Sets: 1, Notes: row.get("Notes")}
+
+	var errs []error
+	rec.entry.Reps, err = parseInt("Reps", row.get("Reps"), true)
+	errs = append(errs, err)
+	rec.entry.Weight, err = parseFloat("Weight", row.get("Weight"), true)
+	errs = append(errs, err)
+	switch strings.ToLower(row.get("Weight Unit")) {
+	case "", "kg", "kgs":
+	case "lb", "lbs":
+		if rec.entry.Weight != nil {
+			//rounded to the precision of the weight column so sets with the same load are still merged
+			kilograms := math.Round(*rec.entry.Weight*kilogramsPerPound*100) / 100
+			rec.entry.Weight = &kilograms
+		}
+	default:
+		errs = append(errs, errors.New("Weight Unit must be kg or lbs"))
+	}
+	rec.entry.DurationSeconds, err = parseInt("Seconds", row.get("Seconds"), true)
+	errs = append(errs, err)
+	rec.entry.Distance, err = parseFloat("Distance", row.get("Distance"), true)
+	errs = append(errs, err)
+
+	if rec.entry.Distance != nil {
+		unit := strings.ToLower(row.get("Distance Unit"))
+		if unit == "" {
+			unit = "km"
+		}
+		rec.entry.DistanceUnit = &unit
+	}
+
+	return rec, errors.Join(errs...)
+}
+
+// the export of the Hevy app, one row per set with weights in kilograms and distances in kilometers
+func matchesHevy(header columns) bool {
+	return header.has("exercise_title") && header.has("start_time") && header.has("set_index")
+}
+
+const hevyTime = "2 Jan 2006, 15:04"
+
+func readHevy(row columns) (record, error) {
+	start, err := parseTime("start_time", row.get("start_time"), hevyTime, time.RFC3339)
+	if err != nil {
+		return record{}, err
+	}
+
+	rec := record{
+		key:         row.get("start_time") + "|" + row.get("title"),
+		performedAt: start,
+		title:       row.get("title"),
+		description: row.get("description"),
+	}
+
+	if row.get("end_time") != "" {
+		end, err := parseTime("end_time", row.get("end_time"), hevyTime, time.RFC3339)
+		if err != nil {
+			return record{}, err
+		}
+		rec.durationMinutes = int(end.Sub(start).Minutes())
+	}
+
+	rec.entry = store.WorkoutEntry{ExerciseName: row.get("exercise_title"), Sets: 1, Notes: row.get("exercise_notes")}
+
+	var errs []error
+	rec.entry.Reps, err = parseInt("reps", row.get("reps"), true)
+	errs = append(errs, err)
+	rec.entry.Weight, err = parseFloat("weight_kg", row.get("weight_kg"), true)
+	errs = append(errs, err)
+	rec.entry.DurationSeconds, err = parseInt("duration_seconds", row.get("duration_seconds"), true)
+	errs = append(errs, err)
+	rec.entry.Distance, err = parseFloat("distance_km", row.get("distance_km"), true)
+	errs = append(errs, err)
+
+	if rec.entry.Distance != nil {
+		rec.entry.DistanceUnit = parseString("km")
+	}
+
+	return rec, errors.Join(errs...)
+}
@@ -0,0 +1,411 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zidariu-sabin/femProject/internal/store"
+)
+
+// imports are limited to this many rows, larger logs can be split by date
+const MaxRows = 10000
+
+var (
+	ErrUnknownFormat = errors.New("the columns of the file do not match any supported format")
+	ErrEmptyFile     = errors.New("the file has no rows")
+	ErrTooManyRows   = fmt.Errorf("the file has more than %d rows", MaxRows)
+)
+
+// error found in a row of the file, rows are numbered like in a spreadsheet so the header is row 1
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// outcome of reading a file, the workouts are only created when there are no errors
+type Report struct {
+	Format   string     `json:"format"`
+	Rows     int        `json:"rows"`
+	Workouts int        `json:"workouts"`
+	Entries  int        `json:"entries"`
+	Errors   []RowError `json:"errors"`
+}
+
+// a row of the file mapped by an adapter, rows with the same key belong to the same workout
+type record struct {
+	key             string
+	performedAt     time.Time
+	title           string
+	description     string
+	status          string
+	durationMinutes int
+	caloriesBurned  int
+	// the workout has no entries when the exercise is empty
+	entry store.WorkoutEntry
+}
+
+// maps the rows of a csv layout to records, perSet layouts have one row per set which are merged into entries
+type Adapter struct {
+	Name    string
+	perSet  bool
+	matches func(header columns) bool
+	read    func(row columns) (record, error)
+}
+
+// values of a row looked up by the lower cased name of their column
+type columns struct {
+	index  map[string]int
+	values []string
+}
+
+func (c columns) has(name string) bool {
+	_, ok := c.index[strings.ToLower(name)]
+	return ok
+}
+
+func (c columns) get(name string) string {
+	i, ok := c.index[strings.ToLower(name)]
+	if !ok || i >= len(c.values) {
+		return ""
+	}
+	return strings.TrimSpace(c.values[i])
+}
+
+// reads the file with the adapter named by format, or with the first adapter matching the header when format is empty,
+// the workouts are returned together with the report and are not valid when the report has errors
+func Parse(r io.Reader, format string) ([]*store.Workout, *Report, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, ErrEmptyFile
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	//spreadsheet programs start utf-8 files with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	names := columns{index: map[string]int{}, values: header}
+	for i, name := range header {
+		names.index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	adapter, err := findAdapter(format, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &Report{Format: adapter.Name, Errors: []RowError{}}
+	builder := newWorkoutBuilder(adapter.perSet)
+
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		report.Rows++
+		if report.Rows > MaxRows {
+			return nil, nil, ErrTooManyRows
+		}
+
+		row := report.Rows + 1
+
+		if isBlank(values) {
+			continue
+		}
+
+		rec, err := adapter.read(columns{index: names.index, values: values})
+		if err != nil {
+			//the adapters join every invalid cell of the row into one error
+			report.Errors = append(report.Errors, RowError{Row: row, Error: strings.ReplaceAll(err.Error(), "\n", "; ")})
+			continue
+		}
+
+		err = builder.add(row, rec)
+		if err != nil {
+			report.Errors = append(report.Errors, RowError{Row: row, Error: err.Error()})
+		}
+	}
+
+	if report.Rows == 0 {
+		return nil, nil, ErrEmptyFile
+	}
+
+	report.Errors = append(report.Errors, builder.validate()...)
+	slices.SortStableFunc(report.Errors, func(a, b RowError) int { return a.Row - b.Row })
+
+	report.Workouts = len(builder.workouts)
+	for _, workout := range builder.workouts {
+		report.Entries += len(workout.Entries)
+	}
+
+	return builder.workouts, report, nil
+}
+
+func findAdapter(format string, header columns) (*Adapter, error) {
+	for i := range Adapters {
+		adapter := &Adapters[i]
+
+		if format != "" && adapter.Name == format {
+			if !adapter.matches(header) {
+				return nil, fmt.Errorf("the columns of the file do not match the %s format", format)
+			}
+			return adapter, nil
+		}
+
+		if format == "" && adapter.matches(header) {
+			return adapter, nil
+		}
+	}
+
+	if format != "" {
+		return nil, fmt.Errorf("format must be one of %s", strings.Join(AdapterNames(), ", "))
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+func AdapterNames() []string {
+	names := []string{}
+	for _, adapter := range Adapters {
+		names = append(names, adapter.Name)
+	}
+	return names
+}
+
+func isBlank(values []string) bool {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// groups the records into workouts in the order the workouts first appear in the file
+type workoutBuilder struct {
+	perSet   bool
+	workouts []*store.Workout
+	byKey    map[string]*store.Workout
+	// first row of every entry, used to report the errors of an entry
+	entryRows map[*store.Workout][]int
+	// rows of the workout, used to report the errors of a workout
+	workoutRows map[*store.Workout]int
+}
+
+func newWorkoutBuilder(perSet bool) *workoutBuilder {
+	return &workoutBuilder{
+		perSet:      perSet,
+		byKey:       map[string]*store.Workout{},
+		entryRows:   map[*store.Workout][]int{},
+		workoutRows: map[*store.Workout]int{},
+	}
+}
+
+func (b *workoutBuilder) add(row int, rec record) error {
+	if rec.title == "" {
+		return errors.New("title is required")
+	}
+
+	if rec.performedAt.IsZero() {
+		return errors.New("the date of the workout is required")
+	}
+
+	if rec.status != "" && !slices.Contains(store.WorkoutStatuses, rec.status) {
+		return fmt.Errorf("status must be one of %s", strings.Join(store.WorkoutStatuses, ", "))
+	}
+
+	workout, ok := b.byKey[rec.key]
+	if !ok {
+		workout = &store.Workout{
+			Title:           rec.title,
+			Description:     rec.description,
+			DurationMinutes: rec.durationMinutes,
+			CaloriesBurned:  rec.caloriesBurned,
+			PerformedAt:     rec.performedAt,
+			Status:          rec.status,
+			Entries:         []store.WorkoutEntry{},
+		}
+
+		b.byKey[rec.key] = workout
+		b.workouts = append(b.workouts, workout)
+		b.workoutRows[workout] = row
+	}
+
+	if rec.entry.ExerciseName == "" {
+		return nil
+	}
+
+	entries := workout.Entries
+	if b.perSet && len(entries) > 0 && sameSet(entries[len(entries)-1], rec.entry) {
+		entries[len(entries)-1].Sets++
+		return nil
+	}
+
+	rec.entry.OrderIndex = len(entries) + 1
+	workout.Entries = append(entries, rec.entry)
+	b.entryRows[workout] = append(b.entryRows[workout], row)
+
+	return nil
+}
+
+// checks the workouts and entries the same way the handlers check a workout sent as json, then checks that the values
+// fit the columns they are stored in so a file that passes the dry run does not fail the insert
+func (b *workoutBuilder) validate() []RowError {
+	errs := []RowError{}
+
+	for _, workout := range b.workouts {
+		if workout.DurationMinutes < 0 || workout.CaloriesBurned < 0 {
+			errs = append(errs, RowError{Row: b.workoutRows[workout], Error: "duration and calories cannot be negative"})
+		} else if err := workoutFitsColumns(workout); err != nil {
+			errs = append(errs, RowError{Row: b.workoutRows[workout], Error: err.Error()})
+		}
+
+		for i := range workout.Entries {
+			err := store.ValidateEntry(&workout.Entries[i])
+			if err == nil {
+				err = entryFitsColumns(&workout.Entries[i])
+			}
+
+			if err != nil {
+				errs = append(errs, RowError{Row: b.entryRows[workout][i], Error: err.Error()})
+			}
+		}
+	}
+
+	return errs
+}
+
+// limits of the column types of the workouts and workout_entries tables
+const (
+	maxTextLength = 255
+	maxInteger    = math.MaxInt32
+)
+
+func workoutFitsColumns(workout *store.Workout) error {
+	if utf8.RuneCountInString(workout.Title) > maxTextLength {
+		return fmt.Errorf("title cannot be longer than %d characters", maxTextLength)
+	}
+
+	if workout.DurationMinutes > maxInteger || workout.CaloriesBurned > maxInteger {
+		return fmt.Errorf("duration and calories cannot be above %d", maxInteger)
+	}
+
+	return nil
+}
+
+func entryFitsColumns(entry *store.WorkoutEntry) error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("entry %d: %s", entry.OrderIndex, fmt.Sprintf(format, args...))
+	}
+
+	if utf8.RuneCountInString(entry.ExerciseName) > maxTextLength {
+		return invalid("exercise_name cannot be longer than %d characters", maxTextLength)
+	}
+
+	for _, value := range []*int{&entry.Sets, entry.Reps, entry.DurationSeconds} {
+		if value != nil && *value > maxInteger {
+			return invalid("sets, reps and duration_seconds cannot be above %d", maxInteger)
+		}
+	}
+
+	for _, column := range []struct {
+		name             string
+		value            *float64
+		precision, scale int
+	}{
+		{"weight", entry.Weight, 5, 2},
+		{"distance", entry.Distance, 10, 3},
+		{"elevation_gain_meters", entry.ElevationGainMeters, 7, 1},
+	} {
+		if column.value != nil && !fitsNumeric(*column.value, column.precision, column.scale) {
+			return invalid("%s must be below %.0f", column.name, math.Pow10(column.precision-column.scale))
+		}
+	}
+
+	return nil
+}
+
+// reports whether the value fits a NUMERIC(precision, scale) column once rounded to its scale
+func fitsNumeric(value float64, precision, scale int) bool {
+	rounded := math.Round(value*math.Pow10(scale)) / math.Pow10(scale)
+	return math.Abs(rounded) < math.Pow10(precision-scale)
+}
+
+// consecutive sets of an exercise with the same load are merged into one entry with more sets
+func sameSet(entry, set store.WorkoutEntry) bool {
+	return strings.EqualFold(entry.ExerciseName, set.ExerciseName) &&
+		entry.Notes == set.Notes &&
+		equalInt(entry.Reps, set.Reps) &&
+		equalInt(entry.DurationSeconds, set.DurationSeconds) &&
+		equalFloat(entry.Weight, set.Weight) &&
+		entry.Distance == nil && set.Distance == nil
+}
+
+func equalInt(a, b *int) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func equalFloat(a, b *float64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// parsers of optional cells, an empty cell is nil and zero is treated as empty when zeroIsEmpty is set
+// because several apps fill the columns that do not apply to a set with 0
+
+func parseInt(column, value string, zeroIsEmpty bool) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f != float64(int(f)) {
+		return nil, fmt.Errorf("%s must be a whole number", column)
+	}
+
+	i := int(f)
+	if zeroIsEmpty && i == 0 {
+		return nil, nil
+	}
+
+	return &i, nil
+}
+
+func parseFloat(column, value string, zeroIsEmpty bool) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", column)
+	}
+
+	if zeroIsEmpty && f == 0 {
+		return nil, nil
+	}
+
+	return &f, nil
+}
+
+func parseString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/store"
+)
+
+const genericCSV = `workout_id,performed_at,title,description,status,duration_minutes,calories_burned,entry_id,order_index,exercise_id,exercise_name,entry_type,sets,reps,weight,duration_seconds,distance,distance_unit,avg_heart_rate,max_heart_rate,elevation_gain_meters,pace_seconds_per_km,notes
+1,2025-03-10T07:00:00Z,"'=HYPERLINK(""x"")",,completed,60,0,10,1,3,Bench Press,strength,3,5,82.5,,,,,,,,"paused, last set"
+1,2025-03-10T07:00:00Z,"'=HYPERLINK(""x"")",,completed,60,0,11,2,4,Running,cardio,1,,,1500,5,km,,,,300,
+2,2025-03-11T07:00:00Z,rest day walk,,completed,20,0,,,,,,,,,,,,,,,,
+`
+
+const strongCSV = "\ufeff" + `Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Reps,Distance,Seconds,Notes,Workout Notes,RPE
+2025-03-10 07:00:00,Push Day,1h 5m,Bench Press (Barbell),1,80,5,0,0,,felt strong,
+2025-03-10 07:00:00,Push Day,1h 5m,Bench Press (Barbell),2,80,5,0,0,,felt strong,
+2025-03-10 07:00:00,Push Day,1h 5m,Bench Press (Barbell),3,85,3,0,0,,felt strong,
+2025-03-10 07:00:00,Push Day,1h 5m,Plank,1,0,0,0,60,,felt strong,
+2025-03-12 18:30:00,Cardio,30m,Running,1,0,0,5.2,1800,,,
+`
+
+const hevyCSV = `title,start_time,end_time,description,exercise_title,superset_id,exercise_notes,set_index,set_type,weight_kg,reps,distance_km,duration_seconds,rpe
+Leg Day,"10 Mar 2025, 07:00","10 Mar 2025, 08:10",,Squat (Barbell),,,0,normal,100,5,,,
+Leg Day,"10 Mar 2025, 07:00","10 Mar 2025, 08:10",,Squat (Barbell),,,1,normal,100,5,,,
+Leg Day,"10 Mar 2025, 07:00","10 Mar 2025, 08:10",,Squat (Barbell),,,2,normal,100,5,,,
+`
+
+func TestParse(t *testing.T) {
+	workouts, report, err := Parse(strings.NewReader(genericCSV), "")
+	require.NoError(t, err)
+
+	assert.Equal(t, &Report{Format: "generic", Rows: 3, Workouts: 2, Entries: 2, Errors: []RowError{}}, report)
+	require.Len(t, workouts, 2)
+
+	push := workouts[0]
+	assert.Equal(t, `=HYPERLINK("x")`, push.Title)
+	assert.Equal(t, 60, push.DurationMinutes)
+	assert.True(t, push.PerformedAt.Equal(time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)))
+	require.Len(t, push.Entries, 2)
+	assert.Equal(t, "Bench Press", push.Entries[0].ExerciseName)
+	assert.Equal(t, 3, push.Entries[0].Sets)
+	assert.Equal(t, 82.5, *push.Entries[0].Weight)
+	assert.Equal(t, "paused, last set", push.Entries[0].Notes)
+	assert.Equal(t, store.EntryCardio, push.Entries[1].EntryType)
+	assert.Equal(t, "km", *push.Entries[1].DistanceUnit)
+	assert.Equal(t, 2, push.Entries[1].OrderIndex)
+
+	assert.Empty(t, workouts[1].Entries)
+}
+
+func TestParseAdapters(t *testing.T) {
+	strongPoundsCSV := `Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Weight Unit,Reps,Distance,Seconds,Notes,Workout Notes
+2025-03-10 07:00:00,Push Day,1h,Bench Press (Barbell),1,225,lbs,5,0,0,,,
+2025-03-10 07:00:00,Push Day,1h,Bench Press (Barbell),2,225,lbs,5,0,0,,,
+2025-03-10 07:00:00,Push Day,1h,Overhead Press (Barbell),1,60,kg,5,0,0,,,
+`
+
+	tests := []struct {
+		name       string
+		file       string
+		format     string
+		wantFormat string
+		check      func(t *testing.T, workouts []*store.Workout)
+	}{
+		{
+			name:       "strong sets with the same load are merged",
+			file:       strongCSV,
+			wantFormat: "strong",
+			check: func(t *testing.T, workouts []*store.Workout) {
+				require.Len(t, workouts, 2)
+
+				push := workouts[0]
+				assert.Equal(t, "Push Day", push.Title)
+				assert.Equal(t, "felt strong", push.Description)
+				assert.Equal(t, 65, push.DurationMinutes)
+				require.Len(t, push.Entries, 3)
+				assert.Equal(t, 2, push.Entries[0].Sets)
+				assert.Equal(t, 85.0, *push.Entries[1].Weight)
+				assert.Equal(t, store.EntryTimed, push.Entries[2].EntryType)
+				assert.Nil(t, push.Entries[2].Weight)
+
+				run := workouts[1].Entries[0]
+				assert.Equal(t, store.EntryCardio, run.EntryType)
+				assert.Equal(t, 5.2, *run.Distance)
+				assert.Equal(t, 1800, *run.DurationSeconds)
+			},
+		},
+		{
+			name:       "strong weights in pounds are converted to kilograms",
+			file:       strongPoundsCSV,
+			wantFormat: "strong",
+			check: func(t *testing.T, workouts []*store.Workout) {
+				require.Len(t, workouts, 1)
+				require.Len(t, workouts[0].Entries, 2)
+				assert.Equal(t, 2, workouts[0].Entries[0].Sets)
+				assert.Equal(t, 102.06, *workouts[0].Entries[0].Weight)
+				assert.Equal(t, 60.0, *workouts[0].Entries[1].Weight)
+			},
+		},
+		{
+			name:       "hevy duration comes from the start and end",
+			file:       hevyCSV,
+			format:     "hevy",
+			wantFormat: "hevy",
+			check: func(t *testing.T, workouts []*store.Workout) {
+				require.Len(t, workouts, 1)
+				assert.Equal(t, 70, workouts[0].DurationMinutes)
+				require.Len(t, workouts[0].Entries, 1)
+				assert.Equal(t, 3, workouts[0].Entries[0].Sets)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workouts, report, err := Parse(strings.NewReader(tt.file), tt.format)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantFormat, report.Format)
+			assert.Empty(t, report.Errors)
+			tt.check(t, workouts)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		format     string
+		wantErr    string
+		wantErrors []RowError
+	}{
+		{
+			name:    "unknown columns",
+			file:    "date,what\n2025-03-10,run\n",
+			wantErr: "the columns of the file do not match any supported format",
+		},
+		{
+			name:    "format that does not match the header",
+			file:    genericCSV,
+			format:  "strong",
+			wantErr: "the columns of the file do not match the strong format",
+		},
+		{
+			name:    "unknown format",
+			file:    genericCSV,
+			format:  "fitbod",
+			wantErr: "format must be one of generic, strong, hevy",
+		},
+		{
+			name:    "header only",
+			file:    "performed_at,title,exercise_name\n",
+			wantErr: "the file has no rows",
+		},
+		{
+			name: "row level errors",
+			file: `performed_at,title,exercise_name,sets,reps,weight,duration_seconds,status
+2025-03-10,push,bench press,3,five,80,,
+yesterday,pull,row,3,5,60,,
+2025-03-10,legs,squat,3,5,100,60,
+2025-03-11,,squat,3,5,100,,
+2025-03-12,run,running,1,,,1800,finished
+`,
+			wantErrors: []RowError{
+				{Row: 2, Error: "reps must be a whole number"},
+				{Row: 3, Error: "performed_at is not a valid time"},
+				{Row: 4, Error: "entry 1: strength entries need reps and no duration_seconds"},
+				{Row: 5, Error: "title is required"},
+				{Row: 6, Error: "status must be one of planned, completed, skipped"},
+			},
+		},
+		{
+			name: "unknown strong weight unit",
+			file: `Date,Workout Name,Duration,Exercise Name,Set Order,Weight,Weight Unit,Reps,Distance,Seconds,Notes,Workout Notes
+2025-03-10 07:00:00,Push Day,1h,Bench Press (Barbell),1,16,st,5,0,0,,,
+`,
+			wantErrors: []RowError{{Row: 2, Error: "Weight Unit must be kg or lbs"}},
+		},
+		{
+			name: "values that do not fit the columns",
+			file: `performed_at,title,exercise_name,entry_type,sets,reps,weight,distance,distance_unit,duration_minutes
+2025-03-10,push,bench press,,3,5,1000,,,
+2025-03-11,pull,row,,3,5,999.995,,,
+2025-03-12,run,running,cardio,1,,,10000000,m,
+2025-03-13,legs,squat,,3,5,999.99,,,3000000000
+`,
+			wantErrors: []RowError{
+				{Row: 2, Error: "entry 1: weight must be below 1000"},
+				{Row: 3, Error: "entry 1: weight must be below 1000"},
+				{Row: 4, Error: "entry 1: distance must be below 10000000"},
+				{Row: 5, Error: "duration and calories cannot be above 2147483647"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, report, err := Parse(strings.NewReader(tt.file), tt.format)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantErrors, report.Errors)
+		})
+	}
+}
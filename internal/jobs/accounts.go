@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// part of the user store needed to remove the accounts whose deletion grace period ended
+type ScheduledUserDeleter interface {
+	DeleteScheduledUsers(ctx context.Context, before time.Time) (int64, error)
+}
+
+// job that deletes every account scheduled for deletion before the tick
+func AccountReaper(userStore ScheduledUserDeleter, logger *slog.Logger) Func {
+	return func(ctx context.Context, now time.Time) error {
+		deleted, err := userStore.DeleteScheduledUsers(ctx, now)
+		if err != nil {
+			return err
+		}
+
+		if deleted > 0 {
+			logger.InfoContext(ctx, "deleted scheduled accounts", "count", deleted)
+		}
+
+		return nil
+	}
+}
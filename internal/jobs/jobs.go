@@ -0,0 +1,99 @@
+package jobs
+
+//periodic background work running alongside the http server
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// a job receives the time of the tick that triggered it
+type Func func(ctx context.Context, now time.Time) error
+
+// source of time for the runner, replaced with a fake one in tests
+type Clock interface {
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// clock backed by the time package
+type RealClock struct{}
+
+func (RealClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t *realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      Func
+}
+
+type Runner struct {
+	clock  Clock
+	logger *slog.Logger
+	jobs   []job
+	wg     sync.WaitGroup
+}
+
+func NewRunner(clock Clock, logger *slog.Logger) *Runner {
+	return &Runner{
+		clock:  clock,
+		logger: logger,
+	}
+}
+
+// registers a job to be run every interval, jobs have to be registered before the runner is started
+func (r *Runner) Register(name string, interval time.Duration, run Func) {
+	r.jobs = append(r.jobs, job{name: name, interval: interval, run: run})
+}
+
+// starts every registered job in its own goroutine, the jobs stop when the context is cancelled
+func (r *Runner) Start(ctx context.Context) {
+	for _, j := range r.jobs {
+		r.wg.Add(1)
+		go r.loop(ctx, j)
+	}
+}
+
+// blocks until every job returned after the context passed to Start was cancelled
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+func (r *Runner) loop(ctx context.Context, j job) {
+	defer r.wg.Done()
+
+	ticker := r.clock.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C():
+			//a failing run is only logged, the job is retried on the next tick
+			err := j.run(ctx, now)
+			if err != nil && ctx.Err() == nil {
+				r.logger.Error("job failed", "job", j.name, "error", err)
+			}
+		}
+	}
+}
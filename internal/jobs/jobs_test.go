@@ -0,0 +1,170 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clock whose tickers only fire when the test sends a tick
+type fakeClock struct {
+	mu      sync.Mutex
+	tickers []*fakeTicker
+	created chan struct{}
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{created: make(chan struct{}, 10)}
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ticker := &fakeTicker{c: make(chan time.Time)}
+	c.tickers = append(c.tickers, ticker)
+	c.created <- struct{}{}
+
+	return ticker
+}
+
+// blocks until the ticker of the nth job was created and sends it a tick
+func (c *fakeClock) tick(t *testing.T, index int, now time.Time) {
+	c.mu.Lock()
+	ticker := c.tickers[index]
+	c.mu.Unlock()
+
+	select {
+	case ticker.c <- now:
+	case <-time.After(time.Second):
+		t.Fatal("job did not receive tick")
+	}
+}
+
+func (c *fakeClock) waitForTickers(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.created:
+		case <-time.After(time.Second):
+			t.Fatal("ticker was not created")
+		}
+	}
+}
+
+type fakeTicker struct {
+	c       chan time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.stopped = true
+}
+
+type fakeTokenStore struct {
+	calls chan time.Time
+	err   error
+}
+
+func (s *fakeTokenStore) DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error) {
+	err := s.err
+	s.calls <- before
+	return 1, err
+}
+
+func TestRunnerRunsJobOnEveryTick(t *testing.T) {
+	clock := newFakeClock()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tokenStore := &fakeTokenStore{calls: make(chan time.Time, 10)}
+
+	runner := NewRunner(clock, logger)
+	runner.Register("token reaper", time.Hour, TokenReaper(tokenStore, logger))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.Start(ctx)
+	clock.waitForTickers(t, 1)
+
+	first := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	clock.tick(t, 0, first)
+	assert.Equal(t, first, <-tokenStore.calls)
+
+	//an error does not stop the job
+	tokenStore.err = errors.New("database is down")
+	clock.tick(t, 0, second)
+	assert.Equal(t, second, <-tokenStore.calls)
+
+	cancel()
+	runner.Wait()
+
+	assert.True(t, clock.tickers[0].stopped)
+}
+
+func TestRunnerStopsOnContextCancel(t *testing.T) {
+	clock := newFakeClock()
+	runner := NewRunner(clock, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	started := make(chan struct{})
+	runner.Register("blocking", time.Minute, func(ctx context.Context, now time.Time) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.Start(ctx)
+	clock.waitForTickers(t, 1)
+
+	clock.tick(t, 0, time.Now())
+	<-started
+
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		runner.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		require.Fail(t, "runner did not stop after cancel")
+	}
+}
+
+type fakeUserStore struct {
+	before  time.Time
+	deleted int64
+	err     error
+}
+
+func (s *fakeUserStore) DeleteScheduledUsers(ctx context.Context, before time.Time) (int64, error) {
+	s.before = before
+	return s.deleted, s.err
+}
+
+func TestAccountReaper(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	userStore := &fakeUserStore{deleted: 2}
+	err := AccountReaper(userStore, logger)(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, now, userStore.before)
+
+	userStore.err = errors.New("database is down")
+	err = AccountReaper(userStore, logger)(context.Background(), now)
+	assert.Error(t, err)
+}
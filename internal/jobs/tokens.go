@@ -0,0 +1,28 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// part of the token store needed to purge expired tokens
+type ExpiredTokenDeleter interface {
+	DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error)
+}
+
+// job that removes every token which expired before the tick
+func TokenReaper(tokenStore ExpiredTokenDeleter, logger *slog.Logger) Func {
+	return func(ctx context.Context, now time.Time) error {
+		deleted, err := tokenStore.DeleteExpiredTokens(ctx, now)
+		if err != nil {
+			return err
+		}
+
+		if deleted > 0 {
+			logger.InfoContext(ctx, "deleted expired tokens", "count", deleted)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+package logging
+
+//structured logging shared by the whole application
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "[REDACTED]"
+
+// attribute keys whose values are never written to the logs
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"password":      true,
+	"token":         true,
+	"auth_token":    true,
+	"refresh_token": true,
+	"dsn":           true,
+	"database_dsn":  true,
+	"cookie":        true,
+}
+
+// creates a json logger writing records at or above the given level ("debug", "info", "warn", "error")
+// records logged with a request context are tagged with the request id and the authenticated user id
+func New(w io.Writer, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+
+	err := lvl.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, err
+	}
+
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       lvl,
+		ReplaceAttr: redact,
+	})
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// replaces the values of sensitive attributes, applied to every attribute including nested groups
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+
+	return attr
+}
+
+// per request details shared between the middlewares, the pointer is stored in the context so inner middlewares
+// can fill in details such as the user id that outer middlewares log once the request is done
+type RequestInfo struct {
+	ID     string
+	UserID int
+}
+
+type contextKey string
+
+const requestInfoContextKey = contextKey("requestInfo")
+
+func WithRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoContextKey, info)
+}
+
+// returns the details of the current request, nil outside of a request
+func GetRequestInfo(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestInfoContextKey).(*RequestInfo)
+	return info
+}
+
+// records the authenticated user of the current request
+func SetUserID(ctx context.Context, userID int) {
+	info := GetRequestInfo(ctx)
+	if info != nil {
+		info.UserID = userID
+	}
+}
+
+// adds the request details and the trace id of the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	info := GetRequestInfo(ctx)
+
+	if info != nil {
+		record.AddAttrs(slog.String("request_id", info.ID))
+
+		if info.UserID != 0 {
+			record.AddAttrs(slog.Int("user_id", info.UserID))
+		}
+	}
+
+	spanContext := trace.SpanContextFromContext(ctx)
+	if spanContext.IsValid() {
+		record.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggerRedactsAndTagsRequests(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger, err := New(&buf, "info")
+	require.NoError(t, err)
+
+	ctx := WithRequestInfo(context.Background(), &RequestInfo{ID: "req-1"})
+	SetUserID(ctx, 42)
+
+	logger.InfoContext(ctx, "login", "Authorization", "Bearer secret", "refresh_token", "secret", "username", "jack")
+	logger.Debug("hidden below the configured level")
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+
+	assert.Equal(t, "login", record["msg"])
+	assert.Equal(t, "req-1", record["request_id"])
+	assert.Equal(t, float64(42), record["user_id"])
+	assert.Equal(t, redacted, record["Authorization"])
+	assert.Equal(t, redacted, record["refresh_token"])
+	assert.Equal(t, "jack", record["username"])
+}
+
+func TestNewRejectsUnknownLevel(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "verbose")
+	assert.Error(t, err)
+}
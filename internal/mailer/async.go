@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// a background send that does not finish in this time is abandoned
+const asyncSendTimeout = 30 * time.Second
+
+// sends the messages of the wrapped mailer in the background so a request does not wait for the delivery and its
+// response time does not tell whether an email was sent, failed deliveries are only logged
+type AsyncMailer struct {
+	mailer Mailer
+	logger *slog.Logger
+	wg     sync.WaitGroup
+}
+
+func NewAsyncMailer(m Mailer, logger *slog.Logger) *AsyncMailer {
+	return &AsyncMailer{mailer: m, logger: logger}
+}
+
+// returns right away, the send keeps the values of ctx but not its cancellation and gets its own timeout
+func (m *AsyncMailer) Send(ctx context.Context, msg Message) error {
+	ctx = context.WithoutCancel(ctx)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ctx, cancel := context.WithTimeout(ctx, asyncSendTimeout)
+		defer cancel()
+
+		err := m.mailer.Send(ctx, msg)
+		if err != nil {
+			m.logger.ErrorContext(ctx, "sendingEmail", "subject", msg.Subject, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// blocks until every pending send returned
+func (m *AsyncMailer) Wait() {
+	m.wg.Wait()
+}
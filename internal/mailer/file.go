@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+)
+
+// writes every email to an .eml file of dir instead of sending it, the files open in any mail client
+type FileMailer struct {
+	dir    string
+	sender string
+}
+
+func NewFileMailer(dir, sender string) (*FileMailer, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileMailer{dir: dir, sender: sender}, nil
+}
+
+// the files are named after the time and the recipient so they sort in the order they were sent
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+
+	content, err := msg.bytes(m.sender, now)
+	if err != nil {
+		return err
+	}
+
+	recipient := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>| `, r) {
+			return '_'
+		}
+		return r
+	}, address(msg.To))
+
+	file, err := os.CreateTemp(m.dir, now.UTC().Format("20060102T150405.000")+"-"+recipient+"-*.eml")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(content)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
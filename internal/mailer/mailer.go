@@ -0,0 +1,123 @@
+package mailer
+
+//delivery of the emails sent to users, smtp is used in production while the file backend keeps the emails on disk for local testing
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BackendSMTP = "smtp"
+	BackendFile = "file"
+)
+
+var Backends = []string{BackendSMTP, BackendFile}
+
+var ErrInvalidHeader = errors.New("email headers cannot contain line breaks")
+
+// plain text email sent to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// settings of the smtp backend, the password can be empty for servers that do not require authentication
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+// builds the mailer of the given backend, emails are sent from sender
+func New(backend, sender string, smtpConfig SMTPConfig, dir string) (Mailer, error) {
+	switch backend {
+	case BackendSMTP:
+		return NewSMTPMailer(smtpConfig, sender), nil
+	case BackendFile:
+		return NewFileMailer(dir, sender)
+	}
+
+	return nil, fmt.Errorf("unknown mailer backend %q", backend)
+}
+
+// formats the message as an rfc 5322 email with a quoted-printable utf-8 body
+func (msg Message) bytes(from string, date time.Time) ([]byte, error) {
+	for _, header := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(header, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	writer := quotedprintable.NewWriter(&buf)
+
+	_, err := writer.Write([]byte(strings.ReplaceAll(msg.Body, "\n", "\r\n")))
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// bare address of a header like "femProject <no-reply@example.com>", used for the smtp envelope
+func address(header string) string {
+	parsed, err := mail.ParseAddress(header)
+	if err != nil {
+		return header
+	}
+	return parsed.Address
+}
+
+// keeps the sent messages in memory, used by the tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// returns a copy of the messages sent so far
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message{}, m.messages...)
+}
@@ -0,0 +1,127 @@
+package mailer
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageBytes(t *testing.T) {
+	date := time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		msg     Message
+		wantErr error
+		want    []string
+	}{
+		{
+			name: "headers and body",
+			msg:  Message{To: "sabin@example.com", Subject: "Reset your password", Body: "line one\nline two"},
+			want: []string{
+				"From: femProject <no-reply@example.com>\r\n",
+				"To: sabin@example.com\r\n",
+				"Subject: Reset your password\r\n",
+				"Date: Mon, 10 Mar 2025 07:00:00 +0000\r\n",
+				"Content-Type: text/plain; charset=utf-8\r\n",
+				"\r\n\r\nline one\r\nline two",
+			},
+		},
+		{
+			name: "non ascii subject is encoded",
+			msg:  Message{To: "sabin@example.com", Subject: "Parolă nouă", Body: "ă"},
+			want: []string{"Subject: =?utf-8?q?Parol=C4=83_nou=C4=83?=\r\n", "=C4=83"},
+		},
+		{
+			name:    "line breaks in headers are refused",
+			msg:     Message{To: "sabin@example.com\r\nBcc: everyone@example.com", Subject: "hi"},
+			wantErr: ErrInvalidHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := tt.msg.bytes("femProject <no-reply@example.com>", date)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				assert.Contains(t, string(content), want)
+			}
+		})
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+
+	m, err := New(BackendFile, "no-reply@example.com", SMTPConfig{}, dir)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, m.Send(context.Background(), Message{To: "Sabin <sabin@example.com>", Subject: "hello", Body: "hi"}))
+	}
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	assert.True(t, strings.HasSuffix(files[0].Name(), ".eml"))
+	assert.Contains(t, files[0].Name(), "sabin@example.com")
+
+	content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: Sabin <sabin@example.com>\r\n")
+}
+
+func TestNewUnknownBackend(t *testing.T) {
+	_, err := New("pigeon", "no-reply@example.com", SMTPConfig{}, "")
+	assert.Error(t, err)
+}
+
+// mailer holding every send until release is closed
+type blockingMailer struct {
+	*MemoryMailer
+	release chan struct{}
+}
+
+func (m *blockingMailer) Send(ctx context.Context, msg Message) error {
+	<-m.release
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return m.MemoryMailer.Send(ctx, msg)
+}
+
+func TestAsyncMailer(t *testing.T) {
+	blocking := &blockingMailer{MemoryMailer: NewMemoryMailer(), release: make(chan struct{})}
+	m := NewAsyncMailer(blocking, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	//the request is over by the time the email is delivered
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, m.Send(ctx, Message{To: "sabin@example.com", Subject: "Reset your password"}))
+	cancel()
+
+	assert.Empty(t, blocking.Messages())
+
+	close(blocking.release)
+	m.Wait()
+
+	messages := blocking.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "sabin@example.com", messages[0].To)
+}
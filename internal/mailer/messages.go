@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"fmt"
+	"time"
+)
+
+// email with the token that sets a new password through PUT /users/password
+func PasswordResetMessage(to, username, token string, ttl time.Duration) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your femProject password",
+		Body: fmt.Sprintf(`Hi %s,
+
+someone asked to reset the password of your femProject account.
+To choose a new password send a PUT /users/password request with the body
+
+{"token": "%s", "password": "your new password"}
+
+The token can be used once and expires in %d minutes. Every session of the account is logged out once the password is reset.
+If you did not ask for a reset you can ignore this email, your password stays the same.
+`, username, token, int(ttl.Minutes())),
+	}
+}
+
+// email with the token that verifies the address through PUT /users/activated
+func ActivationMessage(to, username, token string, ttl time.Duration) Message {
+	return Message{
+		To:      to,
+		Subject: "Activate your femProject account",
+		Body: fmt.Sprintf(`Hi %s,
+
+thanks for signing up to femProject.
+To verify your email and activate your account send a PUT /users/activated request with the body
+
+{"token": "%s"}
+
+The token expires in %d hours, a new one can be requested with POST /tokens/activation.
+If you did not create an account you can ignore this email.
+`, username, token, int(ttl.Hours())),
+	}
+}
@@ -0,0 +1,99 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// a send that does not finish in this time is abandoned when the context has no deadline
+const smtpTimeout = 30 * time.Second
+
+// sends emails through an smtp server, the connection is upgraded with STARTTLS when the server offers it
+type SMTPMailer struct {
+	config SMTPConfig
+	sender string
+}
+
+func NewSMTPMailer(config SMTPConfig, sender string) *SMTPMailer {
+	return &SMTPMailer{config: config, sender: sender}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	content, err := msg.bytes(m.sender, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port)))
+	if err != nil {
+		return err
+	}
+
+	//net/smtp does not take a context so the deadline is set on the connection
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		err = client.StartTLS(&tls.Config{ServerName: m.config.Host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.config.Username != "" {
+		//PlainAuth refuses to send the password over a connection that is not encrypted, except to localhost
+		err = client.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(address(m.sender))
+	if err != nil {
+		return err
+	}
+
+	err = client.Rcpt(address(msg.To))
+	if err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(content)
+	if err != nil {
+		return err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,107 @@
+package metrics
+
+//prometheus metrics of the application, exposed on /metrics
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// every method is safe to call on a nil *Metrics so stores and handlers can be used without metrics in tests
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	tokensIssued    *prometheus.CounterVec
+	authFailures    *prometheus.CounterVec
+	workoutsCreated prometheus.Counter
+}
+
+// creates the metrics in their own registry together with the go runtime, process and database pool collectors
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of http requests by route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of http requests by route pattern and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		tokensIssued: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tokens_issued_total",
+			Help: "Number of tokens issued by scope.",
+		}, []string{"scope"}),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "authentication_failures_total",
+			Help: "Number of failed authentications by reason.",
+		}, []string{"reason"}),
+		workoutsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "workouts_created_total",
+			Help: "Number of workouts created.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.tokensIssued,
+		m.authFailures,
+		m.workoutsCreated,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, "postgres"))
+	}
+
+	return m
+}
+
+// handler serving the metrics in the prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	statusLabel := strconv.Itoa(status)
+	m.requests.WithLabelValues(method, route, statusLabel).Inc()
+	m.requestDuration.WithLabelValues(method, route, statusLabel).Observe(duration.Seconds())
+}
+
+func (m *Metrics) TokenIssued(scope string) {
+	if m == nil {
+		return
+	}
+
+	m.tokensIssued.WithLabelValues(scope).Inc()
+}
+
+// reasons should be a small fixed set such as "invalid_header" or "invalid_credentials" to keep the label cardinality low
+func (m *Metrics) AuthenticationFailed(reason string) {
+	if m == nil {
+		return
+	}
+
+	m.authFailures.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) WorkoutCreated() {
+	if m == nil {
+		return
+	}
+
+	m.workoutsCreated.Inc()
+}
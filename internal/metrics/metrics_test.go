@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerExposesRecordedMetrics(t *testing.T) {
+	m := New(nil)
+
+	m.ObserveRequest(http.MethodGet, "/workout/{id}", http.StatusOK, 20*time.Millisecond)
+	m.TokenIssued("refresh")
+	m.AuthenticationFailed("invalid_token")
+	m.WorkoutCreated()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/workout/{id}",status="200"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/workout/{id}",status="200"} 1`)
+	assert.Contains(t, body, `tokens_issued_total{scope="refresh"} 1`)
+	assert.Contains(t, body, `authentication_failures_total{reason="invalid_token"} 1`)
+	assert.Contains(t, body, `workouts_created_total 1`)
+}
+
+func TestNilMetricsAreNoops(t *testing.T) {
+	var m *Metrics
+
+	assert.NotPanics(t, func() {
+		m.ObserveRequest(http.MethodGet, "/", http.StatusOK, time.Millisecond)
+		m.TokenIssued("refresh")
+		m.AuthenticationFailed("invalid_token")
+		m.WorkoutCreated()
+	})
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/metrics"
+)
+
+// counts requests and records their latency labeled by route pattern, unmatched routes share a single label
+// so scanning random urls cannot blow up the number of series
+func Metrics(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			route := routePattern(r)
+			if route == "" {
+				route = "unmatched"
+			}
+
+			m.ObserveRequest(r.Method, route, rec.status, time.Since(start))
+		})
+	}
+}
@@ -3,26 +3,35 @@ package middleware
 //intercept requests before they go through for validation
 import (
 	"context"
-	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"strings"
 
+	"github.com/zidariu-sabin/femProject/internal/logging"
+	"github.com/zidariu-sabin/femProject/internal/metrics"
 	"github.com/zidariu-sabin/femProject/internal/store"
 	"github.com/zidariu-sabin/femProject/internal/tokens"
 	"github.com/zidariu-sabin/femProject/internal/utils"
 )
 
 type UserMiddleware struct {
-	UserStore store.UserStore
+	UserStore  store.UserStore
+	TokenStore store.TokenStore
+	Metrics    *metrics.Metrics
+	Logger     *slog.Logger
 }
 
 // we define an extra type for the context key so that we don't get colisions on the string type in the context
 type contextKey string
 
-const UserContextKey = contextKey("user")
+const (
+	UserContextKey  = contextKey("user")
+	TokenContextKey = contextKey("token")
+)
 
-func NewUserMiddleware(userStore *store.PostgresUserStore) *UserMiddleware {
-	return &UserMiddleware{UserStore: userStore}
+func NewUserMiddleware(userStore store.UserStore, tokenStore store.TokenStore, m *metrics.Metrics, logger *slog.Logger) *UserMiddleware {
+	return &UserMiddleware{UserStore: userStore, TokenStore: tokenStore, Metrics: m, Logger: logger}
 }
 
 func SetUser(r *http.Request, user *store.User) *http.Request {
@@ -41,6 +50,28 @@ func GetUser(r *http.Request) *store.User {
 	return user
 }
 
+// stores the plain text of the token the request was authenticated with
+func SetToken(r *http.Request, token string) *http.Request {
+	ctx := context.WithValue(r.Context(), TokenContextKey, token)
+	return r.WithContext(ctx)
+}
+
+// returns the token the request was authenticated with, empty for anonymous requests
+func GetToken(r *http.Request) string {
+	token, _ := r.Context().Value(TokenContextKey).(string)
+	return token
+}
+
+// details of the client that sent the request, used to describe sessions
+func GetClientInfo(r *http.Request) store.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return store.ClientInfo{UserAgent: r.UserAgent(), IP: ip}
+}
+
 // func setUserCookie(r *http.Request, user *store.User) *http.Request
 
 // we use this function to wrap all handlers that process requests
@@ -57,30 +88,39 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 			next.ServeHTTP(w, r)
 			return
 		}
-		fmt.Printf("authHeader:%v \n", authHeader)
 		// Parsing auth token of type "Bearer <AUTH TOKEN"
 		headerParts := strings.Split(authHeader, " ")
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			um.Metrics.AuthenticationFailed("invalid_header")
 			utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid authorization header"})
 			return
 		}
 
 		token := headerParts[1]
-		user, err := um.UserStore.GetUserToken(tokens.ScopeAuth, token)
+		user, err := um.UserStore.GetUserToken(r.Context(), tokens.ScopeAuth, token)
 
-		// fmt.Printf("user:%v \n", user)
 		if err != nil {
-			fmt.Printf("error:%v \n", err)
+			um.Logger.ErrorContext(r.Context(), "getUserToken", "error", err)
 			utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "invalid token"})
 			return
 		}
 
 		if user == nil {
+			um.Metrics.AuthenticationFailed("invalid_token")
 			utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "token expired or invalid"})
 			return
 		}
 
+		//failing to record the last use of a token should not fail the request
+		err = um.TokenStore.TouchToken(r.Context(), token, GetClientInfo(r))
+		if err != nil {
+			um.Logger.WarnContext(r.Context(), "touchToken", "error", err)
+		}
+
+		logging.SetUserID(r.Context(), user.ID)
+
 		r = SetUser(r, user)
+		r = SetToken(r, token)
 		next.ServeHTTP(w, r)
 		return
 	})
@@ -97,3 +137,16 @@ func (um *UserMiddleware) RequireUser(next http.HandlerFunc) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// like RequireUser but the user must also have verified the email with the activation token
+func (um *UserMiddleware) RequireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return um.RequireUser(func(w http.ResponseWriter, r *http.Request) {
+		user := GetUser(r)
+
+		if !user.Activated {
+			utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "your account must be activated to access this route"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
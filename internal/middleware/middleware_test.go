@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zidariu-sabin/femProject/internal/store"
+)
+
+func TestRequireActivatedUser(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       *store.User
+		wantStatus int
+	}{
+		{name: "anonymous user", user: store.AnonymousUser, wantStatus: http.StatusUnauthorized},
+		{name: "user waiting for activation", user: &store.User{ID: 7}, wantStatus: http.StatusForbidden},
+		{name: "activated user", user: &store.User{ID: 7, Activated: true}, wantStatus: http.StatusTeapot},
+	}
+
+	um := &UserMiddleware{}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := um.RequireActivatedUser(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+
+			req := SetUser(httptest.NewRequest(http.MethodGet, "/workouts", nil), tt.user)
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.wantStatus, rr.Code)
+		})
+	}
+}
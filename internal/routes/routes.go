@@ -3,27 +3,92 @@ package routes
 import (
 	"github.com/go-chi/chi/v5"
 	"github.com/zidariu-sabin/femProject/internal/app"
+	"github.com/zidariu-sabin/femProject/internal/middleware"
+	"go.opentelemetry.io/otel"
 )
 
 // a struct that works as a multiplexor to pare routes and their parameters
 func SetupRoutes(app *app.Application) *chi.Mux {
 	router := chi.NewRouter()
 
+	//every request gets an id and a span before the access log so both are part of the access record
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Tracing(otel.Tracer("github.com/zidariu-sabin/femProject/internal/routes")))
+	router.Use(middleware.AccessLog(app.Logger))
+	router.Use(middleware.Metrics(app.Metrics))
+
 	router.Group(func(router chi.Router) {
 		router.Use(app.Middleware.Authenticate)
 
-		router.Get("/workout/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleGetWorkoutById))
-		router.Post("/workout", app.Middleware.RequireUser(app.WorkoutHandler.HandleCreateWorkout))
-		router.Put("/workout/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleUpdateWorkoutById))
-		router.Delete("/workout/{id}", app.Middleware.RequireUser(app.WorkoutHandler.HandleDeleteWorkoutById))
+		//the training log needs a verified email, the account and session routes only need a logged in user
+		router.Get("/workouts", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandleListWorkouts))
+		router.Get("/workout/{id}", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandleGetWorkoutById))
+		router.Post("/workout", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandleCreateWorkout))
+		router.Put("/workout/{id}", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandleUpdateWorkoutById))
+		router.Delete("/workout/{id}", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandleDeleteWorkoutById))
+		router.Get("/workout/{id}/route", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandleGetWorkoutRoute))
+		router.Post("/workouts/import", app.Middleware.RequireActivatedUser(app.WorkoutHandler.HandleImportWorkout))
+		router.Get("/exercises", app.Middleware.RequireActivatedUser(app.ExerciseHandler.HandleListExercises))
+		router.Get("/exercise/{id}", app.Middleware.RequireActivatedUser(app.ExerciseHandler.HandleGetExerciseById))
+		router.Post("/exercise", app.Middleware.RequireActivatedUser(app.ExerciseHandler.HandleCreateExercise))
+		router.Put("/exercise/{id}", app.Middleware.RequireActivatedUser(app.ExerciseHandler.HandleUpdateExerciseById))
+		router.Delete("/exercise/{id}", app.Middleware.RequireActivatedUser(app.ExerciseHandler.HandleDeleteExerciseById))
+		router.Get("/records", app.Middleware.RequireActivatedUser(app.RecordHandler.HandleListRecords))
+		router.Get("/exercises/{id}/records", app.Middleware.RequireActivatedUser(app.RecordHandler.HandleListExerciseRecords))
+		router.Get("/analytics/volume", app.Middleware.RequireActivatedUser(app.AnalyticsHandler.HandleVolume))
+		router.Get("/analytics/1rm", app.Middleware.RequireActivatedUser(app.AnalyticsHandler.HandleOneRepMax))
+		router.Get("/templates", app.Middleware.RequireActivatedUser(app.TemplateHandler.HandleListTemplates))
+		router.Post("/templates", app.Middleware.RequireActivatedUser(app.TemplateHandler.HandleCreateTemplate))
+		router.Get("/templates/{id}", app.Middleware.RequireActivatedUser(app.TemplateHandler.HandleGetTemplateById))
+		router.Put("/templates/{id}", app.Middleware.RequireActivatedUser(app.TemplateHandler.HandleUpdateTemplateById))
+		router.Delete("/templates/{id}", app.Middleware.RequireActivatedUser(app.TemplateHandler.HandleDeleteTemplateById))
+		router.Post("/templates/{id}/start", app.Middleware.RequireActivatedUser(app.TemplateHandler.HandleStartTemplate))
+		router.Get("/programs", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandleListPrograms))
+		router.Post("/programs", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandleCreateProgram))
+		router.Get("/programs/{id}", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandleGetProgramById))
+		router.Put("/programs/{id}", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandleUpdateProgramById))
+		router.Delete("/programs/{id}", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandleDeleteProgramById))
+		router.Post("/programs/{id}/enrollments", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandleEnroll))
+		router.Get("/programs/enrollments", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandleListEnrollments))
+		router.Get("/programs/enrollments/{id}", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandleGetEnrollmentById))
+		router.Get("/programs/enrollments/{id}/today", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandleToday))
+		router.Post("/programs/enrollments/{id}/today/start", app.Middleware.RequireActivatedUser(app.ProgramHandler.HandleStartToday))
+		router.Get("/calendar", app.Middleware.RequireActivatedUser(app.CalendarHandler.HandleCalendar))
+		router.Get("/calendar/plans", app.Middleware.RequireActivatedUser(app.CalendarHandler.HandleListPlans))
+		router.Post("/calendar/plans", app.Middleware.RequireActivatedUser(app.CalendarHandler.HandleCreatePlan))
+		router.Put("/calendar/plans/{id}", app.Middleware.RequireActivatedUser(app.CalendarHandler.HandleUpdatePlanById))
+		router.Delete("/calendar/plans/{id}", app.Middleware.RequireActivatedUser(app.CalendarHandler.HandleDeletePlanById))
+		router.Post("/calendar/feed", app.Middleware.RequireActivatedUser(app.CalendarHandler.HandleCreateFeed))
+		router.Delete("/calendar/feed", app.Middleware.RequireActivatedUser(app.CalendarHandler.HandleDeleteFeed))
+		router.Get("/export", app.Middleware.RequireActivatedUser(app.ExportHandler.HandleExport))
+		router.Post("/import", app.Middleware.RequireActivatedUser(app.ImportHandler.HandleImport))
 		router.Get("/user", app.Middleware.RequireUser(app.UserHandler.HandleGetUserByUsername))
+		router.Get("/user/data-export", app.Middleware.RequireUser(app.AccountHandler.HandleDataExport))
+		router.Delete("/user", app.Middleware.RequireUser(app.AccountHandler.HandleDeleteAccount))
+		router.Delete("/user/deletion", app.Middleware.RequireUser(app.AccountHandler.HandleCancelDeletion))
+		router.Put("/user/password", app.Middleware.RequireUser(app.PasswordHandler.HandleChangePassword))
+		router.Get("/users/search", app.Middleware.RequireActivatedUser(app.UserHandler.HandleSearch))
+
+		router.Get("/tokens", app.Middleware.RequireUser(app.TokenHandler.HandleListSessions))
+		router.Delete("/tokens", app.Middleware.RequireUser(app.TokenHandler.HandleLogoutAll))
+		router.Delete("/tokens/current", app.Middleware.RequireUser(app.TokenHandler.HandleLogout))
 
 	})
 
-	router.Get("/health", app.HealthCheck)
+	router.Get("/health", app.HandleReadiness)
+	router.Get("/health/live", app.HandleLiveness)
+	router.Get("/health/ready", app.HandleReadiness)
+	router.Get("/metrics", app.Metrics.Handler().ServeHTTP)
 
 	router.Post("/user", app.UserHandler.HandleRegisterUser)
 	router.Post("/tokens/authentication", app.TokenHandler.HandleCreateToken)
+	router.Post("/tokens/refresh", app.TokenHandler.HandleRefreshToken)
+	router.Post("/tokens/activation", app.UserHandler.HandleCreateActivationToken)
+	router.Put("/users/activated", app.UserHandler.HandleActivateUser)
+	router.Post("/tokens/password-reset", app.PasswordHandler.HandleCreatePasswordResetToken)
+	router.Put("/users/password", app.PasswordHandler.HandleResetPassword)
+	//the secret in the url authenticates the feed so calendar apps can subscribe to it
+	router.Get("/calendar/feed/{token}.ics", app.CalendarHandler.HandleFeed)
 
 	return router
 }
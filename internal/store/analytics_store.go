@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/analytics"
+)
+
+// filter used when loading the weighted sets of a user, the range includes from and excludes to
+type SetFilter struct {
+	UserID     int
+	ExerciseID int64
+	From       time.Time
+	To         time.Time
+}
+
+type PostgresAnalyticsStore struct {
+	db *tracedDB
+}
+
+// a zero query timeout disables the timeout
+func NewPostgresAnalyticsStore(db *sql.DB, queryTimeout time.Duration) *PostgresAnalyticsStore {
+	return &PostgresAnalyticsStore{db: newTracedDB(db, "PostgresAnalyticsStore", queryTimeout)}
+}
+
+type AnalyticsStore interface {
+	ListSets(ctx context.Context, filter SetFilter) ([]analytics.Set, error)
+}
+
+// loads the entries with reps and a weight in the order they were performed
+func (pg *PostgresAnalyticsStore) ListSets(ctx context.Context, filter SetFilter) ([]analytics.Set, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	args := []interface{}{filter.UserID, filter.From, filter.To}
+	exerciseCondition := ""
+
+	if filter.ExerciseID != 0 {
+		args = append(args, filter.ExerciseID)
+		exerciseCondition = fmt.Sprintf("AND we.exercise_id = $%d", len(args))
+	}
+
+	query := fmt.Sprintf(`
+	SELECT w.id, w.performed_at, we.exercise_id, e.name, to_json(e.muscle_groups), we.sets, we.reps, we.weight
+	FROM workout_entries we
+	INNER JOIN workouts w ON w.id = we.workout_id
+	INNER JOIN exercises e ON e.id = we.exercise_id
+	WHERE w.user_id = $1 AND w.status = 'completed' AND w.performed_at >= $2 AND w.performed_at < $3
+		AND we.reps IS NOT NULL AND we.weight > 0 %s
+	ORDER BY w.performed_at, w.id, we.order_index
+	`, exerciseCondition)
+
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sets := []analytics.Set{}
+
+	for rows.Next() {
+		var set analytics.Set
+		var muscleGroups jsonStrings
+
+		err := rows.Scan(&set.WorkoutID,
+			&set.PerformedAt,
+			&set.ExerciseID,
+			&set.ExerciseName,
+			&muscleGroups,
+			&set.Sets,
+			&set.Reps,
+			&set.Weight)
+		if err != nil {
+			return nil, err
+		}
+
+		set.MuscleGroups = muscleGroups
+		sets = append(sets, set)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return sets, nil
+}
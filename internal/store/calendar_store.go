@@ -0,0 +1,247 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// a recurring planned session, rrule is an iCalendar recurrence rule expanded from starts_at
+type WorkoutPlan struct {
+	ID              int       `json:"id"`
+	UserID          int       `json:"user_id"`
+	TemplateID      *int      `json:"template_id"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	StartsAt        time.Time `json:"starts_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+	RRule           string    `json:"rrule"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type PostgresCalendarStore struct {
+	db *tracedDB
+}
+
+// a zero query timeout disables the timeout
+func NewPostgresCalendarStore(db *sql.DB, queryTimeout time.Duration) *PostgresCalendarStore {
+	return &PostgresCalendarStore{db: newTracedDB(db, "PostgresCalendarStore", queryTimeout)}
+}
+
+type CalendarStore interface {
+	CreatePlan(ctx context.Context, plan *WorkoutPlan) error
+	GetPlanByID(ctx context.Context, id int64) (*WorkoutPlan, error)
+	ListPlans(ctx context.Context, userID int) ([]*WorkoutPlan, error)
+	UpdatePlan(ctx context.Context, plan *WorkoutPlan) error
+	DeletePlan(ctx context.Context, id int64) error
+	ListScheduledWorkouts(ctx context.Context, filter ScheduleFilter) ([]*Workout, error)
+}
+
+// workouts of the user performed in [From, To), nil bounds are open and an empty status matches every status
+type ScheduleFilter struct {
+	UserID int
+	From   *time.Time
+	To     *time.Time
+	Status string
+}
+
+const workoutPlanColumns = `id, user_id, template_id, title, COALESCE(description, ''), starts_at, duration_minutes, rrule, created_at, updated_at`
+
+func scanWorkoutPlan(row rowScanner) (*WorkoutPlan, error) {
+	plan := &WorkoutPlan{}
+
+	err := row.Scan(&plan.ID,
+		&plan.UserID,
+		&plan.TemplateID,
+		&plan.Title,
+		&plan.Description,
+		&plan.StartsAt,
+		&plan.DurationMinutes,
+		&plan.RRule,
+		&plan.CreatedAt,
+		&plan.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// the template of the plan has to belong to its owner
+func (pg *PostgresCalendarStore) CreatePlan(ctx context.Context, plan *WorkoutPlan) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	INSERT INTO workout_plans (user_id, template_id, title, description, starts_at, duration_minutes, rrule)
+	SELECT $1::bigint, $2::bigint, $3::text, $4::text, $5::timestamptz, $6::integer, $7::text
+	WHERE $2::bigint IS NULL OR EXISTS (SELECT 1 FROM workout_templates WHERE id = $2 AND user_id = $1)
+	RETURNING id, created_at, updated_at
+	`
+
+	err := pg.db.QueryRowContext(ctx, query, plan.UserID, plan.TemplateID, plan.Title, plan.Description, plan.StartsAt, plan.DurationMinutes, plan.RRule).
+		Scan(&plan.ID, &plan.CreatedAt, &plan.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrUnknownTemplate
+	}
+
+	return err
+}
+
+func (pg *PostgresCalendarStore) GetPlanByID(ctx context.Context, id int64) (*WorkoutPlan, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	plan, err := scanWorkoutPlan(pg.db.QueryRowContext(ctx, `SELECT `+workoutPlanColumns+` FROM workout_plans WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (pg *PostgresCalendarStore) ListPlans(ctx context.Context, userID int) ([]*WorkoutPlan, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := pg.db.QueryContext(ctx, `SELECT `+workoutPlanColumns+` FROM workout_plans WHERE user_id = $1 ORDER BY starts_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	plans := []*WorkoutPlan{}
+
+	for rows.Next() {
+		plan, err := scanWorkoutPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		plans = append(plans, plan)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return plans, nil
+}
+
+// the template of the plan has to belong to its owner
+func (pg *PostgresCalendarStore) UpdatePlan(ctx context.Context, plan *WorkoutPlan) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	UPDATE workout_plans
+	SET template_id = $1, title = $2, description = $3, starts_at = $4, duration_minutes = $5, rrule = $6, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $7 AND ($1::bigint IS NULL OR EXISTS (SELECT 1 FROM workout_templates WHERE id = $1 AND user_id = $8))
+	RETURNING updated_at
+	`
+
+	err := pg.db.QueryRowContext(ctx, query, plan.TemplateID, plan.Title, plan.Description, plan.StartsAt, plan.DurationMinutes, plan.RRule, plan.ID, plan.UserID).
+		Scan(&plan.UpdatedAt)
+	if err == sql.ErrNoRows && plan.TemplateID != nil {
+		return ErrUnknownTemplate
+	}
+
+	return err
+}
+
+func (pg *PostgresCalendarStore) DeletePlan(ctx context.Context, id int64) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := pg.db.ExecContext(ctx, `DELETE FROM workout_plans WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// lists the workouts of the filter ordered by the time they are performed, without their entries
+func (pg *PostgresCalendarStore) ListScheduledWorkouts(ctx context.Context, filter ScheduleFilter) ([]*Workout, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	//appends a condition whose "?" placeholder is replaced by the next positional argument
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1))
+	}
+
+	if filter.From != nil {
+		addCondition("performed_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("performed_at < ?", *filter.To)
+	}
+	if filter.Status != "" {
+		addCondition("status = ?", filter.Status)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT id, user_id, title, COALESCE(description, ''), duration_minutes, COALESCE(calories_burned, 0), performed_at, status
+	FROM workouts
+	WHERE %s
+	ORDER BY performed_at, id
+	`, strings.Join(conditions, " AND "))
+
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	workouts := []*Workout{}
+
+	for rows.Next() {
+		workout := &Workout{Entries: []WorkoutEntry{}}
+
+		err := rows.Scan(&workout.ID,
+			&workout.UserID,
+			&workout.Title,
+			&workout.Description,
+			&workout.DurationMinutes,
+			&workout.CaloriesBurned,
+			&workout.PerformedAt,
+			&workout.Status)
+		if err != nil {
+			return nil, err
+		}
+
+		workouts = append(workouts, workout)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return workouts, nil
+}
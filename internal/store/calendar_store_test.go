@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendar(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	workoutStore := NewPostgresWorkoutStore(db, 0, nil)
+	calendarStore := NewPostgresCalendarStore(db, 0)
+
+	user := &User{Username: "scheduler", Email: "scheduler@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	monday := time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)
+
+	planned, err := workoutStore.CreateWorkout(context.Background(), &Workout{
+		UserID:          user.ID,
+		Title:           "heavy bench",
+		DurationMinutes: 60,
+		PerformedAt:     monday.Add(24 * time.Hour),
+		Status:          WorkoutPlanned,
+		Entries:         []WorkoutEntry{{ExerciseName: "bench press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, planned.PersonalRecords, "planned workouts do not set records")
+
+	completed, err := workoutStore.CreateWorkout(context.Background(), &Workout{UserID: user.ID, Title: "easy run", DurationMinutes: 30, Entries: []WorkoutEntry{}})
+	require.NoError(t, err)
+	assert.Equal(t, WorkoutCompleted, completed.Status)
+	assert.False(t, completed.PerformedAt.IsZero())
+
+	from, to := monday, monday.Add(7*24*time.Hour)
+	workouts, err := calendarStore.ListScheduledWorkouts(context.Background(), ScheduleFilter{UserID: user.ID, From: &from, To: &to})
+	require.NoError(t, err)
+	require.Len(t, workouts, 1)
+	assert.Equal(t, planned.ID, workouts[0].ID)
+
+	plan := &WorkoutPlan{UserID: user.ID, Title: "strength", StartsAt: monday, DurationMinutes: 60, RRule: "FREQ=WEEKLY;BYDAY=MO,TH"}
+	require.NoError(t, calendarStore.CreatePlan(context.Background(), plan))
+
+	missingTemplate := 999999
+	plan.TemplateID = &missingTemplate
+	assert.ErrorIs(t, calendarStore.UpdatePlan(context.Background(), plan), ErrUnknownTemplate)
+
+	plans, err := calendarStore.ListPlans(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, plans, 1)
+	assert.Nil(t, plans[0].TemplateID)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO,TH", plans[0].RRule)
+
+	require.NoError(t, calendarStore.DeletePlan(context.Background(), int64(plan.ID)))
+
+	retrieved, err := calendarStore.GetPlanByID(context.Background(), int64(plan.ID))
+	require.NoError(t, err)
+	assert.Nil(t, retrieved)
+}
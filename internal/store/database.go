@@ -1,26 +1,24 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io/fs"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/pressly/goose/v3"
-	"github.com/zidariu-sabin/femProject/internal/utils"
 )
 
 // var collection = "pg"
 
-func Open() (*sql.DB, error) {
-	db, err := sql.Open("pgx", utils.DATABASE_PGS_CONN_STRING)
+func Open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
 	if err != nil {
 		//format specifier that wraps error
 		return nil, fmt.Errorf("db: open %w", err)
 	}
 
-	fmt.Println("Connected to Database... ")
-
 	return db, nil
 }
 
@@ -50,3 +48,16 @@ func Migrate(db *sql.DB, dir string) error {
 
 	return nil
 }
+
+// returns the version of the last applied migration, the goose table is read directly because the readiness probe
+// calls it on every request and goose keeps its dialect in a package level variable
+func MigrationVersion(ctx context.Context, db *sql.DB) (int64, error) {
+	var version int64
+
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(max(version_id), 0) FROM goose_db_version WHERE is_applied`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("migration version: %w", err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,411 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrExerciseNameTaken = errors.New("an exercise with this name already exists")
+	ErrExerciseInUse     = errors.New("exercise is used by workouts or templates")
+	ErrUnknownExercise   = errors.New("exercise does not exist")
+	ErrExerciseRequired  = errors.New("every entry needs an exercise_id or an exercise_name")
+)
+
+// movement types accepted for an exercise, an empty movement type means it is unknown
+var MovementTypes = []string{"compound", "isolation", "cardio", "mobility"}
+
+// exercises of the default catalog have no creator and can not be changed by users
+type Exercise struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Aliases      []string  `json:"aliases"`
+	MuscleGroups []string  `json:"muscle_groups"`
+	Equipment    string    `json:"equipment"`
+	MovementType string    `json:"movement_type"`
+	CreatedBy    *int      `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// filter used when listing the catalog together with the custom exercises of UserID, empty fields are ignored
+type ExerciseFilter struct {
+	UserID       int
+	Query        string
+	MuscleGroup  string
+	MovementType string
+	Equipment    string
+	Limit        int
+	Offset       int
+}
+
+type PostgresExerciseStore struct {
+	db *tracedDB
+}
+
+// a zero query timeout disables the timeout
+func NewPostgresExerciseStore(db *sql.DB, queryTimeout time.Duration) *PostgresExerciseStore {
+	return &PostgresExerciseStore{db: newTracedDB(db, "PostgresExerciseStore", queryTimeout)}
+}
+
+type ExerciseStore interface {
+	CreateExercise(ctx context.Context, exercise *Exercise) error
+	GetExerciseByID(ctx context.Context, id int64, userID int) (*Exercise, error)
+	GetExerciseByName(ctx context.Context, name string, userID int) (*Exercise, error)
+	ListExercises(ctx context.Context, filter ExerciseFilter) ([]*Exercise, error)
+	UpdateExercise(ctx context.Context, exercise *Exercise) error
+	DeleteExercise(ctx context.Context, id int64) error
+}
+
+const exerciseColumns = `id, name, to_json(aliases), to_json(muscle_groups), equipment, movement_type, created_by, created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanExercise(row rowScanner) (*Exercise, error) {
+	exercise := &Exercise{}
+
+	var aliases, muscleGroups jsonStrings
+	err := row.Scan(&exercise.ID,
+		&exercise.Name,
+		&aliases,
+		&muscleGroups,
+		&exercise.Equipment,
+		&exercise.MovementType,
+		&exercise.CreatedBy,
+		&exercise.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	exercise.Aliases = aliases
+	exercise.MuscleGroups = muscleGroups
+
+	return exercise, nil
+}
+
+func (pg *PostgresExerciseStore) CreateExercise(ctx context.Context, exercise *Exercise) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	err := pg.checkCatalogName(ctx, exercise)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO exercises (name, aliases, muscle_groups, equipment, movement_type, created_by)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at
+	`
+
+	err = pg.db.QueryRowContext(ctx, query,
+		exercise.Name,
+		nonNilStrings(exercise.Aliases),
+		nonNilStrings(exercise.MuscleGroups),
+		exercise.Equipment,
+		exercise.MovementType,
+		exercise.CreatedBy).Scan(&exercise.ID, &exercise.CreatedAt)
+
+	if pgErrorCode(err) == uniqueViolation {
+		return ErrExerciseNameTaken
+	}
+
+	return err
+}
+
+// the custom exercises of other users are not found
+func (pg *PostgresExerciseStore) GetExerciseByID(ctx context.Context, id int64, userID int) (*Exercise, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + exerciseColumns + ` FROM exercises WHERE id = $1 AND ` + exerciseVisible
+
+	exercise, err := scanExercise(pg.db.QueryRowContext(ctx, query, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return exercise, nil
+}
+
+// matches the name case insensitively against the names and aliases of the catalog and of the exercises of the user
+func (pg *PostgresExerciseStore) GetExerciseByName(ctx context.Context, name string, userID int) (*Exercise, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + exerciseColumns + ` FROM exercises ` + exerciseNameCondition + ` LIMIT 1`
+
+	exercise, err := scanExercise(pg.db.QueryRowContext(ctx, query, strings.TrimSpace(name), userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return exercise, nil
+}
+
+func (pg *PostgresExerciseStore) ListExercises(ctx context.Context, filter ExerciseFilter) ([]*Exercise, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	conditions := []string{"(created_by IS NULL OR created_by = $1)"}
+	args := []interface{}{filter.UserID}
+
+	//appends a condition whose "?" placeholders are replaced by the next positional argument
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if filter.Query != "" {
+		addCondition(`(name ILIKE '%' || ? || '%' OR EXISTS (SELECT 1 FROM unnest(aliases) a WHERE a ILIKE '%' || ? || '%'))`,
+			likeEscaper.Replace(filter.Query))
+	}
+	if filter.MuscleGroup != "" {
+		addCondition("? = ANY(muscle_groups)", filter.MuscleGroup)
+	}
+	if filter.MovementType != "" {
+		addCondition("movement_type = ?", filter.MovementType)
+	}
+	if filter.Equipment != "" {
+		addCondition("equipment = ?", filter.Equipment)
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+
+	query := fmt.Sprintf(`
+	SELECT %s
+	FROM exercises
+	WHERE %s
+	ORDER BY lower(name), id
+	LIMIT $%d OFFSET $%d
+	`, exerciseColumns, strings.Join(conditions, " AND "), len(args)-1, len(args))
+
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	exercises := []*Exercise{}
+
+	for rows.Next() {
+		exercise, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		exercises = append(exercises, exercise)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return exercises, nil
+}
+
+func (pg *PostgresExerciseStore) UpdateExercise(ctx context.Context, exercise *Exercise) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	err := pg.checkCatalogName(ctx, exercise)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE exercises
+	SET name = $1, aliases = $2, muscle_groups = $3, equipment = $4, movement_type = $5
+	WHERE id = $6
+	`
+
+	result, err := pg.db.ExecContext(ctx, query,
+		exercise.Name,
+		nonNilStrings(exercise.Aliases),
+		nonNilStrings(exercise.MuscleGroups),
+		exercise.Equipment,
+		exercise.MovementType,
+		exercise.ID)
+
+	if pgErrorCode(err) == uniqueViolation {
+		return ErrExerciseNameTaken
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// a custom exercise cannot take the name of a catalog exercise, the unique indexes only compare names within the catalog
+// and within the exercises of one user
+func (pg *PostgresExerciseStore) checkCatalogName(ctx context.Context, exercise *Exercise) error {
+	if exercise.CreatedBy == nil {
+		return nil
+	}
+
+	var taken bool
+
+	query := `SELECT EXISTS (SELECT 1 FROM exercises WHERE created_by IS NULL AND lower(name) = lower($1))`
+
+	err := pg.db.QueryRowContext(ctx, query, exercise.Name).Scan(&taken)
+	if err != nil {
+		return err
+	}
+
+	if taken {
+		return ErrExerciseNameTaken
+	}
+
+	return nil
+}
+
+// exercises referenced by workout entries are kept so no history is lost
+func (pg *PostgresExerciseStore) DeleteExercise(ctx context.Context, id int64) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := pg.db.ExecContext(ctx, `DELETE FROM exercises WHERE id = $1`, id)
+
+	if pgErrorCode(err) == foreignKeyViolation {
+		return ErrExerciseInUse
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// the catalog and the custom exercises of the user $2
+const exerciseVisible = `(created_by IS NULL OR created_by = $2)`
+
+// matches $1 against the names and aliases of the exercises visible to the user $2, the exercises of the user win over
+// the catalog and an exact name wins over an alias of another exercise
+const exerciseNameCondition = `
+	WHERE ` + exerciseVisible + ` AND (lower(name) = lower($1) OR lower($1) = ANY(SELECT lower(a) FROM unnest(aliases) a))
+	ORDER BY created_by IS NULL, lower(name) = lower($1) DESC, id`
+
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// sets the exercise id and canonical name of an entry, a name matching no exercise or alias visible to the user
+// creates a new exercise owned by the user
+func resolveExercise(ctx context.Context, db rowQueryer, entry *WorkoutEntry, userID int) error {
+	if entry.ExerciseID != 0 {
+		err := db.QueryRowContext(ctx, `SELECT name FROM exercises WHERE id = $1 AND `+exerciseVisible, entry.ExerciseID, userID).Scan(&entry.ExerciseName)
+		if err == sql.ErrNoRows {
+			return ErrUnknownExercise
+		}
+
+		return err
+	}
+
+	name := strings.TrimSpace(entry.ExerciseName)
+	if name == "" {
+		return ErrExerciseRequired
+	}
+
+	lookup := `SELECT id, name FROM exercises ` + exerciseNameCondition + ` LIMIT 1`
+
+	err := db.QueryRowContext(ctx, lookup, name, userID).Scan(&entry.ExerciseID, &entry.ExerciseName)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	insert := `
+	INSERT INTO exercises (name, created_by)
+	VALUES ($1, $2)
+	ON CONFLICT (created_by, (lower(name))) DO NOTHING
+	RETURNING id, name
+	`
+
+	err = db.QueryRowContext(ctx, insert, name, userID).Scan(&entry.ExerciseID, &entry.ExerciseName)
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	//the exercise was created by a concurrent request
+	return db.QueryRowContext(ctx, lookup, name, userID).Scan(&entry.ExerciseID, &entry.ExerciseName)
+}
+
+// postgres error codes the stores translate to their own errors
+const (
+	foreignKeyViolation    = "23503"
+	uniqueViolation        = "23505"
+	checkViolation         = "23514"
+	numericValueOutOfRange = "22003"
+	stringDataTooLong      = "22001"
+)
+
+func pgErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+
+	return ""
+}
+
+// text arrays are selected as json so they can be scanned without driver specific types
+type jsonStrings []string
+
+func (s *jsonStrings) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case []byte:
+		return json.Unmarshal(value, s)
+	case string:
+		return json.Unmarshal([]byte(value), s)
+	default:
+		return fmt.Errorf("cannot scan %T into a string list", src)
+	}
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveExercise(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	workoutStore := NewPostgresWorkoutStore(db, 0, nil)
+
+	user := &User{Username: "catalog", Email: "catalog@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	tests := []struct {
+		name     string
+		entry    WorkoutEntry
+		wantName string
+		wantErr  error
+	}{
+		{name: "canonical name", entry: WorkoutEntry{ExerciseName: "Bench Press"}, wantName: "Bench Press"},
+		{name: "name in another case", entry: WorkoutEntry{ExerciseName: "  bench press "}, wantName: "Bench Press"},
+		{name: "alias", entry: WorkoutEntry{ExerciseName: "bp"}, wantName: "Bench Press"},
+		{name: "unknown name is created", entry: WorkoutEntry{ExerciseName: "Zercher Carry"}, wantName: "Zercher Carry"},
+		{name: "unknown id", entry: WorkoutEntry{ExerciseID: -1}, wantErr: ErrUnknownExercise},
+		{name: "missing exercise", entry: WorkoutEntry{}, wantErr: ErrExerciseRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.entry.Sets = 3
+			tt.entry.Reps = IntPtr(5)
+
+			workout, err := workoutStore.CreateWorkout(context.Background(), &Workout{
+				UserID:          user.ID,
+				Title:           tt.name,
+				DurationMinutes: 30,
+				Entries:         []WorkoutEntry{tt.entry},
+			})
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			retrieved, err := workoutStore.GetWorkoutByID(context.Background(), int64(workout.ID))
+			require.NoError(t, err)
+			require.Len(t, retrieved.Entries, 1)
+			assert.Equal(t, tt.wantName, retrieved.Entries[0].ExerciseName)
+			assert.NotZero(t, retrieved.Entries[0].ExerciseID)
+		})
+	}
+
+	t.Run("auto created exercises are reused", func(t *testing.T) {
+		exerciseStore := NewPostgresExerciseStore(db, 0)
+
+		exercises, err := exerciseStore.ListExercises(context.Background(), ExerciseFilter{UserID: user.ID, Query: "zercher", Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, exercises, 1)
+		require.NotNil(t, exercises[0].CreatedBy)
+		assert.Equal(t, user.ID, *exercises[0].CreatedBy)
+
+		err = exerciseStore.DeleteExercise(context.Background(), exercises[0].ID)
+		assert.ErrorIs(t, err, ErrExerciseInUse)
+	})
+
+	t.Run("custom exercises of other users are not matched", func(t *testing.T) {
+		other := &User{Username: "other", Email: "other@gmail.com"}
+		require.NoError(t, other.PasswordHash.Set("password123"))
+		require.NoError(t, userStore.CreateUser(context.Background(), other))
+
+		workout, err := workoutStore.CreateWorkout(context.Background(), &Workout{
+			UserID:          other.ID,
+			Title:           "other",
+			DurationMinutes: 30,
+			Entries:         []WorkoutEntry{{ExerciseName: "zercher carry", Sets: 3, Reps: IntPtr(5)}},
+		})
+		require.NoError(t, err)
+
+		retrieved, err := workoutStore.GetWorkoutByID(context.Background(), int64(workout.ID))
+		require.NoError(t, err)
+		require.Len(t, retrieved.Entries, 1)
+		assert.Equal(t, "zercher carry", retrieved.Entries[0].ExerciseName)
+
+		exerciseStore := NewPostgresExerciseStore(db, 0)
+
+		exercise, err := exerciseStore.GetExerciseByID(context.Background(), retrieved.Entries[0].ExerciseID, other.ID)
+		require.NoError(t, err)
+		require.NotNil(t, exercise)
+		require.NotNil(t, exercise.CreatedBy)
+		assert.Equal(t, other.ID, *exercise.CreatedBy)
+
+		_, err = workoutStore.CreateWorkout(context.Background(), &Workout{
+			UserID:          other.ID,
+			Title:           "by id",
+			DurationMinutes: 30,
+			Entries:         []WorkoutEntry{{ExerciseID: exercise.ID, Sets: 3, Reps: IntPtr(5)}},
+		})
+		require.NoError(t, err)
+
+		_, err = workoutStore.CreateWorkout(context.Background(), &Workout{
+			UserID:          user.ID,
+			Title:           "by id of another user",
+			DurationMinutes: 30,
+			Entries:         []WorkoutEntry{{ExerciseID: exercise.ID, Sets: 3, Reps: IntPtr(5)}},
+		})
+		assert.ErrorIs(t, err, ErrUnknownExercise)
+	})
+}
+
+func TestExerciseCRUD(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	store := NewPostgresExerciseStore(db, 0)
+
+	user := &User{Username: "catalog", Email: "catalog@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	exercise := &Exercise{CreatedBy: &user.ID, Name: "Landmine Press", Aliases: []string{"Landmine"}, MuscleGroups: []string{"shoulders"}, Equipment: "barbell", MovementType: "compound"}
+	require.NoError(t, store.CreateExercise(context.Background(), exercise))
+
+	other := &User{Username: "other", Email: "other@gmail.com"}
+	require.NoError(t, other.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), other))
+
+	err := store.CreateExercise(context.Background(), &Exercise{CreatedBy: &user.ID, Name: "landmine press"})
+	assert.ErrorIs(t, err, ErrExerciseNameTaken)
+
+	err = store.CreateExercise(context.Background(), &Exercise{CreatedBy: &user.ID, Name: "bench press"})
+	assert.ErrorIs(t, err, ErrExerciseNameTaken)
+
+	//names are only unique within the exercises of one user
+	otherExercise := &Exercise{CreatedBy: &other.ID, Name: "Landmine Press", MuscleGroups: []string{"shoulders"}}
+	require.NoError(t, store.CreateExercise(context.Background(), otherExercise))
+
+	exercises, err := store.ListExercises(context.Background(), ExerciseFilter{UserID: user.ID, MuscleGroup: "shoulders", Query: "landmine", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, exercises, 1)
+	assert.Equal(t, exercise.ID, exercises[0].ID)
+	assert.Equal(t, []string{"Landmine"}, exercises[0].Aliases)
+
+	retrieved, err := store.GetExerciseByName(context.Background(), "landmine press", other.ID)
+	require.NoError(t, err)
+	require.NotNil(t, retrieved)
+	assert.Equal(t, otherExercise.ID, retrieved.ID)
+
+	retrieved, err = store.GetExerciseByID(context.Background(), exercise.ID, other.ID)
+	require.NoError(t, err)
+	assert.Nil(t, retrieved)
+
+	exercise.Name = "Landmine Shoulder Press"
+	require.NoError(t, store.UpdateExercise(context.Background(), exercise))
+
+	retrieved, err = store.GetExerciseByID(context.Background(), exercise.ID, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Landmine Shoulder Press", retrieved.Name)
+
+	require.NoError(t, store.DeleteExercise(context.Background(), exercise.ID))
+
+	retrieved, err = store.GetExerciseByID(context.Background(), exercise.ID, user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, retrieved)
+}
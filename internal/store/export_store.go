@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidImport = errors.New("a value of the file does not fit the training log")
+
+// range of the workouts of a user to export, nil times are ignored and to is exclusive like in WorkoutFilter
+type ExportFilter struct {
+	UserID int
+	From   *time.Time
+	To     *time.Time
+}
+
+// streams the workouts of a user in the order they were performed, fn is called for every workout as soon as its entries are read
+// so the log is never held in memory, the export is not bounded by the query timeout because it lasts as long as the client reads
+func (pg *PostgresWorkoutStore) ExportWorkouts(ctx context.Context, filter ExportFilter, fn func(*Workout) error) error {
+	conditions := []string{"w.user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("w.performed_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("w.performed_at < $%d", len(args)))
+	}
+
+	//one row per entry, workouts without entries come with a row of nulls
+	query := fmt.Sprintf(`
+	SELECT w.id, w.user_id, w.title, w.description, w.duration_minutes, w.calories_burned, w.performed_at, w.status, w.enrollment_id, w.program_day_id,
+		we.id, we.exercise_id, e.name, we.entry_type, we.sets, we.reps, we.duration_seconds, we.weight, we.notes, we.order_index,
+		we.distance, we.distance_unit, we.avg_heart_rate, we.max_heart_rate, we.elevation_gain_meters
+	FROM workouts w
+	LEFT JOIN workout_entries we ON we.workout_id = w.id
+	LEFT JOIN exercises e ON e.id = we.exercise_id
+	WHERE %s
+	ORDER BY w.performed_at, w.id, we.order_index
+	`, strings.Join(conditions, " AND "))
+
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current *Workout
+
+	emit := func() error {
+		if current == nil {
+			return nil
+		}
+		computeCardioMetrics(current.Entries)
+		return fn(current)
+	}
+
+	for rows.Next() {
+		workout := &Workout{Entries: []WorkoutEntry{}}
+		var entryID, orderIndex, sets *int
+		var exerciseID *int64
+		var exerciseName, entryType, notes *string
+		var entry WorkoutEntry
+
+		err = rows.Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned,
+			&workout.PerformedAt, &workout.Status, &workout.EnrollmentID, &workout.ProgramDayID,
+			&entryID, &exerciseID, &exerciseName, &entryType, &sets, &entry.Reps, &entry.DurationSeconds, &entry.Weight, &notes, &orderIndex,
+			&entry.Distance, &entry.DistanceUnit, &entry.AvgHeartRate, &entry.MaxHeartRate, &entry.ElevationGainMeters)
+		if err != nil {
+			return err
+		}
+
+		if current == nil || current.ID != workout.ID {
+			err = emit()
+			if err != nil {
+				return err
+			}
+			current = workout
+		}
+
+		if entryID == nil {
+			continue
+		}
+
+		entry.ID, entry.ExerciseID, entry.ExerciseName = *entryID, *exerciseID, *exerciseName
+		entry.EntryType, entry.Sets, entry.OrderIndex = *entryType, *sets, *orderIndex
+		if notes != nil {
+			entry.Notes = *notes
+		}
+
+		current.Entries = append(current.Entries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	return emit()
+}
+
+// creates the workouts of an import in a single transaction so a failing workout leaves nothing behind,
+// the workouts must belong to the same user and the import gets the query timeout once per workout
+func (pg *PostgresWorkoutStore) ImportWorkouts(ctx context.Context, workouts []*Workout) error {
+	if len(workouts) == 0 {
+		return nil
+	}
+
+	if pg.db.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pg.db.queryTimeout*time.Duration(len(workouts)))
+		defer cancel()
+	}
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	entries := []WorkoutEntry{}
+
+	for _, workout := range workouts {
+		err = insertWorkout(ctx, tx, workout)
+
+		switch pgErrorCode(err) {
+		case checkViolation, numericValueOutOfRange, stringDataTooLong:
+			return fmt.Errorf("%w (workout %q)", ErrInvalidImport, workout.Title)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, workout.Entries...)
+	}
+
+	//the records are rebuilt once for every exercise of the import instead of once per workout
+	_, err = rebuildPersonalRecords(ctx, tx, workouts[0].UserID, entryExerciseIDs(entries))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	for range workouts {
+		pg.metrics.WorkoutCreated()
+	}
+
+	return nil
+}
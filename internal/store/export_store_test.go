@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	workoutStore := NewPostgresWorkoutStore(db, 0, nil)
+
+	user := &User{Username: "exporter", Email: "exporter@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	other := &User{Username: "someone", Email: "someone@gmail.com"}
+	require.NoError(t, other.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), other))
+
+	monday := time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)
+
+	workouts := []*Workout{
+		{UserID: user.ID, Title: "push", DurationMinutes: 60, PerformedAt: monday.Add(48 * time.Hour), Entries: []WorkoutEntry{
+			{ExerciseName: "bench press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 2},
+			{ExerciseName: "running", Sets: 1, Distance: FloatPtr(2), DistanceUnit: StringPtr("km"), DurationSeconds: IntPtr(600), OrderIndex: 1},
+		}},
+		{UserID: user.ID, Title: "stretching", DurationMinutes: 15, PerformedAt: monday, Entries: []WorkoutEntry{}},
+		{UserID: user.ID, Title: "last month", DurationMinutes: 30, PerformedAt: monday.AddDate(0, -1, 0), Entries: []WorkoutEntry{}},
+		{UserID: other.ID, Title: "not mine", DurationMinutes: 30, PerformedAt: monday, Entries: []WorkoutEntry{}},
+	}
+
+	for _, workout := range workouts {
+		_, err := workoutStore.CreateWorkout(context.Background(), workout)
+		require.NoError(t, err)
+	}
+
+	from := monday.AddDate(0, 0, -1)
+	exported := []*Workout{}
+
+	err := workoutStore.ExportWorkouts(context.Background(), ExportFilter{UserID: user.ID, From: &from}, func(workout *Workout) error {
+		exported = append(exported, workout)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, exported, 2)
+	assert.Equal(t, "stretching", exported[0].Title)
+	assert.Empty(t, exported[0].Entries)
+	assert.Equal(t, "push", exported[1].Title)
+	require.Len(t, exported[1].Entries, 2)
+	assert.Equal(t, "Running", exported[1].Entries[0].ExerciseName)
+	assert.Equal(t, 300.0, *exported[1].Entries[0].PaceSecondsPerKm)
+	assert.Equal(t, 2, exported[1].Entries[1].OrderIndex)
+
+	count := 0
+	err = workoutStore.ExportWorkouts(context.Background(), ExportFilter{UserID: user.ID}, func(workout *Workout) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+}
+
+func TestImportWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	workoutStore := NewPostgresWorkoutStore(db, 0, nil)
+
+	user := &User{Username: "importer", Email: "importer@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	monday := time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)
+
+	workouts := []*Workout{
+		{UserID: user.ID, Title: "push", DurationMinutes: 60, PerformedAt: monday, Entries: []WorkoutEntry{
+			{ExerciseName: "bench press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+		}},
+		{UserID: user.ID, Title: "push again", DurationMinutes: 60, PerformedAt: monday.Add(48 * time.Hour), Entries: []WorkoutEntry{
+			{ExerciseName: "bench press", Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(105), OrderIndex: 1},
+		}},
+	}
+
+	require.NoError(t, workoutStore.ImportWorkouts(context.Background(), workouts))
+	assert.NotZero(t, workouts[0].ID)
+	assert.NotZero(t, workouts[1].ID)
+
+	var records int
+	require.NoError(t, db.QueryRow(`SELECT count(*) FROM personal_records WHERE user_id = $1`, user.ID).Scan(&records))
+	assert.NotZero(t, records, "the records are rebuilt after the import")
+
+	failing := []*Workout{
+		{UserID: user.ID, Title: "fine", DurationMinutes: 30, PerformedAt: monday, Entries: []WorkoutEntry{}},
+		{UserID: user.ID, Title: "reps and duration", DurationMinutes: 30, PerformedAt: monday, Entries: []WorkoutEntry{
+			{ExerciseName: "bench press", EntryType: EntryStrength, Sets: 3, Reps: IntPtr(5), DurationSeconds: IntPtr(60), OrderIndex: 1},
+		}},
+	}
+
+	assert.ErrorIs(t, workoutStore.ImportWorkouts(context.Background(), failing), ErrInvalidImport)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT count(*) FROM workouts WHERE user_id = $1`, user.ID).Scan(&count))
+	assert.Equal(t, 2, count, "a failing import leaves nothing behind")
+}
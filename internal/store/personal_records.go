@@ -0,0 +1,325 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/analytics"
+)
+
+// kinds of personal records detected for an exercise
+const (
+	RecordMaxWeight          = "max_weight"
+	RecordMaxReps            = "max_reps"
+	RecordEstimatedOneRepMax = "estimated_1rm"
+	RecordMaxDuration        = "max_duration"
+)
+
+var RecordTypes = []string{RecordMaxWeight, RecordMaxReps, RecordEstimatedOneRepMax, RecordMaxDuration}
+
+// a record set in a workout, max_reps records are kept separately for every weight
+type PersonalRecord struct {
+	ID           int64     `json:"id"`
+	ExerciseID   int64     `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name"`
+	WorkoutID    int       `json:"workout_id"`
+	RecordType   string    `json:"record_type"`
+	Value        float64   `json:"value"`
+	Weight       *float64  `json:"weight"`
+	Reps         *int      `json:"reps"`
+	AchievedAt   time.Time `json:"achieved_at"`
+}
+
+// filter used when listing the records of a user, zero values are ignored
+type RecordFilter struct {
+	UserID     int
+	ExerciseID int64
+	RecordType string
+	Limit      int
+	Offset     int
+}
+
+type PostgresRecordStore struct {
+	db *tracedDB
+}
+
+// a zero query timeout disables the timeout
+func NewPostgresRecordStore(db *sql.DB, queryTimeout time.Duration) *PostgresRecordStore {
+	return &PostgresRecordStore{db: newTracedDB(db, "PostgresRecordStore", queryTimeout)}
+}
+
+// records are written by the workout store, this store only reads the timeline
+type RecordStore interface {
+	ListRecords(ctx context.Context, filter RecordFilter) ([]*PersonalRecord, error)
+}
+
+// lists the records in the order they were achieved
+func (pg *PostgresRecordStore) ListRecords(ctx context.Context, filter RecordFilter) ([]*PersonalRecord, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	conditions := []string{"pr.user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	if filter.ExerciseID != 0 {
+		args = append(args, filter.ExerciseID)
+		conditions = append(conditions, fmt.Sprintf("pr.exercise_id = $%d", len(args)))
+	}
+	if filter.RecordType != "" {
+		args = append(args, filter.RecordType)
+		conditions = append(conditions, fmt.Sprintf("pr.record_type = $%d", len(args)))
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+
+	query := fmt.Sprintf(`
+	SELECT pr.id, pr.exercise_id, e.name, pr.workout_id, pr.record_type, pr.value, pr.weight, pr.reps, pr.achieved_at
+	FROM personal_records pr
+	INNER JOIN exercises e ON e.id = pr.exercise_id
+	WHERE %s
+	ORDER BY pr.achieved_at, pr.id
+	LIMIT $%d OFFSET $%d
+	`, strings.Join(conditions, " AND "), len(args)-1, len(args))
+
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	records := []*PersonalRecord{}
+
+	for rows.Next() {
+		record := &PersonalRecord{}
+
+		err := rows.Scan(&record.ID,
+			&record.ExerciseID,
+			&record.ExerciseName,
+			&record.WorkoutID,
+			&record.RecordType,
+			&record.Value,
+			&record.Weight,
+			&record.Reps,
+			&record.AchievedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// identifies the best value a record is compared against
+type recordKey struct {
+	exerciseID int64
+	recordType string
+	weight     float64
+}
+
+// set of a past workout replayed when rebuilding the records
+type recordEntry struct {
+	workoutID       int
+	performedAt     time.Time
+	exerciseID      int64
+	reps            *int
+	durationSeconds *int
+	weight          *float64
+}
+
+// records an entry competes for, the same rules are used by the migration that backfilled the records
+func recordCandidates(entry recordEntry) []PersonalRecord {
+	candidates := []PersonalRecord{}
+
+	weight := 0.0
+	if entry.weight != nil {
+		weight = *entry.weight
+	}
+
+	if entry.reps != nil && *entry.reps >= 1 {
+		reps := *entry.reps
+
+		if weight > 0 {
+			candidates = append(candidates, PersonalRecord{RecordType: RecordMaxWeight, Value: weight, Weight: &weight, Reps: &reps})
+		}
+
+		candidates = append(candidates, PersonalRecord{RecordType: RecordMaxReps, Value: float64(reps), Weight: &weight, Reps: &reps})
+
+		//records always use the epley formula so they do not change with the formula picked for the analytics
+		oneRepMax, _ := analytics.OneRepMax(analytics.FormulaEpley, weight, reps)
+		if oneRepMax > 0 {
+			candidates = append(candidates, PersonalRecord{RecordType: RecordEstimatedOneRepMax, Value: oneRepMax, Weight: &weight, Reps: &reps})
+		}
+	}
+
+	if entry.durationSeconds != nil && *entry.durationSeconds > 0 {
+		candidates = append(candidates, PersonalRecord{RecordType: RecordMaxDuration, Value: float64(*entry.durationSeconds)})
+	}
+
+	for i := range candidates {
+		candidates[i].ExerciseID = entry.exerciseID
+		candidates[i].WorkoutID = entry.workoutID
+		candidates[i].AchievedAt = entry.performedAt
+	}
+
+	return candidates
+}
+
+func (r *PersonalRecord) key() recordKey {
+	key := recordKey{exerciseID: r.ExerciseID, recordType: r.RecordType}
+	if r.RecordType == RecordMaxReps && r.Weight != nil {
+		key.weight = *r.Weight
+	}
+
+	return key
+}
+
+// replays the entries in the order they were performed and returns every record they set,
+// only the best set of a workout counts so a workout sets a record at most once per key
+func detectRecords(entries []recordEntry) []PersonalRecord {
+	records := []PersonalRecord{}
+	latest := map[recordKey]int{}
+
+	for _, entry := range entries {
+		for _, candidate := range recordCandidates(entry) {
+			key := candidate.key()
+
+			index, ok := latest[key]
+			if !ok {
+				latest[key] = len(records)
+				records = append(records, candidate)
+				continue
+			}
+
+			current := records[index]
+
+			//a better set of the workout that holds the record replaces it
+			if current.WorkoutID == candidate.WorkoutID {
+				if candidate.Value > current.Value || (candidate.Value == current.Value && candidate.Reps != nil && current.Reps != nil && *candidate.Reps > *current.Reps) {
+					records[index] = candidate
+				}
+				continue
+			}
+
+			if candidate.Value > current.Value {
+				latest[key] = len(records)
+				records = append(records, candidate)
+			}
+		}
+	}
+
+	return records
+}
+
+type queryExecer interface {
+	execer
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// deletes and replays the records of the given exercises of a user so edited and deleted workouts leave no stale records
+func rebuildPersonalRecords(ctx context.Context, db queryExecer, userID int, exerciseIDs []int64) ([]PersonalRecord, error) {
+	if len(exerciseIDs) == 0 {
+		return nil, nil
+	}
+
+	_, err := db.ExecContext(ctx, `DELETE FROM personal_records WHERE user_id = $1 AND exercise_id = ANY($2)`, userID, exerciseIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	SELECT w.id, w.performed_at, we.exercise_id, we.reps, we.duration_seconds, we.weight
+	FROM workout_entries we
+	INNER JOIN workouts w ON w.id = we.workout_id
+	WHERE w.user_id = $1 AND we.exercise_id = ANY($2) AND w.status = 'completed'
+	ORDER BY w.performed_at, w.id, we.order_index, we.id
+	`
+
+	rows, err := db.QueryContext(ctx, query, userID, exerciseIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []recordEntry{}
+
+	for rows.Next() {
+		var entry recordEntry
+
+		err := rows.Scan(&entry.workoutID, &entry.performedAt, &entry.exerciseID, &entry.reps, &entry.durationSeconds, &entry.weight)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	//the rows have to be released before the transaction runs the inserts
+	rows.Close()
+
+	records := detectRecords(entries)
+
+	for _, record := range records {
+		query := `
+		INSERT INTO personal_records (user_id, exercise_id, workout_id, record_type, value, weight, reps, achieved_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`
+
+		_, err := db.ExecContext(ctx, query, userID, record.ExerciseID, record.WorkoutID, record.RecordType, record.Value, record.Weight, record.Reps, record.AchievedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// exercises of the entries without duplicates
+func entryExerciseIDs(entries []WorkoutEntry) []int64 {
+	ids := []int64{}
+	seen := map[int64]bool{}
+
+	for _, entry := range entries {
+		if !seen[entry.ExerciseID] {
+			seen[entry.ExerciseID] = true
+			ids = append(ids, entry.ExerciseID)
+		}
+	}
+
+	return ids
+}
+
+// records of the rebuilt timeline that were set by the workout
+func workoutRecords(records []PersonalRecord, workout *Workout) []PersonalRecord {
+	names := map[int64]string{}
+	for _, entry := range workout.Entries {
+		names[entry.ExerciseID] = entry.ExerciseName
+	}
+
+	set := []PersonalRecord{}
+
+	for _, record := range records {
+		if record.WorkoutID == workout.ID {
+			record.ExerciseName = names[record.ExerciseID]
+			set = append(set, record)
+		}
+	}
+
+	return set
+}
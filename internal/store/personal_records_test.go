@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRecords(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2025, 1, n, 10, 0, 0, 0, time.UTC) }
+	set := func(workoutID, reps int, weight float64) recordEntry {
+		return recordEntry{workoutID: workoutID, performedAt: day(workoutID), exerciseID: 1, reps: IntPtr(reps), weight: FloatPtr(weight)}
+	}
+
+	type record struct {
+		workoutID  int
+		recordType string
+		value      float64
+	}
+
+	tests := []struct {
+		name    string
+		entries []recordEntry
+		want    []record
+	}{
+		{
+			name:    "first set sets every record",
+			entries: []recordEntry{set(1, 5, 100)},
+			want: []record{
+				{1, RecordMaxWeight, 100},
+				{1, RecordMaxReps, 5},
+				{1, RecordEstimatedOneRepMax, 116.67},
+			},
+		},
+		{
+			name:    "only the best set of a workout counts",
+			entries: []recordEntry{set(1, 5, 100), set(1, 3, 110), set(2, 5, 105)},
+			want: []record{
+				{1, RecordMaxWeight, 110},
+				{1, RecordMaxReps, 5},
+				{1, RecordEstimatedOneRepMax, 121},
+				{1, RecordMaxReps, 3},
+				{2, RecordMaxReps, 5},
+				{2, RecordEstimatedOneRepMax, 122.5},
+			},
+		},
+		{
+			name:    "worse workouts set no record",
+			entries: []recordEntry{set(1, 5, 100), set(2, 4, 100)},
+			want: []record{
+				{1, RecordMaxWeight, 100},
+				{1, RecordMaxReps, 5},
+				{1, RecordEstimatedOneRepMax, 116.67},
+			},
+		},
+		{
+			name:    "one rep max is not estimated from high rep sets",
+			entries: []recordEntry{set(1, 20, 40)},
+			want: []record{
+				{1, RecordMaxWeight, 40},
+				{1, RecordMaxReps, 20},
+			},
+		},
+		{
+			name: "duration",
+			entries: []recordEntry{
+				{workoutID: 1, performedAt: day(1), exerciseID: 2, durationSeconds: IntPtr(60)},
+				{workoutID: 2, performedAt: day(2), exerciseID: 2, durationSeconds: IntPtr(45)},
+				{workoutID: 3, performedAt: day(3), exerciseID: 2, durationSeconds: IntPtr(90)},
+			},
+			want: []record{
+				{1, RecordMaxDuration, 60},
+				{3, RecordMaxDuration, 90},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := detectRecords(tt.entries)
+
+			got := []record{}
+			for _, r := range records {
+				got = append(got, record{r.WorkoutID, r.RecordType, r.Value})
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPersonalRecordsFollowWorkoutChanges(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	workoutStore := NewPostgresWorkoutStore(db, 0, nil)
+	recordStore := NewPostgresRecordStore(db, 0)
+
+	user := &User{Username: "lifter", Email: "lifter@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	createWorkout := func(weight float64) *Workout {
+		workout, err := workoutStore.CreateWorkout(context.Background(), &Workout{
+			UserID:          user.ID,
+			Title:           "squat day",
+			DurationMinutes: 45,
+			Entries:         []WorkoutEntry{{ExerciseName: "Squat", Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(weight), OrderIndex: 1}},
+		})
+		require.NoError(t, err)
+		return workout
+	}
+
+	maxWeights := func() []float64 {
+		records, err := recordStore.ListRecords(context.Background(), RecordFilter{UserID: user.ID, RecordType: RecordMaxWeight, Limit: 10})
+		require.NoError(t, err)
+
+		values := []float64{}
+		for _, record := range records {
+			values = append(values, record.Value)
+		}
+		return values
+	}
+
+	first := createWorkout(100)
+	assert.Len(t, first.PersonalRecords, 3)
+
+	second := createWorkout(120)
+	assert.Len(t, second.PersonalRecords, 3)
+	assert.Equal(t, []float64{100, 120}, maxWeights())
+
+	//lowering the first workout keeps the timeline consistent
+	first.Entries[0].Weight = FloatPtr(90)
+	require.NoError(t, workoutStore.UpdateWorkout(context.Background(), first))
+	assert.Equal(t, []float64{90, 120}, maxWeights())
+
+	require.NoError(t, workoutStore.DeleteWorkout(context.Background(), int64(second.ID)))
+	assert.Equal(t, []float64{90}, maxWeights())
+}
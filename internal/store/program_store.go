@@ -0,0 +1,482 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrUnknownTemplate   = errors.New("template does not exist")
+	ErrTemplateInUse     = errors.New("template is used by a program")
+	ErrInvalidProgramDay = errors.New("program day does not belong to the enrollment")
+)
+
+// plan of a number of weeks, every planned day points at the template of its session
+type Program struct {
+	ID          int          `json:"id"`
+	UserID      int          `json:"user_id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Weeks       int          `json:"weeks"`
+	Days        []ProgramDay `json:"days"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// day is the day of the week counted from the start date of an enrollment, from 1 to 7
+type ProgramDay struct {
+	ID            int    `json:"id"`
+	Week          int    `json:"week"`
+	Day           int    `json:"day"`
+	TemplateID    int    `json:"template_id"`
+	TemplateTitle string `json:"template_title"`
+}
+
+// a user following a program from the start date on
+type Enrollment struct {
+	ID        int       `json:"id"`
+	ProgramID int       `json:"program_id"`
+	UserID    int       `json:"user_id"`
+	StartDate time.Time `json:"start_date"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// share of the planned days up to a date that were completed by a linked workout
+type Adherence struct {
+	Planned   int     `json:"planned"`
+	Due       int     `json:"due"`
+	Completed int     `json:"completed"`
+	Rate      float64 `json:"rate"`
+}
+
+// date the day is planned on for an enrollment starting on start
+func (d ProgramDay) Date(start time.Time) time.Time {
+	return start.AddDate(0, 0, (d.Week-1)*7+d.Day-1)
+}
+
+// week and day of the program a date falls on, ok is false before the start and after the last week
+func (p *Program) Position(start, date time.Time) (week, day int, ok bool) {
+	offset := int(truncateDay(date).Sub(truncateDay(start)).Hours() / 24)
+	if offset < 0 || offset >= p.Weeks*7 {
+		return 0, 0, false
+	}
+
+	return offset/7 + 1, offset%7 + 1, true
+}
+
+// planned day of the week and day, nil for a rest day
+func (p *Program) DayAt(week, day int) *ProgramDay {
+	for i := range p.Days {
+		if p.Days[i].Week == week && p.Days[i].Day == day {
+			return &p.Days[i]
+		}
+	}
+
+	return nil
+}
+
+// days planned on or before today are due, days completed ahead of time are counted but do not raise the rate
+func (p *Program) Adherence(start time.Time, completed map[int]int, today time.Time) Adherence {
+	adherence := Adherence{Planned: len(p.Days)}
+	completedDue := 0
+
+	for _, day := range p.Days {
+		_, done := completed[day.ID]
+		due := !day.Date(truncateDay(start)).After(truncateDay(today))
+
+		if done {
+			adherence.Completed++
+		}
+		if due {
+			adherence.Due++
+		}
+		if done && due {
+			completedDue++
+		}
+	}
+
+	if adherence.Due > 0 {
+		adherence.Rate = float64(completedDue) / float64(adherence.Due)
+	}
+
+	return adherence
+}
+
+func truncateDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+type PostgresProgramStore struct {
+	db *tracedDB
+}
+
+// a zero query timeout disables the timeout
+func NewPostgresProgramStore(db *sql.DB, queryTimeout time.Duration) *PostgresProgramStore {
+	return &PostgresProgramStore{db: newTracedDB(db, "PostgresProgramStore", queryTimeout)}
+}
+
+type ProgramStore interface {
+	CreateProgram(ctx context.Context, program *Program) error
+	GetProgramByID(ctx context.Context, id int64) (*Program, error)
+	ListPrograms(ctx context.Context, userID int) ([]*Program, error)
+	UpdateProgram(ctx context.Context, program *Program) error
+	DeleteProgram(ctx context.Context, id int64) error
+	CreateEnrollment(ctx context.Context, enrollment *Enrollment) error
+	GetEnrollmentByID(ctx context.Context, id int64) (*Enrollment, error)
+	ListEnrollments(ctx context.Context, userID int) ([]*Enrollment, error)
+	CompletedDays(ctx context.Context, enrollmentID int) (map[int]int, error)
+}
+
+func (pg *PostgresProgramStore) CreateProgram(ctx context.Context, program *Program) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO programs (user_id, title, description, weeks)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at, updated_at
+	`
+
+	err = tx.QueryRowContext(ctx, query, program.UserID, program.Title, program.Description, program.Weeks).
+		Scan(&program.ID, &program.CreatedAt, &program.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = saveProgramDays(ctx, tx, program)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresProgramStore) GetProgramByID(ctx context.Context, id int64) (*Program, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	program := &Program{}
+
+	query := `
+	SELECT id, user_id, title, COALESCE(description, ''), weeks, created_at, updated_at
+	FROM programs
+	WHERE id = $1
+	`
+
+	err := pg.db.QueryRowContext(ctx, query, id).Scan(&program.ID,
+		&program.UserID,
+		&program.Title,
+		&program.Description,
+		&program.Weeks,
+		&program.CreatedAt,
+		&program.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	queryDays := `
+	SELECT pd.id, pd.week, pd.day, pd.template_id, t.title
+	FROM program_days pd
+	INNER JOIN workout_templates t ON t.id = pd.template_id
+	WHERE pd.program_id = $1
+	ORDER BY pd.week, pd.day
+	`
+
+	rows, err := pg.db.QueryContext(ctx, queryDays, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	program.Days = []ProgramDay{}
+
+	for rows.Next() {
+		var day ProgramDay
+
+		err := rows.Scan(&day.ID, &day.Week, &day.Day, &day.TemplateID, &day.TemplateTitle)
+		if err != nil {
+			return nil, err
+		}
+
+		program.Days = append(program.Days, day)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return program, nil
+}
+
+// lists the programs of the user without their days
+func (pg *PostgresProgramStore) ListPrograms(ctx context.Context, userID int) ([]*Program, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT id, user_id, title, COALESCE(description, ''), weeks, created_at, updated_at
+	FROM programs
+	WHERE user_id = $1
+	ORDER BY lower(title), id
+	`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	programs := []*Program{}
+
+	for rows.Next() {
+		program := &Program{}
+
+		err := rows.Scan(&program.ID,
+			&program.UserID,
+			&program.Title,
+			&program.Description,
+			&program.Weeks,
+			&program.CreatedAt,
+			&program.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		programs = append(programs, program)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return programs, nil
+}
+
+// replaces the fields and the days of the program, days that keep their week and day keep their id so linked workouts stay linked
+func (pg *PostgresProgramStore) UpdateProgram(ctx context.Context, program *Program) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE programs
+	SET title = $1, description = $2, weeks = $3, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $4
+	RETURNING updated_at
+	`
+
+	err = tx.QueryRowContext(ctx, query, program.Title, program.Description, program.Weeks, program.ID).Scan(&program.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = saveProgramDays(ctx, tx, program)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresProgramStore) DeleteProgram(ctx context.Context, id int64) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := pg.db.ExecContext(ctx, `DELETE FROM programs WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (pg *PostgresProgramStore) CreateEnrollment(ctx context.Context, enrollment *Enrollment) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	INSERT INTO program_enrollments (program_id, user_id, start_date)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at
+	`
+
+	return pg.db.QueryRowContext(ctx, query, enrollment.ProgramID, enrollment.UserID, enrollment.StartDate).
+		Scan(&enrollment.ID, &enrollment.CreatedAt)
+}
+
+func (pg *PostgresProgramStore) GetEnrollmentByID(ctx context.Context, id int64) (*Enrollment, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	enrollment := &Enrollment{}
+
+	query := `SELECT id, program_id, user_id, start_date, created_at FROM program_enrollments WHERE id = $1`
+
+	err := pg.db.QueryRowContext(ctx, query, id).Scan(&enrollment.ID, &enrollment.ProgramID, &enrollment.UserID, &enrollment.StartDate, &enrollment.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return enrollment, nil
+}
+
+func (pg *PostgresProgramStore) ListEnrollments(ctx context.Context, userID int) ([]*Enrollment, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT id, program_id, user_id, start_date, created_at
+	FROM program_enrollments
+	WHERE user_id = $1
+	ORDER BY start_date DESC, id DESC
+	`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	enrollments := []*Enrollment{}
+
+	for rows.Next() {
+		enrollment := &Enrollment{}
+
+		err := rows.Scan(&enrollment.ID, &enrollment.ProgramID, &enrollment.UserID, &enrollment.StartDate, &enrollment.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		enrollments = append(enrollments, enrollment)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return enrollments, nil
+}
+
+// planned days of the enrollment done by a completed workout, mapped to the first workout that completed them
+func (pg *PostgresProgramStore) CompletedDays(ctx context.Context, enrollmentID int) (map[int]int, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT program_day_id, MIN(id)
+	FROM workouts
+	WHERE enrollment_id = $1 AND program_day_id IS NOT NULL AND status = 'completed'
+	GROUP BY program_day_id
+	`
+
+	rows, err := pg.db.QueryContext(ctx, query, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	completed := map[int]int{}
+
+	for rows.Next() {
+		var dayID, workoutID int
+
+		err := rows.Scan(&dayID, &workoutID)
+		if err != nil {
+			return nil, err
+		}
+
+		completed[dayID] = workoutID
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return completed, nil
+}
+
+// upserts the days of the program by week and day and removes the days that are no longer planned,
+// the templates have to belong to the owner of the program
+func saveProgramDays(ctx context.Context, tx *tracedTx, program *Program) error {
+	weeks, days := []int{}, []int{}
+	for _, day := range program.Days {
+		weeks = append(weeks, day.Week)
+		days = append(days, day.Day)
+	}
+
+	_, err := tx.ExecContext(ctx, `
+	DELETE FROM program_days pd
+	WHERE pd.program_id = $1
+		AND NOT EXISTS (SELECT 1 FROM unnest($2::int[], $3::int[]) AS planned(week, day) WHERE planned.week = pd.week AND planned.day = pd.day)
+	`, program.ID, weeks, days)
+	if err != nil {
+		return err
+	}
+
+	for i := range program.Days {
+		day := &program.Days[i]
+
+		query := `
+		INSERT INTO program_days (program_id, week, day, template_id)
+		SELECT $1::bigint, $2::integer, $3::integer, t.id
+		FROM workout_templates t
+		WHERE t.id = $4 AND t.user_id = $5
+		ON CONFLICT (program_id, week, day) DO UPDATE SET template_id = EXCLUDED.template_id
+		RETURNING id, (SELECT title FROM workout_templates WHERE id = $4)
+		`
+
+		err := tx.QueryRowContext(ctx, query, program.ID, day.Week, day.Day, day.TemplateID, program.UserID).Scan(&day.ID, &day.TemplateTitle)
+		if err == sql.ErrNoRows {
+			return ErrUnknownTemplate
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
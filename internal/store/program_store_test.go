@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustDate(value string) time.Time {
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestProgramPosition(t *testing.T) {
+	program := &Program{Weeks: 2}
+	start := mustDate("2025-03-10")
+
+	tests := []struct {
+		name     string
+		date     time.Time
+		wantWeek int
+		wantDay  int
+		wantOK   bool
+	}{
+		{name: "start date", date: start, wantWeek: 1, wantDay: 1, wantOK: true},
+		{name: "later the same day", date: start.Add(20 * time.Hour), wantWeek: 1, wantDay: 1, wantOK: true},
+		{name: "second week", date: mustDate("2025-03-19"), wantWeek: 2, wantDay: 3, wantOK: true},
+		{name: "last day", date: mustDate("2025-03-23"), wantWeek: 2, wantDay: 7, wantOK: true},
+		{name: "before the start", date: mustDate("2025-03-09")},
+		{name: "after the last week", date: mustDate("2025-03-24")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			week, day, ok := program.Position(start, tt.date)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantWeek, week)
+			assert.Equal(t, tt.wantDay, day)
+		})
+	}
+}
+
+func TestProgramAdherence(t *testing.T) {
+	program := &Program{
+		Weeks: 2,
+		Days: []ProgramDay{
+			{ID: 1, Week: 1, Day: 1},
+			{ID: 2, Week: 1, Day: 3},
+			{ID: 3, Week: 2, Day: 1},
+			{ID: 4, Week: 2, Day: 3},
+		},
+	}
+	start := mustDate("2025-03-10")
+
+	tests := []struct {
+		name      string
+		completed map[int]int
+		today     time.Time
+		want      Adherence
+	}{
+		{
+			name:  "nothing due yet",
+			today: mustDate("2025-03-09"),
+			want:  Adherence{Planned: 4},
+		},
+		{
+			name:      "every due day completed",
+			completed: map[int]int{1: 10, 2: 11},
+			today:     mustDate("2025-03-16"),
+			want:      Adherence{Planned: 4, Due: 2, Completed: 2, Rate: 1},
+		},
+		{
+			name:      "missed day",
+			completed: map[int]int{1: 10, 2: 11},
+			today:     mustDate("2025-03-20"),
+			want:      Adherence{Planned: 4, Due: 4, Completed: 2, Rate: 0.5},
+		},
+		{
+			name:      "day done ahead of time",
+			completed: map[int]int{1: 10, 3: 12},
+			today:     mustDate("2025-03-12"),
+			want:      Adherence{Planned: 4, Due: 2, Completed: 2, Rate: 0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, program.Adherence(start, tt.completed, tt.today))
+		})
+	}
+}
+
+func TestPrograms(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	templateStore := NewPostgresTemplateStore(db, 0)
+	programStore := NewPostgresProgramStore(db, 0)
+	workoutStore := NewPostgresWorkoutStore(db, 0, nil)
+
+	user := &User{Username: "planner", Email: "planner@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	other := &User{Username: "stranger", Email: "stranger@gmail.com"}
+	require.NoError(t, other.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), other))
+
+	template := &WorkoutTemplate{
+		UserID:          user.ID,
+		Title:           "full body",
+		DurationMinutes: 45,
+		Entries:         []WorkoutEntry{{ExerciseName: "squat", Sets: 3, Reps: IntPtr(5), OrderIndex: 1}},
+	}
+	require.NoError(t, templateStore.CreateTemplate(context.Background(), template))
+
+	foreignTemplate := &WorkoutTemplate{UserID: other.ID, Title: "not mine", DurationMinutes: 30, Entries: []WorkoutEntry{}}
+	require.NoError(t, templateStore.CreateTemplate(context.Background(), foreignTemplate))
+
+	program := &Program{
+		UserID: user.ID,
+		Title:  "beginner strength",
+		Weeks:  2,
+		Days:   []ProgramDay{{Week: 1, Day: 1, TemplateID: template.ID}, {Week: 1, Day: 3, TemplateID: template.ID}},
+	}
+	require.NoError(t, programStore.CreateProgram(context.Background(), program))
+	assert.Equal(t, "full body", program.Days[0].TemplateTitle)
+
+	invalid := &Program{UserID: user.ID, Title: "borrowed", Weeks: 1, Days: []ProgramDay{{Week: 1, Day: 1, TemplateID: foreignTemplate.ID}}}
+	assert.ErrorIs(t, programStore.CreateProgram(context.Background(), invalid), ErrUnknownTemplate)
+
+	assert.ErrorIs(t, templateStore.DeleteTemplate(context.Background(), int64(template.ID)), ErrTemplateInUse)
+
+	enrollment := &Enrollment{ProgramID: program.ID, UserID: user.ID, StartDate: mustDate("2025-03-10")}
+	require.NoError(t, programStore.CreateEnrollment(context.Background(), enrollment))
+
+	firstDay := program.Days[0].ID
+	workout := template.NewWorkout(user.ID)
+	workout.EnrollmentID, workout.ProgramDayID = &enrollment.ID, &firstDay
+	_, err := workoutStore.CreateWorkout(context.Background(), workout)
+	require.NoError(t, err)
+
+	stolen := template.NewWorkout(other.ID)
+	stolen.EnrollmentID, stolen.ProgramDayID = &enrollment.ID, &firstDay
+	_, err = workoutStore.CreateWorkout(context.Background(), stolen)
+	assert.ErrorIs(t, err, ErrInvalidProgramDay)
+
+	completed, err := programStore.CompletedDays(context.Background(), enrollment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{firstDay: workout.ID}, completed)
+
+	//moving the second day keeps the first one and its completion
+	program.Days = []ProgramDay{{Week: 1, Day: 1, TemplateID: template.ID}, {Week: 2, Day: 2, TemplateID: template.ID}}
+	require.NoError(t, programStore.UpdateProgram(context.Background(), program))
+
+	retrieved, err := programStore.GetProgramByID(context.Background(), int64(program.ID))
+	require.NoError(t, err)
+	require.Len(t, retrieved.Days, 2)
+	assert.Equal(t, firstDay, retrieved.Days[0].ID)
+	assert.Equal(t, 2, retrieved.Days[1].Week)
+
+	enrollments, err := programStore.ListEnrollments(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, enrollments, 1)
+	assert.Equal(t, mustDate("2025-03-10"), enrollments[0].StartDate.UTC())
+
+	require.NoError(t, programStore.DeleteProgram(context.Background(), int64(program.ID)))
+
+	retrieved, err = programStore.GetProgramByID(context.Background(), int64(program.ID))
+	require.NoError(t, err)
+	assert.Nil(t, retrieved)
+}
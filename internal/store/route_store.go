@@ -0,0 +1,82 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// a point of the gps track of a workout, seq is the position of the point in the track
+type RoutePoint struct {
+	Seq        int        `json:"seq"`
+	Latitude   float64    `json:"latitude"`
+	Longitude  float64    `json:"longitude"`
+	Elevation  *float64   `json:"elevation_meters"`
+	RecordedAt *time.Time `json:"recorded_at"`
+	HeartRate  *int       `json:"heart_rate"`
+}
+
+// inserts the whole route with a single statement by sending every column as an array
+func insertRoutePoints(ctx context.Context, tx *tracedTx, workout *Workout) error {
+	if len(workout.Route) == 0 {
+		return nil
+	}
+
+	n := len(workout.Route)
+	seqs := make([]int, n)
+	latitudes := make([]float64, n)
+	longitudes := make([]float64, n)
+	elevations := make([]*float64, n)
+	times := make([]*time.Time, n)
+	heartRates := make([]*int, n)
+
+	for i, point := range workout.Route {
+		workout.Route[i].Seq = i + 1
+		seqs[i] = i + 1
+		latitudes[i], longitudes[i] = point.Latitude, point.Longitude
+		elevations[i], times[i], heartRates[i] = point.Elevation, point.RecordedAt, point.HeartRate
+	}
+
+	query := `
+	INSERT INTO route_points (workout_id, seq, latitude, longitude, elevation_meters, recorded_at, heart_rate)
+	SELECT $1::bigint, p.seq, p.latitude, p.longitude, p.elevation, p.recorded_at, p.heart_rate
+	FROM unnest($2::int[], $3::float8[], $4::float8[], $5::float8[], $6::timestamptz[], $7::int[])
+		AS p(seq, latitude, longitude, elevation, recorded_at, heart_rate)
+	`
+
+	_, err := tx.ExecContext(ctx, query, workout.ID, seqs, latitudes, longitudes, elevations, times, heartRates)
+	return err
+}
+
+// returns the route of a workout in the order it was recorded, workouts without a route return an empty slice
+func (pg *PostgresWorkoutStore) GetWorkoutRoute(ctx context.Context, workoutID int64) ([]RoutePoint, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT seq, latitude, longitude, elevation_meters, recorded_at, heart_rate
+	FROM route_points
+	WHERE workout_id = $1
+	ORDER BY seq
+	`
+
+	rows, err := pg.db.QueryContext(ctx, query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []RoutePoint{}
+
+	for rows.Next() {
+		var point RoutePoint
+
+		err = rows.Scan(&point.Seq, &point.Latitude, &point.Longitude, &point.Elevation, &point.RecordedAt, &point.HeartRate)
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
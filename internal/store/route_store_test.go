@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkoutRoute(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	workoutStore := NewPostgresWorkoutStore(db, 0, nil)
+
+	user := &User{Username: "runner", Email: "runner@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	start := time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)
+	later := start.Add(10 * time.Second)
+
+	workout, err := workoutStore.CreateWorkout(context.Background(), &Workout{
+		UserID:          user.ID,
+		Title:           "morning run",
+		DurationMinutes: 1,
+		PerformedAt:     start,
+		Entries:         []WorkoutEntry{{ExerciseName: "Running", Sets: 1, Distance: FloatPtr(0.03), DistanceUnit: StringPtr("km"), DurationSeconds: IntPtr(10), OrderIndex: 1}},
+		Route: []RoutePoint{
+			{Latitude: 44.4268, Longitude: 26.1025, Elevation: FloatPtr(70), RecordedAt: &start, HeartRate: IntPtr(120)},
+			{Latitude: 44.4270, Longitude: 26.1027, RecordedAt: &later},
+		},
+	})
+	require.NoError(t, err)
+
+	points, err := workoutStore.GetWorkoutRoute(context.Background(), int64(workout.ID))
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+
+	assert.Equal(t, 1, points[0].Seq)
+	assert.Equal(t, 44.4268, points[0].Latitude)
+	assert.Equal(t, 70.0, *points[0].Elevation)
+	assert.Equal(t, 120, *points[0].HeartRate)
+	assert.True(t, later.Equal(*points[1].RecordedAt))
+	assert.Nil(t, points[1].Elevation)
+	assert.Nil(t, points[1].HeartRate)
+
+	without, err := workoutStore.CreateWorkout(context.Background(), &Workout{UserID: user.ID, Title: "bench", DurationMinutes: 30, Entries: []WorkoutEntry{}})
+	require.NoError(t, err)
+
+	points, err = workoutStore.GetWorkoutRoute(context.Background(), int64(without.ID))
+	require.NoError(t, err)
+	assert.Empty(t, points)
+
+	require.NoError(t, workoutStore.DeleteWorkout(context.Background(), int64(workout.ID)))
+
+	points, err = workoutStore.GetWorkoutRoute(context.Background(), int64(workout.ID))
+	require.NoError(t, err)
+	assert.Empty(t, points, "the route is deleted with the workout")
+}
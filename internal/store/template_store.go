@@ -0,0 +1,313 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// routine a user repeats, its entries are copied into a new workout every time it is started
+type WorkoutTemplate struct {
+	ID              int            `json:"id"`
+	UserID          int            `json:"user_id"`
+	Title           string         `json:"title"`
+	Description     string         `json:"description"`
+	DurationMinutes int            `json:"duration_minutes"`
+	CaloriesBurned  int            `json:"calories_burned"`
+	Entries         []WorkoutEntry `json:"entries"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+}
+
+// builds a workout of the user from the template, the entries are copied so the workout can be changed freely
+func (t *WorkoutTemplate) NewWorkout(userID int) *Workout {
+	workout := &Workout{
+		UserID:          userID,
+		Title:           t.Title,
+		Description:     t.Description,
+		DurationMinutes: t.DurationMinutes,
+		CaloriesBurned:  t.CaloriesBurned,
+		Entries:         make([]WorkoutEntry, len(t.Entries)),
+	}
+
+	copy(workout.Entries, t.Entries)
+	for i := range workout.Entries {
+		workout.Entries[i].ID = 0
+	}
+
+	return workout
+}
+
+type PostgresTemplateStore struct {
+	db *tracedDB
+}
+
+// a zero query timeout disables the timeout
+func NewPostgresTemplateStore(db *sql.DB, queryTimeout time.Duration) *PostgresTemplateStore {
+	return &PostgresTemplateStore{db: newTracedDB(db, "PostgresTemplateStore", queryTimeout)}
+}
+
+type TemplateStore interface {
+	CreateTemplate(ctx context.Context, template *WorkoutTemplate) error
+	GetTemplateByID(ctx context.Context, id int64) (*WorkoutTemplate, error)
+	ListTemplates(ctx context.Context, userID int) ([]*WorkoutTemplate, error)
+	UpdateTemplate(ctx context.Context, template *WorkoutTemplate) error
+	DeleteTemplate(ctx context.Context, id int64) error
+}
+
+func (pg *PostgresTemplateStore) CreateTemplate(ctx context.Context, template *WorkoutTemplate) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO workout_templates (user_id, title, description, duration_minutes, calories_burned)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, updated_at
+	`
+
+	err = tx.QueryRowContext(ctx, query, template.UserID, template.Title, template.Description, template.DurationMinutes, template.CaloriesBurned).
+		Scan(&template.ID, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = insertTemplateEntries(ctx, tx, template)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresTemplateStore) GetTemplateByID(ctx context.Context, id int64) (*WorkoutTemplate, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	template := &WorkoutTemplate{}
+
+	query := `
+	SELECT id, user_id, title, COALESCE(description, ''), duration_minutes, COALESCE(calories_burned, 0), created_at, updated_at
+	FROM workout_templates
+	WHERE id = $1
+	`
+
+	err := pg.db.QueryRowContext(ctx, query, id).Scan(&template.ID,
+		&template.UserID,
+		&template.Title,
+		&template.Description,
+		&template.DurationMinutes,
+		&template.CaloriesBurned,
+		&template.CreatedAt,
+		&template.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	queryEntries := `
+	SELECT te.id, te.exercise_id, e.name, te.entry_type, te.sets, te.reps, te.duration_seconds, te.weight, COALESCE(te.notes, ''), te.order_index,
+		te.distance, te.distance_unit, te.avg_heart_rate, te.max_heart_rate, te.elevation_gain_meters
+	FROM template_entries te
+	INNER JOIN exercises e ON e.id = te.exercise_id
+	WHERE te.template_id = $1
+	ORDER BY te.order_index
+	`
+
+	rows, err := pg.db.QueryContext(ctx, queryEntries, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	template.Entries = []WorkoutEntry{}
+
+	for rows.Next() {
+		var entry WorkoutEntry
+
+		err := rows.Scan(&entry.ID,
+			&entry.ExerciseID,
+			&entry.ExerciseName,
+			&entry.EntryType,
+			&entry.Sets,
+			&entry.Reps,
+			&entry.DurationSeconds,
+			&entry.Weight,
+			&entry.Notes,
+			&entry.OrderIndex,
+			&entry.Distance,
+			&entry.DistanceUnit,
+			&entry.AvgHeartRate,
+			&entry.MaxHeartRate,
+			&entry.ElevationGainMeters)
+		if err != nil {
+			return nil, err
+		}
+
+		template.Entries = append(template.Entries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	computeCardioMetrics(template.Entries)
+
+	return template, nil
+}
+
+// lists the templates of the user without their entries
+func (pg *PostgresTemplateStore) ListTemplates(ctx context.Context, userID int) ([]*WorkoutTemplate, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT id, user_id, title, COALESCE(description, ''), duration_minutes, COALESCE(calories_burned, 0), created_at, updated_at
+	FROM workout_templates
+	WHERE user_id = $1
+	ORDER BY lower(title), id
+	`
+
+	rows, err := pg.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	templates := []*WorkoutTemplate{}
+
+	for rows.Next() {
+		template := &WorkoutTemplate{}
+
+		err := rows.Scan(&template.ID,
+			&template.UserID,
+			&template.Title,
+			&template.Description,
+			&template.DurationMinutes,
+			&template.CaloriesBurned,
+			&template.CreatedAt,
+			&template.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, template)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// replaces the fields and the entries of the template
+func (pg *PostgresTemplateStore) UpdateTemplate(ctx context.Context, template *WorkoutTemplate) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	query := `
+	UPDATE workout_templates
+	SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $5
+	RETURNING updated_at
+	`
+
+	err = tx.QueryRowContext(ctx, query, template.Title, template.Description, template.DurationMinutes, template.CaloriesBurned, template.ID).
+		Scan(&template.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM template_entries WHERE template_id = $1`, template.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertTemplateEntries(ctx, tx, template)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresTemplateStore) DeleteTemplate(ctx context.Context, id int64) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := pg.db.ExecContext(ctx, `DELETE FROM workout_templates WHERE id = $1`, id)
+	if pgErrorCode(err) == foreignKeyViolation {
+		return ErrTemplateInUse
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// resolves the exercises of the entries against the catalog and inserts them, entries sent without a type get the inferred one
+func insertTemplateEntries(ctx context.Context, tx *tracedTx, template *WorkoutTemplate) error {
+	for i := range template.Entries {
+		entry := &template.Entries[i]
+
+		err := resolveExercise(ctx, tx, entry, template.UserID)
+		if err != nil {
+			return err
+		}
+
+		if entry.EntryType == "" {
+			entry.EntryType = inferEntryType(entry)
+		}
+
+		query := `
+		INSERT INTO template_entries (template_id, exercise_id, entry_type, sets, reps, duration_seconds, weight, notes, order_index,
+			distance, distance_unit, avg_heart_rate, max_heart_rate, elevation_gain_meters)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+		`
+
+		err = tx.QueryRowContext(ctx, query, template.ID, entry.ExerciseID, entry.EntryType, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex,
+			entry.Distance, entry.DistanceUnit, entry.AvgHeartRate, entry.MaxHeartRate, entry.ElevationGainMeters).Scan(&entry.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	computeCardioMetrics(template.Entries)
+
+	return nil
+}
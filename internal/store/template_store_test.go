@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplates(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	templateStore := NewPostgresTemplateStore(db, 0)
+	workoutStore := NewPostgresWorkoutStore(db, 0, nil)
+
+	user := &User{Username: "routine", Email: "routine@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("password123"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	template := &WorkoutTemplate{
+		UserID:          user.ID,
+		Title:           "leg day",
+		DurationMinutes: 60,
+		Entries: []WorkoutEntry{
+			{ExerciseName: "back squat", Sets: 5, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+			{ExerciseName: "Plank", Sets: 3, DurationSeconds: IntPtr(60), OrderIndex: 2},
+		},
+	}
+	require.NoError(t, templateStore.CreateTemplate(context.Background(), template))
+
+	retrieved, err := templateStore.GetTemplateByID(context.Background(), int64(template.ID))
+	require.NoError(t, err)
+	require.Len(t, retrieved.Entries, 2)
+	assert.Equal(t, "Squat", retrieved.Entries[0].ExerciseName)
+
+	workout, err := workoutStore.CreateWorkout(context.Background(), retrieved.NewWorkout(user.ID))
+	require.NoError(t, err)
+	assert.Len(t, workout.Entries, 2)
+	assert.NotEqual(t, retrieved.Entries[0].ID, workout.Entries[0].ID)
+
+	retrieved.Title = "heavy leg day"
+	retrieved.Entries = retrieved.Entries[:1]
+	require.NoError(t, templateStore.UpdateTemplate(context.Background(), retrieved))
+
+	templates, err := templateStore.ListTemplates(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+	assert.Equal(t, "heavy leg day", templates[0].Title)
+
+	require.NoError(t, templateStore.DeleteTemplate(context.Background(), int64(template.ID)))
+
+	retrieved, err = templateStore.GetTemplateByID(context.Background(), int64(template.ID))
+	require.NoError(t, err)
+	assert.Nil(t, retrieved)
+}
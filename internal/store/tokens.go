@@ -1,56 +1,395 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"time"
 
+	"github.com/zidariu-sabin/femProject/internal/metrics"
 	"github.com/zidariu-sabin/femProject/internal/tokens"
 )
 
+var (
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// returned when an already rotated refresh token is presented again, the whole token family is revoked
+	ErrTokenReused = errors.New("refresh token reuse detected")
+)
+
+// details of the client a token was issued to or last used by
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// a login session groups every token of the same family
+type Session struct {
+	ID         string     `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
+
+// details of a token that are safe to hand to its owner, the hash is left out
+type TokenMetadata struct {
+	Scope      string     `json:"scope"`
+	FamilyID   string     `json:"family_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	UsedAt     *time.Time `json:"used_at"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+}
+
 type PostgresTokenStore struct {
-	db *sql.DB
+	db      *tracedDB
+	metrics *metrics.Metrics
 }
 
-func NewPostgresTokenStore(db *sql.DB) *PostgresTokenStore {
+// metrics can be nil and a zero query timeout disables the timeout
+func NewPostgresTokenStore(db *sql.DB, queryTimeout time.Duration, m *metrics.Metrics) *PostgresTokenStore {
 	return &PostgresTokenStore{
-		db: db,
+		db:      newTracedDB(db, "PostgresTokenStore", queryTimeout),
+		metrics: m,
 	}
 }
 
 type TokenStore interface {
-	Insert(token *tokens.Token) error
-	CreateNewToken(userID int, ttl time.Duration, scope string) (*tokens.Token, error)
-	DeleteAllTokensForUser(userID int, scope string) error
+	Insert(ctx context.Context, token *tokens.Token) error
+	CreateNewToken(ctx context.Context, userID int, ttl time.Duration, scope string) (*tokens.Token, error)
+	CreateTokenPair(ctx context.Context, userID int, accessTTL, refreshTTL time.Duration, client ClientInfo) (*tokens.TokenPair, error)
+	RotateRefreshToken(ctx context.Context, refreshPlainText string, accessTTL, refreshTTL time.Duration, client ClientInfo) (*tokens.TokenPair, error)
+	DeleteAllTokensForUser(ctx context.Context, userID int, scope string) error
+	DeleteTokenFamily(ctx context.Context, userID int, tokenPlainText string) error
+	TouchToken(ctx context.Context, tokenPlainText string, client ClientInfo) error
+	ListSessions(ctx context.Context, userID int, currentTokenPlainText string) ([]*Session, error)
+	DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error)
+	ListTokenMetadata(ctx context.Context, userID int) ([]*TokenMetadata, error)
 }
 
-func (th *PostgresTokenStore) CreateNewToken(userID int, ttl time.Duration, scope string) (*tokens.Token, error) {
+func (th *PostgresTokenStore) CreateNewToken(ctx context.Context, userID int, ttl time.Duration, scope string) (*tokens.Token, error) {
 	token, err := tokens.GenerateToken(userID, ttl, scope)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = th.Insert(token)
+	err = th.Insert(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	th.metrics.TokenIssued(scope)
+
+	return token, nil
+}
+
+func (th *PostgresTokenStore) Insert(ctx context.Context, token *tokens.Token) error {
+	ctx, cancel := th.db.withTimeout(ctx)
+	defer cancel()
+
+	return insertToken(ctx, th.db, token, ClientInfo{})
+}
+
+// issues an access and refresh token starting a new token family
+func (th *PostgresTokenStore) CreateTokenPair(ctx context.Context, userID int, accessTTL, refreshTTL time.Duration, client ClientInfo) (*tokens.TokenPair, error) {
+	ctx, cancel := th.db.withTimeout(ctx)
+	defer cancel()
+
+	pair, err := tokens.GenerateTokenPair(userID, accessTTL, refreshTTL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := th.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	err = insertTokenPair(ctx, tx, pair, client)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	th.observeTokenPair(pair)
+
+	return pair, nil
+}
 
-	return token, err
+func (th *PostgresTokenStore) observeTokenPair(pair *tokens.TokenPair) {
+	th.metrics.TokenIssued(pair.Access.Scope)
+	th.metrics.TokenIssued(pair.Refresh.Scope)
 }
 
-func (th *PostgresTokenStore) Insert(token *tokens.Token) error {
+// exchanges a refresh token for a new pair in the same family, the used refresh token is marked so a replay of it
+// can be detected, in which case every token of the family is deleted
+func (th *PostgresTokenStore) RotateRefreshToken(ctx context.Context, refreshPlainText string, accessTTL, refreshTTL time.Duration, client ClientInfo) (*tokens.TokenPair, error) {
+	ctx, cancel := th.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := th.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
 	query := `
-	INSERT INTO tokens (hash, user_id, expiry, scope)
-	VALUES ($1, $2, $3, $4)`
+	SELECT user_id, family_id, expiry, used_at
+	FROM tokens
+	WHERE hash = $1 AND scope = $2
+	FOR UPDATE`
 
-	_, err := th.db.Exec(query, token.Hash, token.UserID, token.Expiry, token.Scope)
+	var userID int
+	var family string
+	var expiry time.Time
+	var usedAt sql.NullTime
 
-	return err
+	err = tx.QueryRowContext(ctx, query, tokens.Hash(refreshPlainText), tokens.ScopeRefresh).Scan(&userID, &family, &expiry, &usedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if usedAt.Valid {
+		_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE family_id = $1`, family)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, ErrTokenReused
+	}
+
+	if !expiry.After(time.Now()) {
+		return nil, ErrInvalidToken
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE tokens SET used_at = CURRENT_TIMESTAMP WHERE hash = $1`, tokens.Hash(refreshPlainText))
+	if err != nil {
+		return nil, err
+	}
+
+	pair, err := tokens.GenerateTokenPair(userID, accessTTL, refreshTTL, family)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertTokenPair(ctx, tx, pair, client)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	th.observeTokenPair(pair)
+
+	return pair, nil
 }
 
-func (th *PostgresTokenStore) DeleteAllTokensForUser(userID int, scope string) error {
+func (th *PostgresTokenStore) DeleteAllTokensForUser(ctx context.Context, userID int, scope string) error {
+	ctx, cancel := th.db.withTimeout(ctx)
+	defer cancel()
+
 	query := `
 	DELETE FROM tokens
 	WHERE user_id = $1 AND scope = $2`
 
-	_, err := th.db.Exec(query, userID, scope)
+	_, err := th.db.ExecContext(ctx, query, userID, scope)
 
 	return err
 }
+
+// deletes every token sharing the family of the given token, logging out that session
+func (th *PostgresTokenStore) DeleteTokenFamily(ctx context.Context, userID int, tokenPlainText string) error {
+	ctx, cancel := th.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	DELETE FROM tokens
+	WHERE user_id = $1 AND family_id = (SELECT family_id FROM tokens WHERE hash = $2)`
+
+	result, err := th.db.ExecContext(ctx, query, userID, tokens.Hash(tokenPlainText))
+
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// records the last use of a token, writes are skipped if the token was already touched in the last minute
+func (th *PostgresTokenStore) TouchToken(ctx context.Context, tokenPlainText string, client ClientInfo) error {
+	ctx, cancel := th.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	UPDATE tokens
+	SET last_used_at = CURRENT_TIMESTAMP, user_agent = $2, ip_address = $3
+	WHERE hash = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')`
+
+	_, err := th.db.ExecContext(ctx, query, tokens.Hash(tokenPlainText), client.UserAgent, client.IP)
+
+	return err
+}
+
+// lists the active sessions of a user, the details of the most recently used token of every family are shown
+func (th *PostgresTokenStore) ListSessions(ctx context.Context, userID int, currentTokenPlainText string) ([]*Session, error) {
+	ctx, cancel := th.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT family_id,
+		MIN(created_at),
+		MAX(last_used_at),
+		(array_agg(user_agent ORDER BY COALESCE(last_used_at, created_at) DESC))[1],
+		(array_agg(ip_address ORDER BY COALESCE(last_used_at, created_at) DESC))[1],
+		MAX(expiry),
+		bool_or(hash = $2)
+	FROM tokens
+	WHERE user_id = $1 AND scope IN ($3, $4) AND expiry > CURRENT_TIMESTAMP AND used_at IS NULL
+	GROUP BY family_id
+	ORDER BY MAX(COALESCE(last_used_at, created_at)) DESC`
+
+	rows, err := th.db.QueryContext(ctx, query, userID, tokens.Hash(currentTokenPlainText), tokens.ScopeAuth, tokens.ScopeRefresh)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []*Session{}
+
+	for rows.Next() {
+		session := &Session{}
+
+		err := rows.Scan(&session.ID,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.UserAgent,
+			&session.IP,
+			&session.ExpiresAt,
+			&session.Current)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// lists every token of a user, expired and used ones included, for the personal data export
+func (th *PostgresTokenStore) ListTokenMetadata(ctx context.Context, userID int) ([]*TokenMetadata, error) {
+	ctx, cancel := th.db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+	SELECT scope, family_id, created_at, expiry, last_used_at, used_at, user_agent, ip_address
+	FROM tokens
+	WHERE user_id = $1
+	ORDER BY created_at, family_id`
+
+	rows, err := th.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	metadata := []*TokenMetadata{}
+
+	for rows.Next() {
+		token := &TokenMetadata{}
+
+		err := rows.Scan(&token.Scope, &token.FamilyID, &token.CreatedAt, &token.ExpiresAt, &token.LastUsedAt, &token.UsedAt, &token.UserAgent, &token.IP)
+		if err != nil {
+			return nil, err
+		}
+
+		metadata = append(metadata, token)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+// removes every token that expired before the given time, returns the number of deleted tokens
+func (th *PostgresTokenStore) DeleteExpiredTokens(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := th.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := th.db.ExecContext(ctx, `DELETE FROM tokens WHERE expiry < $1`, before)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// common interface of *sql.DB and *sql.Tx so inserts can run inside or outside of a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertToken(ctx context.Context, db execer, token *tokens.Token, client ClientInfo) error {
+	query := `
+	INSERT INTO tokens (hash, user_id, expiry, scope, family_id, user_agent, ip_address)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := db.ExecContext(ctx, query, token.Hash, token.UserID, token.Expiry, token.Scope, token.Family, client.UserAgent, client.IP)
+
+	return err
+}
+
+func insertTokenPair(ctx context.Context, db execer, pair *tokens.TokenPair, client ClientInfo) error {
+	err := insertToken(ctx, db, pair.Access, client)
+	if err != nil {
+		return err
+	}
+
+	return insertToken(ctx, db, pair.Refresh, client)
+}
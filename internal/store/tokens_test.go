@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/tokens"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	store := NewPostgresTokenStore(db, 0, nil)
+
+	user := &User{Username: "rotator", Email: "rotator@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("rotatorPassword"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	pair, err := store.CreateTokenPair(context.Background(), user.ID, time.Minute, time.Hour, ClientInfo{})
+	require.NoError(t, err)
+
+	rotated, err := store.RotateRefreshToken(context.Background(), pair.Refresh.PlainText, time.Minute, time.Hour, ClientInfo{})
+	require.NoError(t, err)
+	assert.NotEqual(t, pair.Refresh.PlainText, rotated.Refresh.PlainText)
+	assert.Equal(t, pair.Refresh.Family, rotated.Refresh.Family)
+
+	authenticated, err := userStore.GetUserToken(context.Background(), tokens.ScopeAuth, rotated.Access.PlainText)
+	require.NoError(t, err)
+	require.NotNil(t, authenticated)
+	assert.Equal(t, user.ID, authenticated.ID)
+
+	//replaying the first refresh token revokes the whole family
+	_, err = store.RotateRefreshToken(context.Background(), pair.Refresh.PlainText, time.Minute, time.Hour, ClientInfo{})
+	assert.ErrorIs(t, err, ErrTokenReused)
+
+	_, err = store.RotateRefreshToken(context.Background(), rotated.Refresh.PlainText, time.Minute, time.Hour, ClientInfo{})
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	authenticated, err = userStore.GetUserToken(context.Background(), tokens.ScopeAuth, rotated.Access.PlainText)
+	require.NoError(t, err)
+	assert.Nil(t, authenticated)
+}
+
+func TestSessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	store := NewPostgresTokenStore(db, 0, nil)
+
+	user := &User{Username: "sessions", Email: "sessions@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("sessionsPassword"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	laptop, err := store.CreateTokenPair(context.Background(), user.ID, time.Minute, time.Hour, ClientInfo{UserAgent: "laptop", IP: "10.0.0.1"})
+	require.NoError(t, err)
+	_, err = store.CreateTokenPair(context.Background(), user.ID, time.Minute, time.Hour, ClientInfo{UserAgent: "phone", IP: "10.0.0.2"})
+	require.NoError(t, err)
+
+	require.NoError(t, store.TouchToken(context.Background(), laptop.Access.PlainText, ClientInfo{UserAgent: "laptop", IP: "10.0.0.3"}))
+
+	sessions, err := store.ListSessions(context.Background(), user.ID, laptop.Access.PlainText)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.True(t, sessions[0].Current)
+	assert.Equal(t, "10.0.0.3", sessions[0].IP)
+	assert.NotNil(t, sessions[0].LastUsedAt)
+	assert.False(t, sessions[1].Current)
+
+	require.NoError(t, store.DeleteTokenFamily(context.Background(), user.ID, laptop.Access.PlainText))
+
+	sessions, err = store.ListSessions(context.Background(), user.ID, laptop.Access.PlainText)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "phone", sessions[0].UserAgent)
+}
+
+func TestDeleteExpiredTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	store := NewPostgresTokenStore(db, 0, nil)
+
+	user := &User{Username: "reaped", Email: "reaped@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("reapedPassword"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	_, err := store.CreateNewToken(context.Background(), user.ID, -time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+	active, err := store.CreateNewToken(context.Background(), user.ID, time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+
+	deleted, err := store.DeleteExpiredTokens(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	var remaining int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM tokens WHERE user_id = $1`, user.ID).Scan(&remaining))
+	assert.Equal(t, 1, remaining)
+
+	authenticated, err := userStore.GetUserToken(context.Background(), tokens.ScopeAuth, active.PlainText)
+	require.NoError(t, err)
+	assert.NotNil(t, authenticated)
+}
+
+func TestUpdatePasswordRevokesOtherSessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	store := NewPostgresTokenStore(db, 0, nil)
+
+	user := &User{Username: "forgetful", Email: "forgetful@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("forgetfulPassword"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	current, err := store.CreateTokenPair(context.Background(), user.ID, time.Minute, time.Hour, ClientInfo{})
+	require.NoError(t, err)
+	other, err := store.CreateTokenPair(context.Background(), user.ID, time.Minute, time.Hour, ClientInfo{})
+	require.NoError(t, err)
+	reset, err := store.CreateNewToken(context.Background(), user.ID, time.Hour, tokens.ScopePasswordReset)
+	require.NoError(t, err)
+	feed, err := store.CreateNewToken(context.Background(), user.ID, time.Hour, tokens.ScopeCalendar)
+	require.NoError(t, err)
+
+	require.NoError(t, user.PasswordHash.Set("rememberedPassword"))
+	require.NoError(t, userStore.UpdatePassword(context.Background(), user, current.Access.PlainText))
+
+	saved, err := userStore.GetUserByEmail(context.Background(), "forgetful@gmail.com")
+	require.NoError(t, err)
+	require.NotNil(t, saved)
+	matches, err := saved.PasswordHash.Matches("rememberedPassword")
+	require.NoError(t, err)
+	assert.True(t, matches)
+
+	for _, tt := range []struct {
+		scope string
+		token string
+		valid bool
+	}{
+		{tokens.ScopeAuth, current.Access.PlainText, true},
+		{tokens.ScopeAuth, other.Access.PlainText, false},
+		{tokens.ScopePasswordReset, reset.PlainText, false},
+		{tokens.ScopeCalendar, feed.PlainText, true},
+	} {
+		authenticated, err := userStore.GetUserToken(context.Background(), tt.scope, tt.token)
+		require.NoError(t, err)
+		assert.Equal(t, tt.valid, authenticated != nil, tt.scope)
+	}
+
+	//without a current token every session is revoked
+	require.NoError(t, userStore.UpdatePassword(context.Background(), user, ""))
+
+	authenticated, err := userStore.GetUserToken(context.Background(), tokens.ScopeAuth, current.Access.PlainText)
+	require.NoError(t, err)
+	assert.Nil(t, authenticated)
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/zidariu-sabin/femProject/internal/store")
+
+// wraps the database of a store so every sql statement is recorded as a span named after the store
+// only the context aware methods are exposed so no query can run detached from the request that issued it
+type tracedDB struct {
+	db           *sql.DB
+	store        string
+	queryTimeout time.Duration
+}
+
+func newTracedDB(db *sql.DB, store string, queryTimeout time.Duration) *tracedDB {
+	return &tracedDB{db: db, store: store, queryTimeout: queryTimeout}
+}
+
+// bounds the queries of a single store method, a zero timeout only keeps the deadline of the caller
+func (db *tracedDB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// transaction whose statements are recorded the same way as the ones of its database
+type tracedTx struct {
+	tx    *sql.Tx
+	store string
+}
+
+func startQuerySpan(ctx context.Context, store, query string) (context.Context, trace.Span) {
+	operation := strings.ToUpper(strings.SplitN(strings.TrimSpace(query), " ", 2)[0])
+
+	return tracer.Start(ctx, store+" "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", strings.Join(strings.Fields(query), " ")),
+		))
+}
+
+func endQuerySpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (db *tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, db.store, query)
+	result, err := db.db.ExecContext(ctx, query, args...)
+	endQuerySpan(span, err)
+	return result, err
+}
+
+// the span covers running the query, not reading the rows
+func (db *tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, db.store, query)
+	rows, err := db.db.QueryContext(ctx, query, args...)
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+func (db *tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, db.store, query)
+	row := db.db.QueryRowContext(ctx, query, args...)
+	endQuerySpan(span, row.Err())
+	return row
+}
+
+func (db *tracedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*tracedTx, error) {
+	tx, err := db.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedTx{tx: tx, store: db.store}, nil
+}
+
+func (tx *tracedTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, tx.store, query)
+	result, err := tx.tx.ExecContext(ctx, query, args...)
+	endQuerySpan(span, err)
+	return result, err
+}
+
+func (tx *tracedTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, tx.store, query)
+	rows, err := tx.tx.QueryContext(ctx, query, args...)
+	endQuerySpan(span, err)
+	return rows, err
+}
+
+func (tx *tracedTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, tx.store, query)
+	row := tx.tx.QueryRowContext(ctx, query, args...)
+	endQuerySpan(span, row.Err())
+	return row
+}
+
+func (tx *tracedTx) Commit() error {
+	return tx.tx.Commit()
+}
+
+func (tx *tracedTx) Rollback() error {
+	return tx.tx.Rollback()
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCancellation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	t.Run("cancelled context aborts the running query", func(t *testing.T) {
+		traced := newTracedDB(db, "test", 0)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := traced.ExecContext(ctx, `SELECT pg_sleep(5)`)
+		require.Error(t, err)
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("query timeout bounds a store call", func(t *testing.T) {
+		traced := newTracedDB(db, "test", 100*time.Millisecond)
+
+		ctx, cancel := traced.withTimeout(context.Background())
+		defer cancel()
+
+		start := time.Now()
+		_, err := traced.ExecContext(ctx, `SELECT pg_sleep(5)`)
+		require.Error(t, err)
+		assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+}
@@ -1,15 +1,19 @@
 package store
 
 import (
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
+	"github.com/zidariu-sabin/femProject/internal/tokens"
 	"golang.org/x/crypto/bcrypt"
-	_ "golang.org/x/crypto/bcrypt"
 )
 
+const DefaultBcryptCost = 12
+
 type password struct {
 	plainText *string
 	//uint8 0 <= val < 256
@@ -17,8 +21,13 @@ type password struct {
 }
 
 func (p *password) Set(plainTextPassword string) error {
+	return p.SetWithCost(plainTextPassword, DefaultBcryptCost)
+}
+
+// hashes the password with the given bcrypt cost, higher costs are slower to hash and to brute force
+func (p *password) SetWithCost(plainTextPassword string, cost int) error {
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(plainTextPassword), 12)
+	hash, err := bcrypt.GenerateFromPassword([]byte(plainTextPassword), cost)
 
 	if err != nil {
 		return err
@@ -54,6 +63,18 @@ type User struct {
 	Bio          string    `json:"bio"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"Updated_at"`
+	//the email was verified with the activation token sent at registration
+	Activated bool `json:"activated"`
+	//set while a requested deletion of the account waits for its grace period
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+}
+
+// public view of a user which is safe to show to other users, the email is intentionally left out
+type PublicUser struct {
+	ID        int       `json:"id"`
+	Username  string    `json:"username"`
+	Bio       string    `json:"bio"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 var AnonymousUser = &User{}
@@ -64,30 +85,41 @@ func (u *User) IsAnonymous() bool {
 }
 
 type PostgresUserStore struct {
-	db *sql.DB
+	db *tracedDB
 }
 
-func NewPostgresUserStore(db *sql.DB) *PostgresUserStore {
+// a zero query timeout disables the timeout
+func NewPostgresUserStore(db *sql.DB, queryTimeout time.Duration) *PostgresUserStore {
 	return &PostgresUserStore{
-		db: db,
+		db: newTracedDB(db, "PostgresUserStore", queryTimeout),
 	}
 }
 
 type UserStore interface {
-	CreateUser(*User) error
-	GetUserByUsername(username string) (*User, error)
-	UpdateUser(*User) error
-	GetUserToken(scope, tokenPlainText string) (*User, error)
+	CreateUser(ctx context.Context, user *User) error
+	GetUserByUsername(ctx context.Context, username string) (*User, error)
+	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	UpdateUser(ctx context.Context, user *User) error
+	UpdatePassword(ctx context.Context, user *User, currentTokenPlainText string) error
+	ActivateUser(ctx context.Context, userID int) error
+	GetUserToken(ctx context.Context, scope, tokenPlainText string) (*User, error)
+	SearchUsers(ctx context.Context, query string, limit, offset int) ([]*PublicUser, error)
+	DeleteUser(ctx context.Context, userID int) error
+	ScheduleUserDeletion(ctx context.Context, userID int, at *time.Time) error
+	DeleteScheduledUsers(ctx context.Context, before time.Time) (int64, error)
 }
 
-func (pg *PostgresUserStore) CreateUser(user *User) error {
+func (pg *PostgresUserStore) CreateUser(ctx context.Context, user *User) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
 	query := `
 	INSERT INTO users (username, email, password_hash, bio) 
 	VALUES ($1, $2, $3, $4)
-	RETURNING id, created_at, updated_at
+	RETURNING id, created_at, updated_at, activated
 	`
 
-	err := pg.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	err := pg.db.QueryRowContext(ctx, query, user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt, &user.Activated)
 
 	if err != nil {
 		return err
@@ -96,18 +128,21 @@ func (pg *PostgresUserStore) CreateUser(user *User) error {
 	return nil
 }
 
-func (pg *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
+func (pg *PostgresUserStore) GetUserByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
 	user := &User{
 		PasswordHash: password{},
 	}
 
 	query := `
-	SELECT id, username, email, password_hash, bio, created_at, updated_at
+	SELECT id, username, email, password_hash, bio, created_at, updated_at, deletion_scheduled_at, activated
 	FROM users
 	WHERE username = $1
 	`
 
-	err := pg.db.QueryRow(query, username).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.CreatedAt, &user.UpdatedAt)
+	err := pg.db.QueryRowContext(ctx, query, username).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.CreatedAt, &user.UpdatedAt, &user.DeletionScheduledAt, &user.Activated)
 	//returning now rows is not an error, it just means there is no data for the query
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -120,14 +155,43 @@ func (pg *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
 	return user, nil
 }
 
-func (pg *PostgresUserStore) UpdateUser(user *User) error {
+func (pg *PostgresUserStore) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	user := &User{
+		PasswordHash: password{},
+	}
+
+	query := `
+	SELECT id, username, email, password_hash, bio, created_at, updated_at, deletion_scheduled_at, activated
+	FROM users
+	WHERE email = $1
+	`
+
+	err := pg.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash.hash, &user.Bio, &user.CreatedAt, &user.UpdatedAt, &user.DeletionScheduledAt, &user.Activated)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (pg *PostgresUserStore) UpdateUser(ctx context.Context, user *User) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
 	query := `
 				UPDATE users
 				SET username = $1, email = $2, bio = $3, updated_at = CURRENT_TIMESTAMP
 				WHERE id = $4
 				RETURNING updated_at
 			`
-	result, err := pg.db.Exec(query, user.Username, user.Email, user.Bio, user.ID)
+	result, err := pg.db.ExecContext(ctx, query, user.Username, user.Email, user.Bio, user.ID)
 
 	if err != nil {
 		return err
@@ -146,11 +210,88 @@ func (pg *PostgresUserStore) UpdateUser(user *User) error {
 	return nil
 }
 
-func (pg *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User, error) {
+// saves the password hash of the user and revokes every session and password reset token of the user
+// except the session of currentTokenPlainText, an empty token revokes every session
+func (pg *PostgresUserStore) UpdatePassword(ctx context.Context, user *User, currentTokenPlainText string) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, user.PasswordHash.hash, user.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	currentHash := sha256.Sum256([]byte(currentTokenPlainText))
+
+	query := `
+	DELETE FROM tokens
+	WHERE user_id = $1 AND scope IN ($2, $3, $4)
+		AND family_id IS DISTINCT FROM (SELECT family_id FROM tokens WHERE hash = $5 AND user_id = $1)`
+
+	_, err = tx.ExecContext(ctx, query, user.ID, tokens.ScopeAuth, tokens.ScopeRefresh, tokens.ScopePasswordReset, currentHash[:])
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// marks the email of the user as verified and deletes the activation tokens that are left
+func (pg *PostgresUserStore) ActivateUser(ctx context.Context, userID int) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE users SET activated = TRUE, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE user_id = $1 AND scope = $2`, userID, tokens.ScopeActivation)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (pg *PostgresUserStore) GetUserToken(ctx context.Context, scope, tokenPlainText string) (*User, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
 	tokenHash := sha256.Sum256([]byte(tokenPlainText))
 
 	query := `
-	SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.created_at, u.updated_at
+	SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.created_at, u.updated_at, u.deletion_scheduled_at, u.activated
 	FROM users u
 	INNER JOIN tokens t ON t.user_id = u.id
 	WHERE t.hash = $1 AND t.scope = $2 and t.expiry > $3
@@ -160,7 +301,7 @@ func (pg *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User,
 		PasswordHash: password{},
 	}
 
-	err := pg.db.QueryRow(query, tokenHash[:], scope, time.Now()).Scan(
+	err := pg.db.QueryRowContext(ctx, query, tokenHash[:], scope, time.Now()).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -168,6 +309,8 @@ func (pg *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User,
 		&user.Bio,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.DeletionScheduledAt,
+		&user.Activated,
 	)
 
 	if err == sql.ErrNoRows {
@@ -180,3 +323,140 @@ func (pg *PostgresUserStore) GetUserToken(scope, tokenPlainText string) (*User,
 
 	return user, nil
 }
+
+// escapes the LIKE wildcards so user input is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searches users by username prefix, by the prefix of a word in the bio and by trigram similarity on the username and bio
+// username prefix matches are ranked first, then bio word prefix matches, followed by the closest fuzzy matches
+func (pg *PostgresUserStore) SearchUsers(ctx context.Context, query string, limit, offset int) ([]*PublicUser, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	sqlQuery := `
+	SELECT id, username, COALESCE(bio, ''), created_at
+	FROM users
+	WHERE username ILIKE $2 || '%' OR bio ILIKE $2 || '%' OR bio ILIKE '% ' || $2 || '%' OR username % $1 OR $1 <% bio
+	ORDER BY username ILIKE $2 || '%' DESC,
+		(bio ILIKE $2 || '%' OR bio ILIKE '% ' || $2 || '%') IS TRUE DESC,
+		GREATEST(similarity(username, $1), word_similarity($1, COALESCE(bio, ''))) DESC,
+		username
+	LIMIT $3 OFFSET $4
+	`
+
+	rows, err := pg.db.QueryContext(ctx, sqlQuery, query, likeEscaper.Replace(query), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []*PublicUser{}
+
+	for rows.Next() {
+		user := &PublicUser{}
+
+		err := rows.Scan(&user.ID, &user.Username, &user.Bio, &user.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// deletes the account together with its workouts, templates, programs, plans, records, tokens and custom exercises
+func (pg *PostgresUserStore) DeleteUser(ctx context.Context, userID int) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	deleted, err := pg.deleteUsers(ctx, `id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// the programs are deleted first because their days RESTRICT the deletion of the templates they use,
+// which would otherwise depend on the order the cascades run in
+func (pg *PostgresUserStore) deleteUsers(ctx context.Context, condition string, arg interface{}) (int64, error) {
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	deletedUsers := `(SELECT id FROM users WHERE ` + condition + `)`
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM programs WHERE user_id IN `+deletedUsers, arg)
+	if err != nil {
+		return 0, err
+	}
+
+	//workout and template entries RESTRICT the deletion of their exercise so the entries of the user are removed before
+	//the custom exercises, only the user logs with a custom exercise so none of them is still referenced afterwards
+	for _, query := range []string{
+		`DELETE FROM workouts WHERE user_id IN ` + deletedUsers,
+		`DELETE FROM workout_templates WHERE user_id IN ` + deletedUsers,
+		`DELETE FROM exercises WHERE created_by IN ` + deletedUsers,
+	} {
+		_, err = tx.ExecContext(ctx, query, arg)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE `+condition, arg)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, tx.Commit()
+}
+
+// schedules the deletion of the account at the given time, a nil time cancels a scheduled deletion
+func (pg *PostgresUserStore) ScheduleUserDeletion(ctx context.Context, userID int, at *time.Time) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	result, err := pg.db.ExecContext(ctx, `UPDATE users SET deletion_scheduled_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, at, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// deletes every account whose grace period ended before the given time, returns the number of deleted accounts
+func (pg *PostgresUserStore) DeleteScheduledUsers(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	return pg.deleteUsers(ctx, `deletion_scheduled_at <= $1`, before)
+}
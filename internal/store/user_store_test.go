@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zidariu-sabin/femProject/internal/tokens"
+)
+
+func TestSearchUsers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresUserStore(db, 0)
+
+	for _, user := range []*User{
+		{Username: "johnny_lifts", Email: "johnny@gmail.com", Bio: "powerlifting coach"},
+		{Username: "john_doe", Email: "john@gmail.com", Bio: "marathon runner"},
+		{Username: "jane", Email: "jane@gmail.com", Bio: "weekend powerlifter"},
+	} {
+		require.NoError(t, user.PasswordHash.Set("password123"))
+		require.NoError(t, store.CreateUser(context.Background(), user))
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		limit         int
+		wantUsernames []string
+	}{
+		{
+			name:          "username prefix",
+			query:         "john",
+			limit:         10,
+			wantUsernames: []string{"john_doe", "johnny_lifts"},
+		},
+		{
+			name:          "prefix of a word in the bio",
+			query:         "mara",
+			limit:         10,
+			wantUsernames: []string{"john_doe"},
+		},
+		{
+			name:          "wildcards are matched literally",
+			query:         "%",
+			limit:         10,
+			wantUsernames: []string{},
+		},
+		{
+			name:          "limit is applied",
+			query:         "john",
+			limit:         1,
+			wantUsernames: []string{"john_doe"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := store.SearchUsers(context.Background(), tt.query, tt.limit, 0)
+			require.NoError(t, err)
+
+			usernames := []string{}
+			for _, user := range users {
+				usernames = append(usernames, user.Username)
+			}
+
+			assert.Equal(t, tt.wantUsernames, usernames)
+		})
+	}
+
+	t.Run("fuzzy match on bio", func(t *testing.T) {
+		users, err := store.SearchUsers(context.Background(), "powerlifting", 10, 0)
+		require.NoError(t, err)
+		assert.NotEmpty(t, users)
+	})
+}
+
+func TestAccountDeletion(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresUserStore(db, 0)
+	ctx := context.Background()
+
+	users := []*User{
+		{Username: "leaving", Email: "leaving@gmail.com"},
+		{Username: "staying", Email: "staying@gmail.com"},
+		{Username: "changed_mind", Email: "changed@gmail.com"},
+	}
+	for _, user := range users {
+		require.NoError(t, user.PasswordHash.Set("password123"))
+		require.NoError(t, store.CreateUser(ctx, user))
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	//both users get their own "Zercher Carry", only the one of the leaving user is removed
+	workoutStore := NewPostgresWorkoutStore(db, 0, nil)
+	for _, user := range users[:2] {
+		_, err := workoutStore.CreateWorkout(ctx, &Workout{
+			UserID:          user.ID,
+			Title:           user.Username,
+			DurationMinutes: 30,
+			Entries:         []WorkoutEntry{{ExerciseName: "Zercher Carry", Sets: 3, Reps: IntPtr(5)}},
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, store.ScheduleUserDeletion(ctx, users[0].ID, &past))
+	require.NoError(t, store.ScheduleUserDeletion(ctx, users[1].ID, &future))
+	require.NoError(t, store.ScheduleUserDeletion(ctx, users[2].ID, &past))
+	require.NoError(t, store.ScheduleUserDeletion(ctx, users[2].ID, nil))
+
+	scheduled, err := store.GetUserByUsername(ctx, "staying")
+	require.NoError(t, err)
+	require.NotNil(t, scheduled.DeletionScheduledAt)
+	assert.True(t, future.Equal(*scheduled.DeletionScheduledAt))
+
+	deleted, err := store.DeleteScheduledUsers(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	for _, tt := range []struct {
+		username string
+		exists   bool
+	}{{"leaving", false}, {"staying", true}, {"changed_mind", true}} {
+		user, err := store.GetUserByUsername(ctx, tt.username)
+		require.NoError(t, err)
+		assert.Equal(t, tt.exists, user != nil, tt.username)
+	}
+
+	var creators []int
+	rows, err := db.QueryContext(ctx, `SELECT created_by FROM exercises WHERE name = 'Zercher Carry'`)
+	require.NoError(t, err)
+	for rows.Next() {
+		var creator int
+		require.NoError(t, rows.Scan(&creator))
+		creators = append(creators, creator)
+	}
+	require.NoError(t, rows.Err())
+	rows.Close()
+	assert.Equal(t, []int{users[1].ID}, creators)
+
+	require.NoError(t, store.DeleteUser(ctx, users[1].ID))
+	assert.ErrorIs(t, store.DeleteUser(ctx, users[1].ID), sql.ErrNoRows)
+}
+
+func TestActivateUser(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	store := NewPostgresUserStore(db, 0)
+	tokenStore := NewPostgresTokenStore(db, 0, nil)
+	ctx := context.Background()
+
+	user := &User{Username: "newcomer", Email: "newcomer@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("password123"))
+	require.NoError(t, store.CreateUser(ctx, user))
+	assert.False(t, user.Activated)
+
+	token, err := tokenStore.CreateNewToken(ctx, user.ID, time.Hour, tokens.ScopeActivation)
+	require.NoError(t, err)
+
+	owner, err := store.GetUserToken(ctx, tokens.ScopeActivation, token.PlainText)
+	require.NoError(t, err)
+	require.NotNil(t, owner)
+	assert.Equal(t, user.ID, owner.ID)
+
+	require.NoError(t, store.ActivateUser(ctx, user.ID))
+
+	activated, err := store.GetUserByUsername(ctx, "newcomer")
+	require.NoError(t, err)
+	assert.True(t, activated.Activated)
+
+	//the token cannot be used twice
+	owner, err = store.GetUserToken(ctx, tokens.ScopeActivation, token.PlainText)
+	require.NoError(t, err)
+	assert.Nil(t, owner)
+
+	assert.ErrorIs(t, store.ActivateUser(ctx, 0), sql.ErrNoRows)
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// kinds of workout entries, strength entries have reps, timed entries a duration
+// and cardio entries a distance, a duration or both
+const (
+	EntryStrength = "strength"
+	EntryTimed    = "timed"
+	EntryCardio   = "cardio"
+)
+
+var EntryTypes = []string{EntryStrength, EntryTimed, EntryCardio}
+
+// meters in one of each supported distance unit
+var distanceUnits = map[string]float64{
+	"m":  1,
+	"km": 1000,
+	"mi": 1609.344,
+}
+
+var DistanceUnits = []string{"m", "km", "mi"}
+
+// heart rates outside of this range are measurement errors
+const (
+	MinHeartRate = 30
+	MaxHeartRate = 250
+)
+
+// infers the type of an entry sent without one and checks that the entry only has the fields of its type,
+// the database enforces the same rules with the valid_workout_entry and valid_cardio_metrics constraints
+func ValidateEntry(entry *WorkoutEntry) error {
+	if entry.EntryType == "" {
+		entry.EntryType = inferEntryType(entry)
+	}
+
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("entry %d: %s", entry.OrderIndex, fmt.Sprintf(format, args...))
+	}
+
+	if entry.Sets < 1 {
+		return invalid("needs at least one set")
+	}
+
+	hasCardioMetrics := entry.Distance != nil || entry.DistanceUnit != nil || entry.AvgHeartRate != nil || entry.MaxHeartRate != nil || entry.ElevationGainMeters != nil
+
+	switch entry.EntryType {
+	case EntryStrength:
+		if entry.Reps == nil || entry.DurationSeconds != nil {
+			return invalid("strength entries need reps and no duration_seconds")
+		}
+	case EntryTimed:
+		if entry.DurationSeconds == nil || entry.Reps != nil {
+			return invalid("timed entries need duration_seconds and no reps")
+		}
+	case EntryCardio:
+		if entry.Reps != nil || entry.Weight != nil {
+			return invalid("cardio entries cannot have reps or weight")
+		}
+		if entry.Distance == nil && entry.DurationSeconds == nil {
+			return invalid("cardio entries need a distance or duration_seconds")
+		}
+	default:
+		return invalid("entry_type must be one of strength, timed, cardio")
+	}
+
+	if entry.EntryType != EntryCardio && hasCardioMetrics {
+		return invalid("only cardio entries can have a distance, heart rate or elevation gain")
+	}
+
+	if entry.Reps != nil && *entry.Reps < 1 {
+		return invalid("reps must be positive")
+	}
+
+	if entry.DurationSeconds != nil && *entry.DurationSeconds < 1 {
+		return invalid("duration_seconds must be positive")
+	}
+
+	if (entry.Distance == nil) != (entry.DistanceUnit == nil) {
+		return invalid("distance and distance_unit are set together")
+	}
+
+	if entry.Distance != nil {
+		if *entry.Distance <= 0 {
+			return invalid("distance must be positive")
+		}
+		if !slices.Contains(DistanceUnits, *entry.DistanceUnit) {
+			return invalid("distance_unit must be one of m, km, mi")
+		}
+	}
+
+	for _, heartRate := range []*int{entry.AvgHeartRate, entry.MaxHeartRate} {
+		if heartRate != nil && (*heartRate < MinHeartRate || *heartRate > MaxHeartRate) {
+			return invalid("heart rates must be between %d and %d", MinHeartRate, MaxHeartRate)
+		}
+	}
+
+	if entry.AvgHeartRate != nil && entry.MaxHeartRate != nil && *entry.AvgHeartRate > *entry.MaxHeartRate {
+		return invalid("avg_heart_rate cannot be above max_heart_rate")
+	}
+
+	if entry.ElevationGainMeters != nil && *entry.ElevationGainMeters < 0 {
+		return invalid("elevation_gain_meters cannot be negative")
+	}
+
+	return nil
+}
+
+// entries with cardio metrics are cardio entries, otherwise reps make a strength entry and a duration a timed one
+func inferEntryType(entry *WorkoutEntry) string {
+	switch {
+	case entry.Distance != nil || entry.AvgHeartRate != nil || entry.MaxHeartRate != nil || entry.ElevationGainMeters != nil:
+		return EntryCardio
+	case entry.Reps != nil:
+		return EntryStrength
+	case entry.DurationSeconds != nil:
+		return EntryTimed
+	}
+
+	return ""
+}
+
+// fills the pace and the speed of entries with both a distance and a duration
+func computeCardioMetrics(entries []WorkoutEntry) {
+	for i := range entries {
+		entry := &entries[i]
+		entry.PaceSecondsPerKm, entry.SpeedKmh = nil, nil
+
+		if entry.Distance == nil || entry.DistanceUnit == nil || entry.DurationSeconds == nil || *entry.DurationSeconds <= 0 {
+			continue
+		}
+
+		kilometers := *entry.Distance * distanceUnits[*entry.DistanceUnit] / 1000
+		if kilometers <= 0 {
+			continue
+		}
+
+		seconds := float64(*entry.DurationSeconds)
+		pace := math.Round(seconds/kilometers*10) / 10
+		speed := math.Round(kilometers/(seconds/3600)*100) / 100
+
+		entry.PaceSecondsPerKm, entry.SpeedKmh = &pace, &speed
+	}
+}
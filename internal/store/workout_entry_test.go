@@ -0,0 +1,120 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		entry    WorkoutEntry
+		wantType string
+		wantErr  string
+	}{
+		{
+			name:     "strength entry is inferred from reps",
+			entry:    WorkoutEntry{Sets: 3, Reps: IntPtr(5), Weight: FloatPtr(100), OrderIndex: 1},
+			wantType: EntryStrength,
+		},
+		{
+			name:     "timed entry is inferred from the duration",
+			entry:    WorkoutEntry{Sets: 3, DurationSeconds: IntPtr(60), OrderIndex: 1},
+			wantType: EntryTimed,
+		},
+		{
+			name:     "cardio entry is inferred from the distance",
+			entry:    WorkoutEntry{Sets: 1, Distance: FloatPtr(5), DistanceUnit: StringPtr("km"), DurationSeconds: IntPtr(1500), OrderIndex: 1},
+			wantType: EntryCardio,
+		},
+		{
+			name:     "cardio entry with only heart rate and duration",
+			entry:    WorkoutEntry{EntryType: EntryCardio, Sets: 1, DurationSeconds: IntPtr(1800), AvgHeartRate: IntPtr(130), MaxHeartRate: IntPtr(165), OrderIndex: 1},
+			wantType: EntryCardio,
+		},
+		{
+			name:    "no sets",
+			entry:   WorkoutEntry{Reps: IntPtr(5), OrderIndex: 2},
+			wantErr: "entry 2: needs at least one set",
+		},
+		{
+			name:    "reps and duration",
+			entry:   WorkoutEntry{Sets: 1, Reps: IntPtr(5), DurationSeconds: IntPtr(60), OrderIndex: 1},
+			wantErr: "entry 1: strength entries need reps and no duration_seconds",
+		},
+		{
+			name:    "nothing to infer the type from",
+			entry:   WorkoutEntry{Sets: 1, OrderIndex: 1},
+			wantErr: "entry 1: entry_type must be one of strength, timed, cardio",
+		},
+		{
+			name:    "heart rate on a strength entry",
+			entry:   WorkoutEntry{EntryType: EntryStrength, Sets: 1, Reps: IntPtr(5), AvgHeartRate: IntPtr(120), OrderIndex: 1},
+			wantErr: "entry 1: only cardio entries can have a distance, heart rate or elevation gain",
+		},
+		{
+			name:    "cardio entry with weight",
+			entry:   WorkoutEntry{Sets: 1, Distance: FloatPtr(5), DistanceUnit: StringPtr("km"), Weight: FloatPtr(20), OrderIndex: 1},
+			wantErr: "entry 1: cardio entries cannot have reps or weight",
+		},
+		{
+			name:    "distance without a unit",
+			entry:   WorkoutEntry{Sets: 1, Distance: FloatPtr(5), OrderIndex: 1},
+			wantErr: "entry 1: distance and distance_unit are set together",
+		},
+		{
+			name:    "unknown unit",
+			entry:   WorkoutEntry{Sets: 1, Distance: FloatPtr(5), DistanceUnit: StringPtr("yd"), OrderIndex: 1},
+			wantErr: "entry 1: distance_unit must be one of m, km, mi",
+		},
+		{
+			name:    "average above max heart rate",
+			entry:   WorkoutEntry{Sets: 1, DurationSeconds: IntPtr(600), AvgHeartRate: IntPtr(170), MaxHeartRate: IntPtr(160), OrderIndex: 1},
+			wantErr: "entry 1: avg_heart_rate cannot be above max_heart_rate",
+		},
+		{
+			name:    "impossible heart rate",
+			entry:   WorkoutEntry{Sets: 1, DurationSeconds: IntPtr(600), MaxHeartRate: IntPtr(320), OrderIndex: 1},
+			wantErr: "entry 1: heart rates must be between 30 and 250",
+		},
+		{
+			name:    "negative elevation gain",
+			entry:   WorkoutEntry{Sets: 1, Distance: FloatPtr(10), DistanceUnit: StringPtr("km"), ElevationGainMeters: FloatPtr(-5), OrderIndex: 1},
+			wantErr: "entry 1: elevation_gain_meters cannot be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEntry(&tt.entry)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantType, tt.entry.EntryType)
+		})
+	}
+}
+
+func TestComputeCardioMetrics(t *testing.T) {
+	entries := []WorkoutEntry{
+		{Distance: FloatPtr(5), DistanceUnit: StringPtr("km"), DurationSeconds: IntPtr(1500)},
+		{Distance: FloatPtr(1), DistanceUnit: StringPtr("mi"), DurationSeconds: IntPtr(480)},
+		{Distance: FloatPtr(400), DistanceUnit: StringPtr("m")},
+		{Reps: IntPtr(5)},
+	}
+
+	computeCardioMetrics(entries)
+
+	assert.Equal(t, 300.0, *entries[0].PaceSecondsPerKm)
+	assert.Equal(t, 12.0, *entries[0].SpeedKmh)
+	assert.Equal(t, 298.3, *entries[1].PaceSecondsPerKm)
+	assert.Equal(t, 12.07, *entries[1].SpeedKmh)
+	assert.Nil(t, entries[2].PaceSecondsPerKm)
+	assert.Nil(t, entries[3].SpeedKmh)
+}
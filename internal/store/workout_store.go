@@ -1,107 +1,211 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zidariu-sabin/femProject/internal/metrics"
 )
 
 // adding this `json:` tag is a go feature that will allow us to assign a struct a json stucture aswell
 type Workout struct {
-	ID              int            `json:"id"`
-	UserID          int            `json:"user_id"`
-	Title           string         `json:"title"`
-	Description     string         `json:"description"`
-	DurationMinutes int            `json:"duration_minutes"`
-	CaloriesBurned  int            `json:"calories_burned"`
-	Entries         []WorkoutEntry `json:"entries"`
+	ID              int    `json:"id"`
+	UserID          int    `json:"user_id"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	DurationMinutes int    `json:"duration_minutes"`
+	CaloriesBurned  int    `json:"calories_burned"`
+	//when the workout was or is planned to be performed, defaults to the time it is created
+	PerformedAt time.Time      `json:"performed_at"`
+	Status      string         `json:"status"`
+	Entries     []WorkoutEntry `json:"entries"`
+	//planned day of a program enrollment the workout completes, both are set or neither
+	EnrollmentID *int `json:"enrollment_id,omitempty"`
+	ProgramDayID *int `json:"program_day_id,omitempty"`
+	//records set by the workout, only filled when the workout is saved
+	PersonalRecords []PersonalRecord `json:"personal_records,omitempty"`
+	//points of an imported gps track, saved with the workout and read back with GetWorkoutRoute
+	Route []RoutePoint `json:"-"`
 }
 
 // reps, duration and weight are refferences because we want to check specifically if they are set to null exercise_mode comparison
+// an entry is sent with either an exercise id or a name which is resolved against the catalog
 type WorkoutEntry struct {
 	ID              int      `json:"id"`
+	ExerciseID      int64    `json:"exercise_id"`
 	ExerciseName    string   `json:"exercise_name"`
+	EntryType       string   `json:"entry_type"`
 	Sets            int      `json:"sets"`
 	Reps            *int     `json:"reps"`
 	DurationSeconds *int     `json:"duration_seconds"`
 	Weight          *float64 `json:"weight"`
 	Notes           string   `json:"notes"`
 	OrderIndex      int      `json:"order_index"`
+	//cardio metrics, the distance is kept in the unit it was entered in
+	Distance            *float64 `json:"distance,omitempty"`
+	DistanceUnit        *string  `json:"distance_unit,omitempty"`
+	AvgHeartRate        *int     `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate        *int     `json:"max_heart_rate,omitempty"`
+	ElevationGainMeters *float64 `json:"elevation_gain_meters,omitempty"`
+	//computed from the distance and the duration, they are not stored
+	PaceSecondsPerKm *float64 `json:"pace_seconds_per_km,omitempty"`
+	SpeedKmh         *float64 `json:"speed_kmh,omitempty"`
 }
 
 // Store used for postgres database operations
 type PostgresWorkoutStore struct {
-	db *sql.DB
+	db      *tracedDB
+	metrics *metrics.Metrics
 }
 
-// postgres workout store constructore
-func NewPostgresWorkoutStore(db *sql.DB) *PostgresWorkoutStore {
-	return &PostgresWorkoutStore{db: db}
+// postgres workout store constructore, metrics can be nil and a zero query timeout disables the timeout
+func NewPostgresWorkoutStore(db *sql.DB, queryTimeout time.Duration, m *metrics.Metrics) *PostgresWorkoutStore {
+	return &PostgresWorkoutStore{db: newTracedDB(db, "PostgresWorkoutStore", queryTimeout), metrics: m}
 }
 
 // We create an interface in order to separate database operations from store methods so the application store is not bound to postgress
 type WorkoutStore interface {
-	CreateWorkout(*Workout) (*Workout, error)
-	GetWorkoutByID(id int64) (*Workout, error)
-	UpdateWorkout(*Workout) error
-	DeleteWorkout(id int64) error
-	GetWorkoutOwner(workoutID int64) (int, error)
+	CreateWorkout(ctx context.Context, workout *Workout) (*Workout, error)
+	GetWorkoutByID(ctx context.Context, id int64) (*Workout, error)
+	UpdateWorkout(ctx context.Context, workout *Workout) error
+	DeleteWorkout(ctx context.Context, id int64) error
+	GetWorkoutOwner(ctx context.Context, workoutID int64) (int, error)
+	ListWorkouts(ctx context.Context, filter WorkoutFilter) ([]*Workout, string, error)
+	GetWorkoutRoute(ctx context.Context, workoutID int64) ([]RoutePoint, error)
+	ExportWorkouts(ctx context.Context, filter ExportFilter, fn func(*Workout) error) error
+	ImportWorkouts(ctx context.Context, workouts []*Workout) error
+}
+
+// filter used when listing the workouts of a user, nil pointers and empty strings are ignored
+type WorkoutFilter struct {
+	UserID      int
+	From        *time.Time
+	To          *time.Time
+	Title       string
+	Status      string
+	MinDuration *int
+	MaxDuration *int
+	MinCalories *int
+	MaxCalories *int
+	// one of the keys of workoutSortColumns, prefixed with "-" for descending order
+	Sort   string
+	Cursor string
+	Limit  int
+}
+
+const (
+	WorkoutPlanned   = "planned"
+	WorkoutCompleted = "completed"
+	WorkoutSkipped   = "skipped"
+)
+
+// only completed workouts count towards records, analytics and programs
+var WorkoutStatuses = []string{WorkoutPlanned, WorkoutCompleted, WorkoutSkipped}
+
+const (
+	DefaultWorkoutListLimit = 20
+	MaxWorkoutListLimit     = 100
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort field")
+)
+
+// sortable columns mapped to the sql expression used for ordering and the type used to cast the cursor value back
+var workoutSortColumns = map[string]struct {
+	expr     string
+	castType string
+}{
+	"created_at":       {"w.created_at", "timestamptz"},
+	"performed_at":     {"w.performed_at", "timestamptz"},
+	"title":            {"w.title", "text"},
+	"duration_minutes": {"w.duration_minutes", "integer"},
+	"calories_burned":  {"COALESCE(w.calories_burned, 0)", "integer"},
+}
+
+// the cursor holds the sort key and id of the last workout of a page so the next page can continue after it
+type workoutCursor struct {
+	SortKey string `json:"k"`
+	ID      int    `json:"id"`
+}
+
+func encodeWorkoutCursor(c workoutCursor) string {
+	js, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(js)
+}
+
+func decodeWorkoutCursor(s string) (workoutCursor, error) {
+	var c workoutCursor
+
+	js, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	err = json.Unmarshal(js, &c)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
 }
 
-func (pg *PostgresWorkoutStore) CreateWorkout(workout *Workout) (*Workout, error) {
+func (pg *PostgresWorkoutStore) CreateWorkout(ctx context.Context, workout *Workout) (*Workout, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
 	//transaction
-	tx, err := pg.db.Begin()
+	tx, err := pg.db.BeginTx(ctx, nil)
 
 	if err != nil {
 		return nil, err
 	}
 	//rollback is defered so it only proceeds at the end if there are caught errors within the transaction object
 	defer tx.Rollback()
-	query :=
-		`INSERT INTO  workouts(user_id, title, description, duration_minutes, calories_burned)
-	VALUES ($1,$2,$3,$4,$5)
-	RETURNING id
-	`
-
-	err = tx.QueryRow(query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned).Scan(&workout.ID)
 
+	err = insertWorkout(ctx, tx, workout)
 	if err != nil {
 		return nil, err
 	}
 
-	//inserting entries
-	for i, entry := range workout.Entries {
-		query := `INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index ) 
-		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id
-		`
-
-		err = tx.QueryRow(query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&workout.Entries[i].ID)
-
-		if err != nil {
-			return nil, err
-		}
-
+	records, err := rebuildPersonalRecords(ctx, tx, workout.UserID, entryExerciseIDs(workout.Entries))
+	if err != nil {
+		return nil, err
 	}
 
+	workout.PersonalRecords = workoutRecords(records, workout)
+
 	err = tx.Commit()
 
 	if err != nil {
 		return nil, err
 	}
 
+	pg.metrics.WorkoutCreated()
+
 	return workout, nil
 
 }
 
-func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
+func (pg *PostgresWorkoutStore) GetWorkoutByID(ctx context.Context, id int64) (*Workout, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
 
 	workout := &Workout{}
 
-	queryWorkout := `SELECT id, title, description, duration_minutes, calories_burned 
+	queryWorkout := `SELECT id, user_id, title, description, duration_minutes, calories_burned, performed_at, status, enrollment_id, program_day_id 
 	FROM workouts 
 	WHERE id = $1`
 
-	err := pg.db.QueryRow(queryWorkout, id).Scan(&workout.ID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned)
+	err := pg.db.QueryRowContext(ctx, queryWorkout, id).Scan(&workout.ID, &workout.UserID, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned,
+		&workout.PerformedAt, &workout.Status, &workout.EnrollmentID, &workout.ProgramDayID)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -111,13 +215,15 @@ func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 		return nil, err
 	}
 
-	queryEntries := `SELECT id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index 
-	FROM workout_entries 
-	WHERE workout_id = $1
-	ORDER BY order_index
+	queryEntries := `SELECT we.id, we.exercise_id, e.name, we.entry_type, we.sets, we.reps, we.duration_seconds, we.weight, we.notes, we.order_index,
+		we.distance, we.distance_unit, we.avg_heart_rate, we.max_heart_rate, we.elevation_gain_meters
+	FROM workout_entries we
+	INNER JOIN exercises e ON e.id = we.exercise_id
+	WHERE we.workout_id = $1
+	ORDER BY we.order_index
 	`
 
-	rows, err := pg.db.Query(queryEntries, id)
+	rows, err := pg.db.QueryContext(ctx, queryEntries, id)
 	if err != nil {
 		return nil, err
 	}
@@ -127,13 +233,20 @@ func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 	for rows.Next() {
 		var workout_entry WorkoutEntry
 		err := rows.Scan(&workout_entry.ID,
+			&workout_entry.ExerciseID,
 			&workout_entry.ExerciseName,
+			&workout_entry.EntryType,
 			&workout_entry.Sets,
 			&workout_entry.Reps,
 			&workout_entry.DurationSeconds,
 			&workout_entry.Weight,
 			&workout_entry.Notes,
-			&workout_entry.OrderIndex)
+			&workout_entry.OrderIndex,
+			&workout_entry.Distance,
+			&workout_entry.DistanceUnit,
+			&workout_entry.AvgHeartRate,
+			&workout_entry.MaxHeartRate,
+			&workout_entry.ElevationGainMeters)
 		if err != nil {
 			return nil, err
 		}
@@ -144,26 +257,30 @@ func (pg *PostgresWorkoutStore) GetWorkoutByID(id int64) (*Workout, error) {
 		return nil, err
 	}
 
+	computeCardioMetrics(workout.Entries)
+
 	return workout, nil
 
 }
 
 // also used to delete/ update a workout entry
-func (pg *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
-	tx, err := pg.db.Begin()
+func (pg *PostgresWorkoutStore) UpdateWorkout(ctx context.Context, workout *Workout) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pg.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
 	defer tx.Rollback()
-	fmt.Println("Updated workout with ID:", workout.ID)
 
 	query := `
 	UPDATE workouts
-	SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4
-	WHERE id = $5
+	SET title = $1, description = $2, duration_minutes = $3, calories_burned = $4, performed_at = $5, status = $6
+	WHERE id = $7
 	`
-	result, err := tx.Exec(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.ID)
+	result, err := tx.ExecContext(ctx, query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.PerformedAt, workout.Status, workout.ID)
 
 	if err != nil {
 		return err
@@ -178,59 +295,109 @@ func (pg *PostgresWorkoutStore) UpdateWorkout(workout *Workout) error {
 	if rowsAffected == 0 {
 		return sql.ErrNoRows
 	}
+	//the records of the exercises that are removed from the workout are rebuilt as well
+	previousEntries, err := workoutEntryExercises(ctx, tx, workout.ID)
+	if err != nil {
+		return err
+	}
+
 	// we delete the existing entries for the workout to do a patch update to ease the data required for the client
-	_, err = tx.Exec(`DELETE FROM workout_entries WHERE workout_id = $1`, workout.ID)
+	_, err = tx.ExecContext(ctx, `DELETE FROM workout_entries WHERE workout_id = $1`, workout.ID)
 
 	if err != nil {
 		return err
 	}
 
-	for _, entry := range workout.Entries {
-		query := `
-		INSERT INTO workout_entries (workout_id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index ) 
-		VALUES($1, $2, $3, $4, $5, $6, $7, $8)
-		`
+	err = insertWorkoutEntries(ctx, tx, workout)
+	if err != nil {
+		return err
+	}
+
+	records, err := rebuildPersonalRecords(ctx, tx, workout.UserID, entryExerciseIDs(append(previousEntries, workout.Entries...)))
+	if err != nil {
+		return err
+	}
+
+	workout.PersonalRecords = workoutRecords(records, workout)
 
-		_, err := tx.Exec(query, workout.ID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex)
+	return tx.Commit()
+}
+
+// entries of a saved workout holding only their exercise id
+func workoutEntryExercises(ctx context.Context, db queryExecer, workoutID int) ([]WorkoutEntry, error) {
+	rows, err := db.QueryContext(ctx, `SELECT exercise_id FROM workout_entries WHERE workout_id = $1`, workoutID)
+	if err != nil {
+		return nil, err
+	}
 
+	defer rows.Close()
+
+	entries := []WorkoutEntry{}
+
+	for rows.Next() {
+		var entry WorkoutEntry
+
+		err := rows.Scan(&entry.ExerciseID)
 		if err != nil {
-			return err
+			return nil, err
 		}
+
+		entries = append(entries, entry)
 	}
 
-	return tx.Commit()
+	return entries, rows.Err()
 }
 
-func (pg *PostgresWorkoutStore) DeleteWorkout(id int64) error {
-	query := `DELETE FROM workouts WHERE id = $1`
+func (pg *PostgresWorkoutStore) DeleteWorkout(ctx context.Context, id int64) error {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := pg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
 
-	result, err := pg.db.Exec(query, id)
+	var userID int
+	err = tx.QueryRowContext(ctx, `SELECT user_id FROM workouts WHERE id = $1`, id).Scan(&userID)
 
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	entries, err := workoutEntryExercises(ctx, tx, int(id))
+	if err != nil {
+		return err
+	}
+
+	//entries and records of the workout are deleted by the cascade
+	_, err = tx.ExecContext(ctx, `DELETE FROM workouts WHERE id = $1`, id)
 
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	//later records may only have been set because the deleted workout was not better
+	_, err = rebuildPersonalRecords(ctx, tx, userID, entryExerciseIDs(entries))
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return tx.Commit()
 }
 
-func (pg *PostgresWorkoutStore) GetWorkoutOwner(workoutID int64) (int, error) {
+func (pg *PostgresWorkoutStore) GetWorkoutOwner(ctx context.Context, workoutID int64) (int, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
 	var userID int
 
 	query := `
 	SELECT user_id
 	FROM workouts where id = $1`
 
-	err := pg.db.QueryRow(query, workoutID).Scan(&userID)
+	err := pg.db.QueryRowContext(ctx, query, workoutID).Scan(&userID)
 
 	if err != nil {
 		return 0, err
@@ -238,3 +405,270 @@ func (pg *PostgresWorkoutStore) GetWorkoutOwner(workoutID int64) (int, error) {
 
 	return userID, nil
 }
+
+// lists the workouts of a user together with their entries in a single query using keyset pagination
+// the returned cursor is empty when there are no more pages
+func (pg *PostgresWorkoutStore) ListWorkouts(ctx context.Context, filter WorkoutFilter) ([]*Workout, string, error) {
+	ctx, cancel := pg.db.withTimeout(ctx)
+	defer cancel()
+
+	sortField := filter.Sort
+	if sortField == "" {
+		sortField = "-performed_at"
+	}
+
+	descending := strings.HasPrefix(sortField, "-")
+	column, ok := workoutSortColumns[strings.TrimPrefix(sortField, "-")]
+	if !ok {
+		return nil, "", ErrInvalidSort
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultWorkoutListLimit
+	}
+	if limit > MaxWorkoutListLimit {
+		limit = MaxWorkoutListLimit
+	}
+
+	conditions := []string{"w.user_id = $1"}
+	args := []interface{}{filter.UserID}
+
+	//appends a condition whose "?" placeholder is replaced by the next positional argument
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1))
+	}
+
+	if filter.From != nil {
+		addCondition("w.performed_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("w.performed_at < ?", *filter.To)
+	}
+	if filter.Status != "" {
+		addCondition("w.status = ?", filter.Status)
+	}
+	if filter.Title != "" {
+		addCondition("w.title ILIKE '%' || ? || '%'", filter.Title)
+	}
+	if filter.MinDuration != nil {
+		addCondition("w.duration_minutes >= ?", *filter.MinDuration)
+	}
+	if filter.MaxDuration != nil {
+		addCondition("w.duration_minutes <= ?", *filter.MaxDuration)
+	}
+	if filter.MinCalories != nil {
+		addCondition("w.calories_burned >= ?", *filter.MinCalories)
+	}
+	if filter.MaxCalories != nil {
+		addCondition("w.calories_burned <= ?", *filter.MaxCalories)
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeWorkoutCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		args = append(args, cursor.SortKey, cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, w.id) %s ($%d::%s, $%d)",
+			column.expr, comparison, len(args)-1, column.castType, len(args)))
+	}
+
+	//we fetch one extra row to know if there is a next page
+	args = append(args, limit+1)
+
+	//entries are aggregated as json in a subquery to avoid querying them for every workout
+	query := fmt.Sprintf(`
+	SELECT w.id, w.user_id, w.title, w.description, w.duration_minutes, w.calories_burned, w.performed_at, w.status, (%[1]s)::text,
+		COALESCE((
+			SELECT json_agg(json_build_object(
+				'id', e.id,
+				'exercise_id', e.exercise_id,
+				'exercise_name', x.name,
+				'entry_type', e.entry_type,
+				'sets', e.sets,
+				'reps', e.reps,
+				'duration_seconds', e.duration_seconds,
+				'weight', e.weight,
+				'notes', e.notes,
+				'order_index', e.order_index,
+				'distance', e.distance,
+				'distance_unit', e.distance_unit,
+				'avg_heart_rate', e.avg_heart_rate,
+				'max_heart_rate', e.max_heart_rate,
+				'elevation_gain_meters', e.elevation_gain_meters
+			) ORDER BY e.order_index)
+			FROM workout_entries e
+			INNER JOIN exercises x ON x.id = e.exercise_id
+			WHERE e.workout_id = w.id
+		), '[]')
+	FROM workouts w
+	WHERE %[2]s
+	ORDER BY %[1]s %[3]s, w.id %[3]s
+	LIMIT $%[4]d
+	`, column.expr, strings.Join(conditions, " AND "), direction, len(args))
+
+	rows, err := pg.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer rows.Close()
+
+	workouts := []*Workout{}
+	sortKeys := []string{}
+
+	for rows.Next() {
+		workout := &Workout{}
+		var sortKey string
+		var entries []byte
+
+		err := rows.Scan(&workout.ID,
+			&workout.UserID,
+			&workout.Title,
+			&workout.Description,
+			&workout.DurationMinutes,
+			&workout.CaloriesBurned,
+			&workout.PerformedAt,
+			&workout.Status,
+			&sortKey,
+			&entries)
+		if err != nil {
+			return nil, "", err
+		}
+
+		err = json.Unmarshal(entries, &workout.Entries)
+		if err != nil {
+			return nil, "", err
+		}
+
+		computeCardioMetrics(workout.Entries)
+
+		workouts = append(workouts, workout)
+		sortKeys = append(sortKeys, sortKey)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(workouts) > limit {
+		workouts = workouts[:limit]
+		nextCursor = encodeWorkoutCursor(workoutCursor{SortKey: sortKeys[limit-1], ID: workouts[limit-1].ID})
+	}
+
+	return workouts, nextCursor, nil
+}
+
+// the enrollment has to belong to the owner of the workout and the day to the program of the enrollment
+func checkProgramDay(ctx context.Context, tx *tracedTx, workout *Workout) error {
+	if workout.EnrollmentID == nil && workout.ProgramDayID == nil {
+		return nil
+	}
+
+	if workout.EnrollmentID == nil || workout.ProgramDayID == nil {
+		return ErrInvalidProgramDay
+	}
+
+	query := `
+	SELECT EXISTS (
+		SELECT 1
+		FROM program_enrollments pe
+		INNER JOIN program_days pd ON pd.program_id = pe.program_id
+		WHERE pe.id = $1 AND pe.user_id = $2 AND pd.id = $3
+	)
+	`
+
+	var valid bool
+	err := tx.QueryRowContext(ctx, query, *workout.EnrollmentID, workout.UserID, *workout.ProgramDayID).Scan(&valid)
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		return ErrInvalidProgramDay
+	}
+
+	return nil
+}
+
+// inserts a workout with its entries and route, the personal records are rebuilt by the caller
+func insertWorkout(ctx context.Context, tx *tracedTx, workout *Workout) error {
+	err := checkProgramDay(ctx, tx, workout)
+	if err != nil {
+		return err
+	}
+
+	if workout.Status == "" {
+		workout.Status = WorkoutCompleted
+	}
+
+	query :=
+		`INSERT INTO  workouts(user_id, title, description, duration_minutes, calories_burned, enrollment_id, program_day_id, performed_at, status)
+	VALUES ($1,$2,$3,$4,$5,$6,$7,COALESCE($8, CURRENT_TIMESTAMP),$9)
+	RETURNING id, performed_at
+	`
+
+	err = tx.QueryRowContext(ctx, query, workout.UserID, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned,
+		workout.EnrollmentID, workout.ProgramDayID, nullTime(workout.PerformedAt), workout.Status).Scan(&workout.ID, &workout.PerformedAt)
+	if err != nil {
+		return err
+	}
+
+	err = insertWorkoutEntries(ctx, tx, workout)
+	if err != nil {
+		return err
+	}
+
+	return insertRoutePoints(ctx, tx, workout)
+}
+
+// a zero time is stored as null so the column default applies
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// resolves the exercises of the entries against the catalog and inserts them, entries sent without a type get the inferred one
+func insertWorkoutEntries(ctx context.Context, tx *tracedTx, workout *Workout) error {
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+
+		err := resolveExercise(ctx, tx, entry, workout.UserID)
+		if err != nil {
+			return err
+		}
+
+		if entry.EntryType == "" {
+			entry.EntryType = inferEntryType(entry)
+		}
+
+		query := `
+		INSERT INTO workout_entries (workout_id, exercise_id, entry_type, sets, reps, duration_seconds, weight, notes, order_index,
+			distance, distance_unit, avg_heart_rate, max_heart_rate, elevation_gain_meters)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+		`
+
+		err = tx.QueryRowContext(ctx, query, workout.ID, entry.ExerciseID, entry.EntryType, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex,
+			entry.Distance, entry.DistanceUnit, entry.AvgHeartRate, entry.MaxHeartRate, entry.ElevationGainMeters).Scan(&entry.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	computeCardioMetrics(workout.Entries)
+
+	return nil
+}
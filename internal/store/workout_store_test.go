@@ -1,17 +1,24 @@
 package store
 
 import (
+	"context"
 	"database/sql"
+	"os"
 	"testing"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"github.com/zidariu-sabin/femProject/internal/utils"
 )
 
+// the dsn is read from the environment, the config package cannot be imported here because it imports the store
 func setupTestDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("pgx", utils.TEST_DATABASE_PGS_CONN_STRING)
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
 	if err != nil {
 		t.Fatalf("opening test db error: %v", err)
 	}
@@ -22,12 +29,23 @@ func setupTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("migration test db error: %v", err)
 	}
 
-	_, err = db.Exec(`TRUNCATE users, workouts, workout_entries CASCADE`)
+	_, err = db.Exec(`TRUNCATE workouts, workout_entries, workout_templates, programs CASCADE`)
 
 	if err != nil {
 		t.Fatalf("truncating tables error: %v", err)
 	}
 
+	//users are deleted instead of truncated so the cascade does not empty the default exercise catalog
+	_, err = db.Exec(`DELETE FROM exercises WHERE created_by IS NOT NULL`)
+	if err != nil {
+		t.Fatalf("deleting exercises error: %v", err)
+	}
+
+	_, err = db.Exec(`DELETE FROM users`)
+	if err != nil {
+		t.Fatalf("deleting users error: %v", err)
+	}
+
 	return db
 }
 
@@ -36,8 +54,8 @@ func TestCreateWorkout(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
 
-	userStore := NewPostgresUserStore(db)
-	store := NewPostgresWorkoutStore(db)
+	userStore := NewPostgresUserStore(db, 0)
+	store := NewPostgresWorkoutStore(db, 0, nil)
 	//
 	//TODO: add user ids
 	//
@@ -72,6 +90,30 @@ func TestCreateWorkout(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "cardio workout",
+			user: &User{
+				Username:     "User3",
+				PasswordHash: password{plainText: StringPtr("User3Password")},
+				Email:        "User3@gmail.com",
+			},
+			workout: &Workout{
+				Title:           "easy run",
+				DurationMinutes: 30,
+				Entries: []WorkoutEntry{
+					{
+						ExerciseName:    "run",
+						Sets:            1,
+						DurationSeconds: IntPtr(1500),
+						Distance:        FloatPtr(5),
+						DistanceUnit:    StringPtr("km"),
+						AvgHeartRate:    IntPtr(148),
+						OrderIndex:      1,
+					},
+				},
+			},
+			wantErr: false,
+		},
 		{
 			name: "workout with invalid entries",
 			user: &User{
@@ -114,13 +156,13 @@ func TestCreateWorkout(t *testing.T) {
 
 			require.NoError(t, err)
 
-			err = userStore.CreateUser(tt.user)
+			err = userStore.CreateUser(context.Background(), tt.user)
 
 			require.NoError(t, err)
 
 			tt.workout.UserID = tt.user.ID
 
-			createdWorkout, err := store.CreateWorkout(tt.workout)
+			createdWorkout, err := store.CreateWorkout(context.Background(), tt.workout)
 
 			if tt.wantErr {
 				assert.Error(t, err)
@@ -133,7 +175,7 @@ func TestCreateWorkout(t *testing.T) {
 			assert.Equal(t, tt.workout.DurationMinutes, createdWorkout.DurationMinutes)
 
 			//ensuring data stored in the database is retrieved correctly
-			retrieved, err := store.GetWorkoutByID(int64(createdWorkout.ID))
+			retrieved, err := store.GetWorkoutByID(context.Background(), int64(createdWorkout.ID))
 			require.NoError(t, err)
 
 			assert.Equal(t, createdWorkout.ID, retrieved.ID)
@@ -143,6 +185,8 @@ func TestCreateWorkout(t *testing.T) {
 				assert.Equal(t, tt.workout.Entries[i].ExerciseName, retrieved.Entries[i].ExerciseName)
 				assert.Equal(t, tt.workout.Entries[i].Sets, retrieved.Entries[i].Sets)
 				assert.Equal(t, tt.workout.Entries[i].OrderIndex, retrieved.Entries[i].OrderIndex)
+				assert.Equal(t, tt.workout.Entries[i].Distance, retrieved.Entries[i].Distance)
+				assert.Equal(t, tt.workout.Entries[i].PaceSecondsPerKm, retrieved.Entries[i].PaceSecondsPerKm)
 			}
 		})
 	}
@@ -157,3 +201,97 @@ func FloatPtr(f float64) *float64 {
 func StringPtr(s string) *string {
 	return &s
 }
+
+func TestListWorkouts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	userStore := NewPostgresUserStore(db, 0)
+	store := NewPostgresWorkoutStore(db, 0, nil)
+
+	user := &User{Username: "lister", Email: "lister@gmail.com"}
+	require.NoError(t, user.PasswordHash.Set("listerPassword"))
+	require.NoError(t, userStore.CreateUser(context.Background(), user))
+
+	for i, title := range []string{"push day", "pull day", "leg day"} {
+		_, err := store.CreateWorkout(context.Background(), &Workout{
+			UserID:          user.ID,
+			Title:           title,
+			DurationMinutes: 30 * (i + 1),
+			CaloriesBurned:  100 * (i + 1),
+			Entries: []WorkoutEntry{
+				{ExerciseName: "Squats", Sets: 3, Reps: IntPtr(10), OrderIndex: 1},
+				{ExerciseName: "Plank", Sets: 1, DurationSeconds: IntPtr(60), OrderIndex: 2},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name       string
+		filter     WorkoutFilter
+		wantTitles []string
+		wantErr    error
+	}{
+		{
+			name:       "sorted by duration",
+			filter:     WorkoutFilter{UserID: user.ID, Sort: "duration_minutes"},
+			wantTitles: []string{"push day", "pull day", "leg day"},
+		},
+		{
+			name:       "title substring",
+			filter:     WorkoutFilter{UserID: user.ID, Title: "PU", Sort: "-calories_burned"},
+			wantTitles: []string{"pull day", "push day"},
+		},
+		{
+			name:       "duration range",
+			filter:     WorkoutFilter{UserID: user.ID, MinDuration: IntPtr(45), MaxDuration: IntPtr(70)},
+			wantTitles: []string{"pull day"},
+		},
+		{
+			name:    "invalid sort",
+			filter:  WorkoutFilter{UserID: user.ID, Sort: "password"},
+			wantErr: ErrInvalidSort,
+		},
+		{
+			name:    "invalid cursor",
+			filter:  WorkoutFilter{UserID: user.ID, Cursor: "not a cursor"},
+			wantErr: ErrInvalidCursor,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workouts, _, err := store.ListWorkouts(context.Background(), tt.filter)
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, len(tt.wantTitles), len(workouts))
+
+			for i := range workouts {
+				assert.Equal(t, tt.wantTitles[i], workouts[i].Title)
+				assert.Len(t, workouts[i].Entries, 2)
+			}
+		})
+	}
+
+	t.Run("paginates with cursor", func(t *testing.T) {
+		filter := WorkoutFilter{UserID: user.ID, Sort: "title", Limit: 2}
+
+		firstPage, cursor, err := store.ListWorkouts(context.Background(), filter)
+		require.NoError(t, err)
+		require.Len(t, firstPage, 2)
+		require.NotEmpty(t, cursor)
+
+		filter.Cursor = cursor
+		secondPage, cursor, err := store.ListWorkouts(context.Background(), filter)
+		require.NoError(t, err)
+		require.Len(t, secondPage, 1)
+		assert.Empty(t, cursor)
+		assert.Equal(t, "push day", secondPage[0].Title)
+	})
+}
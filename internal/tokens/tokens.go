@@ -4,11 +4,30 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
+	"encoding/hex"
 	"time"
 )
 
 const (
-	ScopeAuth = "authentification"
+	ScopeAuth    = "authentification"
+	ScopeRefresh = "refresh"
+	//secret of the calendar feed url, it only grants reading the feed
+	ScopeCalendar = "calendar"
+	//emailed to a user who forgot the password, it only allows setting a new password once
+	ScopePasswordReset = "password-reset"
+	//emailed at registration, it verifies the email and activates the account
+	ScopeActivation = "activation"
+)
+
+const (
+	// access tokens are short lived, the client uses the refresh token to get a new pair
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+	// calendar apps keep polling a subscribed feed so its secret lives until it is rotated
+	CalendarFeedTTL = 10 * 365 * 24 * time.Hour
+	// a reset link should be used right after it is requested
+	PasswordResetTTL = 45 * time.Minute
+	ActivationTTL    = 3 * 24 * time.Hour
 )
 
 type Token struct {
@@ -18,13 +37,73 @@ type Token struct {
 	UserID int       `json:"-"`
 	Expiry time.Time `json:"expiry"`
 	Scope  string    `json:"-"`
+	//tokens issued from the same login share a family so they can be revoked together
+	Family string `json:"-"`
+}
+
+// access and refresh token issued together
+type TokenPair struct {
+	Access  *Token `json:"auth_token"`
+	Refresh *Token `json:"refresh_token"`
 }
 
 func GenerateToken(userID int, ttl time.Duration, scope string) (*Token, error) {
+	family, err := NewFamily()
+	if err != nil {
+		return nil, err
+	}
+
+	return generateToken(userID, ttl, scope, family)
+}
+
+// generates an access and refresh token belonging to the given family, an empty family starts a new one
+func GenerateTokenPair(userID int, accessTTL, refreshTTL time.Duration, family string) (*TokenPair, error) {
+	var err error
+
+	if family == "" {
+		family, err = NewFamily()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	access, err := generateToken(userID, accessTTL, ScopeAuth, family)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := generateToken(userID, refreshTTL, ScopeRefresh, family)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{Access: access, Refresh: refresh}, nil
+}
+
+// random identifier of a token family
+func NewFamily() (string, error) {
+	familyBytes := make([]byte, 16)
+
+	_, err := rand.Read(familyBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(familyBytes), nil
+}
+
+// hashes the plain text of a token the same way it is stored in the database
+func Hash(plainText string) []byte {
+	hash := sha256.Sum256([]byte(plainText))
+	return hash[:]
+}
+
+func generateToken(userID int, ttl time.Duration, scope string, family string) (*Token, error) {
 	token := &Token{
 		UserID: userID,
 		Expiry: time.Now().Add(ttl),
 		Scope:  scope,
+		Family: family,
 	}
 	//creates an empty array of bytes
 	emptyBytes := make([]byte, 32)
@@ -41,9 +120,7 @@ func GenerateToken(userID int, ttl time.Duration, scope string) (*Token, error)
 	token.PlainText = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(emptyBytes)
 
 	//using the standard documented crypting lbrary of go to avoid creating a hashing algoritm ourselves
-	hash := sha256.Sum256([]byte(token.PlainText))
-
-	token.Hash = hash[:]
+	token.Hash = Hash(token.PlainText)
 
 	return token, nil
 }
@@ -0,0 +1,22 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetup(t *testing.T) {
+	for _, exporter := range []string{ExporterNone, ExporterStdout} {
+		t.Run(exporter, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), exporter, "", "test")
+			require.NoError(t, err)
+			assert.NoError(t, shutdown(context.Background()))
+		})
+	}
+
+	_, err := Setup(context.Background(), "zipkin", "", "test")
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package tracks
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// elements of a gpx 1.1 file, the heart rate comes from the garmin track point extension
+type gpxFile struct {
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []struct {
+				Latitude  *float64   `xml:"lat,attr"`
+				Longitude *float64   `xml:"lon,attr"`
+				Elevation *float64   `xml:"ele"`
+				Time      *time.Time `xml:"time"`
+				HeartRate *int       `xml:"extensions>TrackPointExtension>hr"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// the segments of every track are joined into a single track, the first point of each segment is marked
+func parseGPX(data []byte) (*Track, error) {
+	var file gpxFile
+
+	err := xml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+
+	track := &Track{Sport: SportOther}
+
+	for i, trk := range file.Tracks {
+		if i == 0 {
+			track.Name = trk.Name
+			track.Sport = normalizeSport(trk.Type)
+		}
+
+		for _, segment := range trk.Segments {
+			for j, trkpt := range segment.Points {
+				err = validPosition(trkpt.Latitude, trkpt.Longitude)
+				if err != nil {
+					return nil, err
+				}
+
+				track.Points = append(track.Points, Point{
+					Latitude:     trkpt.Latitude,
+					Longitude:    trkpt.Longitude,
+					Elevation:    trkpt.Elevation,
+					Time:         trkpt.Time,
+					HeartRate:    trkpt.HeartRate,
+					SegmentStart: j == 0,
+				})
+			}
+		}
+	}
+
+	return track, nil
+}
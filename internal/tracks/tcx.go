@@ -0,0 +1,71 @@
+package tracks
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// elements of a garmin training center file
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Notes string `xml:"Notes"`
+		Laps  []struct {
+			Points []struct {
+				Time     *time.Time `xml:"Time"`
+				Position *struct {
+					Latitude  *float64 `xml:"LatitudeDegrees"`
+					Longitude *float64 `xml:"LongitudeDegrees"`
+				} `xml:"Position"`
+				Altitude  *float64 `xml:"AltitudeMeters"`
+				Distance  *float64 `xml:"DistanceMeters"`
+				HeartRate *int     `xml:"HeartRateBpm>Value"`
+			} `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+// only the first activity of the file is imported
+func parseTCX(data []byte) (*Track, error) {
+	var file tcxFile
+
+	err := xml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, err
+	}
+
+	track := &Track{Sport: SportOther}
+
+	if len(file.Activities) == 0 {
+		return track, nil
+	}
+
+	activity := file.Activities[0]
+	track.Name = activity.Notes
+	track.Sport = normalizeSport(activity.Sport)
+
+	for _, lap := range activity.Laps {
+		for _, trackpoint := range lap.Points {
+			point := Point{
+				Elevation: trackpoint.Altitude,
+				Time:      trackpoint.Time,
+				HeartRate: trackpoint.HeartRate,
+				Distance:  trackpoint.Distance,
+			}
+
+			//a point without a position is kept, a position with a missing or invalid coordinate is not
+			if trackpoint.Position != nil {
+				err = validPosition(trackpoint.Position.Latitude, trackpoint.Position.Longitude)
+				if err != nil {
+					return nil, err
+				}
+
+				point.Latitude, point.Longitude = trackpoint.Position.Latitude, trackpoint.Position.Longitude
+			}
+
+			track.Points = append(track.Points, point)
+		}
+	}
+
+	return track, nil
+}
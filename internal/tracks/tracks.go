@@ -0,0 +1,224 @@
+package tracks
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// sports a track can be recorded for, other sports are reported as SportOther
+const (
+	SportRunning = "running"
+	SportCycling = "cycling"
+	SportOther   = "other"
+)
+
+var (
+	ErrUnknownFormat = errors.New("the file is neither a gpx nor a tcx file")
+	ErrNoPoints      = errors.New("the file has no track points")
+	ErrInvalidPoint  = errors.New("a track point has a missing or out of range latitude or longitude")
+)
+
+// a recorded track, the points are in the order they were recorded
+type Track struct {
+	Name   string
+	Sport  string
+	Points []Point
+}
+
+// a track point, tcx files can have points without a position, for example on a treadmill
+type Point struct {
+	Latitude  *float64
+	Longitude *float64
+	Elevation *float64
+	Time      *time.Time
+	HeartRate *int
+	// distance from the start recorded by the device, only tcx files have it
+	Distance *float64
+	// first point of a gpx track segment, the device stopped recording between it and the previous point
+	SegmentStart bool
+}
+
+func (p Point) HasPosition() bool {
+	return p.Latitude != nil && p.Longitude != nil
+}
+
+// checks the coordinates of a track point, both have to be present and in range
+func validPosition(latitude, longitude *float64) error {
+	if latitude == nil || longitude == nil {
+		return ErrInvalidPoint
+	}
+
+	if math.Abs(*latitude) > 90 || math.Abs(*longitude) > 180 || math.IsNaN(*latitude) || math.IsNaN(*longitude) {
+		return ErrInvalidPoint
+	}
+
+	return nil
+}
+
+// totals of a track, the heart rates are nil when the track has none
+type Summary struct {
+	StartTime           *time.Time
+	DistanceMeters      float64
+	DurationSeconds     int
+	ElevationGainMeters float64
+	AvgHeartRate        *int
+	MaxHeartRate        *int
+}
+
+// heart rates outside of this range are treated as dropouts of the sensor
+const (
+	minHeartRate = 30
+	maxHeartRate = 250
+)
+
+// parses a gpx or a tcx file, the format is detected from the root element
+func Parse(r io.Reader) (*Track, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var track *Track
+
+	switch root {
+	case "gpx":
+		track, err = parseGPX(data)
+	case "TrainingCenterDatabase":
+		track, err = parseTCX(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(track.Points) == 0 {
+		return nil, ErrNoPoints
+	}
+
+	return track, nil
+}
+
+func rootElement(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", ErrUnknownFormat
+		}
+
+		if err != nil {
+			return "", ErrUnknownFormat
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// maps the sport names used by devices and apps to the sports of a track
+func normalizeSport(sport string) string {
+	switch strings.ToLower(strings.TrimSpace(sport)) {
+	case "running", "run", "trail_running", "treadmill_running", "9":
+		return SportRunning
+	case "biking", "cycling", "ride", "road_biking", "mountain_biking", "1":
+		return SportCycling
+	}
+
+	return SportOther
+}
+
+// sums the distance, elevation gain and heart rates of the track, the device distance is used when the file has one
+func (t *Track) Summary() Summary {
+	summary := Summary{}
+
+	var first, last *time.Time
+	var previous *Point
+	heartRateSum, heartRateCount, heartRateMax := 0, 0, 0
+	deviceDistance := 0.0
+
+	for i := range t.Points {
+		point := &t.Points[i]
+
+		if point.Time != nil {
+			if first == nil || point.Time.Before(*first) {
+				first = point.Time
+			}
+			if last == nil || point.Time.After(*last) {
+				last = point.Time
+			}
+		}
+
+		if point.HeartRate != nil && *point.HeartRate >= minHeartRate && *point.HeartRate <= maxHeartRate {
+			heartRateSum += *point.HeartRate
+			heartRateCount++
+			heartRateMax = max(heartRateMax, *point.HeartRate)
+		}
+
+		if point.Distance != nil {
+			deviceDistance = max(deviceDistance, *point.Distance)
+		}
+
+		//the gap between two segments is not part of the distance or the elevation gain
+		if point.SegmentStart {
+			previous = nil
+		}
+
+		if !point.HasPosition() {
+			continue
+		}
+
+		if previous != nil {
+			summary.DistanceMeters += haversine(*previous.Latitude, *previous.Longitude, *point.Latitude, *point.Longitude)
+
+			if previous.Elevation != nil && point.Elevation != nil && *point.Elevation > *previous.Elevation {
+				summary.ElevationGainMeters += *point.Elevation - *previous.Elevation
+			}
+		}
+
+		previous = point
+	}
+
+	if deviceDistance > 0 {
+		summary.DistanceMeters = deviceDistance
+	}
+
+	if first != nil {
+		summary.StartTime = first
+		summary.DurationSeconds = int(last.Sub(*first).Seconds())
+	}
+
+	if heartRateCount > 0 {
+		avg := int(math.Round(float64(heartRateSum) / float64(heartRateCount)))
+		summary.AvgHeartRate, summary.MaxHeartRate = &avg, &heartRateMax
+	}
+
+	return summary
+}
+
+const earthRadiusMeters = 6371008.8
+
+// great circle distance in meters between two coordinates
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(a))
+}
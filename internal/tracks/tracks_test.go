@@ -0,0 +1,192 @@
+package tracks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+	xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+	<trk>
+		<name>Morning Run</name>
+		<type>running</type>
+		<trkseg>
+			<trkpt lat="44.4268" lon="26.1025">
+				<ele>70.0</ele>
+				<time>2025-03-10T07:00:00Z</time>
+				<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>120</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions>
+			</trkpt>
+			<trkpt lat="44.4278" lon="26.1025">
+				<ele>75.5</ele>
+				<time>2025-03-10T07:00:30Z</time>
+				<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>140</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions>
+			</trkpt>
+		</trkseg>
+		<trkseg>
+			<trkpt lat="44.4298" lon="26.1025">
+				<ele>80.0</ele>
+				<time>2025-03-10T07:01:00Z</time>
+				<extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>0</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions>
+			</trkpt>
+			<trkpt lat="44.4308" lon="26.1025">
+				<ele>81.0</ele>
+				<time>2025-03-10T07:01:30Z</time>
+			</trkpt>
+		</trkseg>
+	</trk>
+</gpx>`
+
+const sampleTCX = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+	<Activities>
+		<Activity Sport="Biking">
+			<Id>2025-03-10T18:00:00Z</Id>
+			<Lap StartTime="2025-03-10T18:00:00Z">
+				<Track>
+					<Trackpoint>
+						<Time>2025-03-10T18:00:00Z</Time>
+						<Position><LatitudeDegrees>44.4268</LatitudeDegrees><LongitudeDegrees>26.1025</LongitudeDegrees></Position>
+						<AltitudeMeters>70</AltitudeMeters>
+						<DistanceMeters>0</DistanceMeters>
+						<HeartRateBpm><Value>110</Value></HeartRateBpm>
+					</Trackpoint>
+					<Trackpoint>
+						<Time>2025-03-10T18:10:00Z</Time>
+						<DistanceMeters>4000</DistanceMeters>
+						<HeartRateBpm><Value>150</Value></HeartRateBpm>
+					</Trackpoint>
+				</Track>
+			</Lap>
+		</Activity>
+	</Activities>
+</TrainingCenterDatabase>`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		wantErr     error
+		wantName    string
+		wantSport   string
+		wantPoints  int
+		wantSummary Summary
+	}{
+		{
+			name:       "gpx segments are joined without the gap between them",
+			file:       sampleGPX,
+			wantName:   "Morning Run",
+			wantSport:  SportRunning,
+			wantPoints: 4,
+			wantSummary: Summary{
+				DistanceMeters:      222.4,
+				DurationSeconds:     90,
+				ElevationGainMeters: 6.5,
+				AvgHeartRate:        intPtr(130),
+				MaxHeartRate:        intPtr(140),
+			},
+		},
+		{
+			name:       "tcx uses the device distance",
+			file:       sampleTCX,
+			wantSport:  SportCycling,
+			wantPoints: 2,
+			wantSummary: Summary{
+				DistanceMeters:  4000,
+				DurationSeconds: 600,
+				AvgHeartRate:    intPtr(130),
+				MaxHeartRate:    intPtr(150),
+			},
+		},
+		{
+			name:    "unknown root element",
+			file:    `<kml></kml>`,
+			wantErr: ErrUnknownFormat,
+		},
+		{
+			name:    "not xml",
+			file:    `{"type": "Feature"}`,
+			wantErr: ErrUnknownFormat,
+		},
+		{
+			name:    "gpx without points",
+			file:    `<gpx><trk><name>empty</name></trk></gpx>`,
+			wantErr: ErrNoPoints,
+		},
+		{
+			name:    "gpx point without a longitude",
+			file:    `<gpx><trk><trkseg><trkpt lat="44.43"></trkpt></trkseg></trk></gpx>`,
+			wantErr: ErrInvalidPoint,
+		},
+		{
+			name:    "gpx latitude out of range",
+			file:    `<gpx><trk><trkseg><trkpt lat="94.43" lon="26.10"></trkpt></trkseg></trk></gpx>`,
+			wantErr: ErrInvalidPoint,
+		},
+		{
+			name: "tcx position without a latitude",
+			file: `<TrainingCenterDatabase><Activities><Activity Sport="Running"><Lap><Track><Trackpoint>
+				<Position><LongitudeDegrees>26.10</LongitudeDegrees></Position>
+			</Trackpoint></Track></Lap></Activity></Activities></TrainingCenterDatabase>`,
+			wantErr: ErrInvalidPoint,
+		},
+		{
+			name: "tcx longitude out of range",
+			file: `<TrainingCenterDatabase><Activities><Activity Sport="Running"><Lap><Track><Trackpoint>
+				<Position><LatitudeDegrees>44.43</LatitudeDegrees><LongitudeDegrees>-186.10</LongitudeDegrees></Position>
+			</Trackpoint></Track></Lap></Activity></Activities></TrainingCenterDatabase>`,
+			wantErr: ErrInvalidPoint,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track, err := Parse(strings.NewReader(tt.file))
+
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantName, track.Name)
+			assert.Equal(t, tt.wantSport, track.Sport)
+			assert.Len(t, track.Points, tt.wantPoints)
+
+			summary := track.Summary()
+			require.NotNil(t, summary.StartTime)
+			assert.InDelta(t, tt.wantSummary.DistanceMeters, summary.DistanceMeters, 0.1)
+			assert.Equal(t, tt.wantSummary.DurationSeconds, summary.DurationSeconds)
+			assert.InDelta(t, tt.wantSummary.ElevationGainMeters, summary.ElevationGainMeters, 0.001)
+			assert.Equal(t, tt.wantSummary.AvgHeartRate, summary.AvgHeartRate)
+			assert.Equal(t, tt.wantSummary.MaxHeartRate, summary.MaxHeartRate)
+		})
+	}
+}
+
+func TestSummaryWithoutTimes(t *testing.T) {
+	lat, lon := 44.0, 26.0
+	track := &Track{Points: []Point{{Latitude: &lat, Longitude: &lon}}}
+
+	summary := track.Summary()
+
+	assert.Nil(t, summary.StartTime)
+	assert.Zero(t, summary.DurationSeconds)
+	assert.Nil(t, summary.AvgHeartRate)
+}
+
+func TestPointTimes(t *testing.T) {
+	track, err := Parse(strings.NewReader(sampleGPX))
+	require.NoError(t, err)
+
+	assert.True(t, track.Points[0].Time.Equal(time.Date(2025, 3, 10, 7, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 120, *track.Points[0].HeartRate)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
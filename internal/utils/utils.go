@@ -3,8 +3,10 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -43,3 +45,41 @@ func ReadIDParam(r *http.Request) (int64, error) {
 
 	return id, nil
 }
+
+// read an optional integer query parameter, returns nil when the parameter is missing
+func ReadIntQuery(r *http.Request, key string) (*int, error) {
+	value := r.URL.Query().Get(key)
+
+	if value == "" {
+		return nil, nil
+	}
+
+	i, err := strconv.Atoi(value)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter", key)
+	}
+
+	return &i, nil
+}
+
+// read an optional time query parameter given either as a date (2006-01-02) or as RFC3339, returns nil when the parameter is missing
+func ReadTimeQuery(r *http.Request, key string) (*time.Time, error) {
+	value := r.URL.Query().Get(key)
+
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return &t, nil
+	}
+
+	t, err = time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter", key)
+	}
+
+	return &t, nil
+}
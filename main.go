@@ -1,46 +1,106 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/zidariu-sabin/femProject/internal/app"
+	"github.com/zidariu-sabin/femProject/internal/config"
 	"github.com/zidariu-sabin/femProject/internal/routes"
 )
 
+// exit codes reported when the application cannot start or stops with an error
+const (
+	exitRuntimeError = 1
+	exitConfigError  = 2
+)
+
 // entry point of the application
 func main() {
-	var port int
-	flag.IntVar(&port, "port", 8080, "go backend port")
-	flag.Parse()
-	//-port *value* will set the port we will run from to value
-	app, err := app.NewApplication()
+	//-port *value* will set the port we will run from to value, run with -h to list every option
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration: %v\n", err)
+		os.Exit(exitConfigError)
+	}
+
+	err = run(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitRuntimeError)
+	}
+}
+
+// runs the server until it fails or a SIGINT/SIGTERM is received, in which case in-flight requests are drained
+// before the background jobs and the database connection are closed
+func run(cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	app, err := app.NewApplication(cfg)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("starting application: %w", err)
 	}
 
-	//at the end of execution close the datbase connection
-	defer app.DB.Close()
+	//at the end of execution stop the jobs and close the datbase connection
+	defer app.Close()
 
 	router := routes.SetupRoutes(app)
 
 	server := &http.Server{
 		//print function that returns a value
-		Addr:         fmt.Sprintf(":%d", port),
+		Addr:         fmt.Sprintf(":%d", cfg.Port),
 		Handler:      router,
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  cfg.IdleTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+	}
+
+	serverErrors := make(chan error, 1)
+	go func() {
+		serverErrors <- server.ListenAndServe()
+	}()
+
+	app.Logger.Info("app is successfully running", "port", cfg.Port)
+
+	select {
+	case err := <-serverErrors:
+		return fmt.Errorf("server on port %d: %w", cfg.Port, err)
+	case <-ctx.Done():
 	}
 
-	app.Logger.Printf("App is successfully running on port %d \n", port)
+	//a second signal while draining kills the process immediately
+	stop()
+	app.BeginShutdown()
+
+	//the readiness probe fails from now on, new requests are still served until the load balancer notices
+	app.Logger.Info("shutting down, waiting for the instance to be taken out of rotation", "delay", cfg.ShutdownDrainDelay)
+	time.Sleep(cfg.ShutdownDrainDelay)
+
+	app.Logger.Info("shutting down, draining requests", "timeout", cfg.ShutdownTimeout)
 
-	err = server.ListenAndServe()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
+	err = server.Shutdown(shutdownCtx)
 	if err != nil {
-		server.ErrorLog.Fatal()
-		app.Logger.Fatal()
+		return fmt.Errorf("draining requests: %w", err)
 	}
+
+	app.Logger.Info("server stopped")
+
+	return nil
 }
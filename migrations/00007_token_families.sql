@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tokens
+ADD COLUMN family_id TEXT,
+ADD COLUMN used_at TIMESTAMP(0) WITH TIME ZONE;
+
+UPDATE tokens SET family_id = encode(hash, 'hex') WHERE family_id IS NULL;
+
+ALTER TABLE tokens ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS tokens_family_id_idx ON tokens (family_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS tokens_family_id_idx;
+ALTER TABLE tokens DROP COLUMN used_at, DROP COLUMN family_id;
+-- +goose StatementEnd
@@ -0,0 +1,93 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS exercises (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    aliases TEXT[] NOT NULL DEFAULT '{}',
+    muscle_groups TEXT[] NOT NULL DEFAULT '{}',
+    equipment VARCHAR(50) NOT NULL DEFAULT '',
+    movement_type VARCHAR(50) NOT NULL DEFAULT '',
+    -- null for the exercises of the default catalog
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS exercises_name_idx ON exercises (lower(name));
+CREATE INDEX IF NOT EXISTS exercises_name_trgm_idx ON exercises USING GIN (name gin_trgm_ops);
+
+INSERT INTO exercises (name, aliases, muscle_groups, equipment, movement_type) VALUES
+    ('Bench Press', '{BP,Barbell Bench Press,Flat Bench}', '{chest,triceps,shoulders}', 'barbell', 'compound'),
+    ('Incline Bench Press', '{Incline Press,Incline BP}', '{chest,shoulders,triceps}', 'barbell', 'compound'),
+    ('Dumbbell Bench Press', '{DB Bench,DB Bench Press}', '{chest,triceps,shoulders}', 'dumbbell', 'compound'),
+    ('Push Up', '{Push-up,Pushup,Push Ups}', '{chest,triceps,shoulders}', 'bodyweight', 'compound'),
+    ('Dip', '{Dips,Parallel Bar Dip}', '{chest,triceps}', 'bodyweight', 'compound'),
+    ('Squat', '{Back Squat,Barbell Squat}', '{quads,glutes,hamstrings}', 'barbell', 'compound'),
+    ('Front Squat', '{}', '{quads,glutes,core}', 'barbell', 'compound'),
+    ('Leg Press', '{}', '{quads,glutes}', 'machine', 'compound'),
+    ('Lunge', '{Lunges,Walking Lunge}', '{quads,glutes}', 'dumbbell', 'compound'),
+    ('Deadlift', '{DL,Conventional Deadlift}', '{hamstrings,glutes,back}', 'barbell', 'compound'),
+    ('Romanian Deadlift', '{RDL}', '{hamstrings,glutes}', 'barbell', 'compound'),
+    ('Hip Thrust', '{Barbell Hip Thrust}', '{glutes,hamstrings}', 'barbell', 'compound'),
+    ('Leg Curl', '{Hamstring Curl}', '{hamstrings}', 'machine', 'isolation'),
+    ('Leg Extension', '{}', '{quads}', 'machine', 'isolation'),
+    ('Calf Raise', '{Calf Raises,Standing Calf Raise}', '{calves}', 'machine', 'isolation'),
+    ('Overhead Press', '{OHP,Military Press,Shoulder Press}', '{shoulders,triceps}', 'barbell', 'compound'),
+    ('Lateral Raise', '{Side Raise,Lateral Raises}', '{shoulders}', 'dumbbell', 'isolation'),
+    ('Pull Up', '{Pull-up,Pullup,Pull Ups}', '{back,biceps}', 'bodyweight', 'compound'),
+    ('Chin Up', '{Chin-up,Chinup}', '{back,biceps}', 'bodyweight', 'compound'),
+    ('Barbell Row', '{Bent Over Row,BB Row}', '{back,biceps}', 'barbell', 'compound'),
+    ('Lat Pulldown', '{Pulldown}', '{back,biceps}', 'cable', 'compound'),
+    ('Seated Cable Row', '{Cable Row}', '{back,biceps}', 'cable', 'compound'),
+    ('Bicep Curl', '{Biceps Curl,Curl,Barbell Curl}', '{biceps}', 'barbell', 'isolation'),
+    ('Hammer Curl', '{}', '{biceps,forearms}', 'dumbbell', 'isolation'),
+    ('Tricep Pushdown', '{Triceps Pushdown,Pushdown}', '{triceps}', 'cable', 'isolation'),
+    ('Skull Crusher', '{Lying Tricep Extension}', '{triceps}', 'barbell', 'isolation'),
+    ('Plank', '{}', '{core}', 'bodyweight', 'isolation'),
+    ('Crunch', '{Crunches}', '{core}', 'bodyweight', 'isolation'),
+    ('Kettlebell Swing', '{KB Swing}', '{glutes,hamstrings,core}', 'kettlebell', 'compound'),
+    ('Running', '{Run,Jog,Jogging}', '{cardio}', 'none', 'cardio'),
+    ('Cycling', '{Bike,Biking}', '{cardio}', 'none', 'cardio'),
+    ('Rowing', '{Row Machine,Erg}', '{cardio,back}', 'machine', 'cardio'),
+    ('Jump Rope', '{Skipping}', '{cardio,calves}', 'none', 'cardio')
+ON CONFLICT DO NOTHING;
+
+-- every free text name already used gets an exercise unless it matches a name or an alias of the catalog
+INSERT INTO exercises (name)
+SELECT DISTINCT ON (lower(trim(we.exercise_name))) trim(we.exercise_name)
+FROM workout_entries we
+WHERE NOT EXISTS (
+    SELECT 1 FROM exercises e
+    WHERE lower(e.name) = lower(trim(we.exercise_name))
+        OR lower(trim(we.exercise_name)) = ANY(SELECT lower(a) FROM unnest(e.aliases) a)
+)
+ORDER BY lower(trim(we.exercise_name)), we.id
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE workout_entries ADD COLUMN exercise_id BIGINT REFERENCES exercises(id) ON DELETE RESTRICT;
+
+UPDATE workout_entries we
+SET exercise_id = (
+    SELECT e.id FROM exercises e
+    WHERE lower(e.name) = lower(trim(we.exercise_name))
+        OR lower(trim(we.exercise_name)) = ANY(SELECT lower(a) FROM unnest(e.aliases) a)
+    ORDER BY lower(e.name) = lower(trim(we.exercise_name)) DESC, e.id
+    LIMIT 1
+);
+
+ALTER TABLE workout_entries ALTER COLUMN exercise_id SET NOT NULL;
+ALTER TABLE workout_entries DROP COLUMN exercise_name;
+
+CREATE INDEX IF NOT EXISTS workout_entries_exercise_id_idx ON workout_entries (exercise_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries ADD COLUMN exercise_name VARCHAR(255);
+
+UPDATE workout_entries we SET exercise_name = e.name FROM exercises e WHERE e.id = we.exercise_id;
+
+ALTER TABLE workout_entries ALTER COLUMN exercise_name SET NOT NULL;
+ALTER TABLE workout_entries DROP COLUMN exercise_id;
+
+DROP TABLE exercises;
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS personal_records (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    exercise_id BIGINT NOT NULL REFERENCES exercises(id) ON DELETE CASCADE,
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    record_type VARCHAR(20) NOT NULL,
+    value DECIMAL(10,2) NOT NULL,
+    -- weight and reps of the set that set the record, max_reps records are kept per weight
+    weight DECIMAL(5,2),
+    reps INTEGER,
+    achieved_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS personal_records_user_exercise_idx ON personal_records (user_id, exercise_id, achieved_at);
+
+-- the history of the existing workouts is replayed with the same rules the workout store applies
+WITH candidates AS (
+    SELECT w.user_id, we.exercise_id, w.id AS workout_id, w.created_at, we.order_index,
+        'max_weight' AS record_type, NULL::DECIMAL AS key_weight, we.weight, we.reps, we.weight AS value
+    FROM workout_entries we INNER JOIN workouts w ON w.id = we.workout_id
+    WHERE we.weight > 0 AND we.reps >= 1
+    UNION ALL
+    SELECT w.user_id, we.exercise_id, w.id, w.created_at, we.order_index,
+        'max_reps', COALESCE(we.weight, 0), COALESCE(we.weight, 0), we.reps, we.reps
+    FROM workout_entries we INNER JOIN workouts w ON w.id = we.workout_id
+    WHERE we.reps >= 1
+    UNION ALL
+    SELECT w.user_id, we.exercise_id, w.id, w.created_at, we.order_index,
+        'estimated_1rm', NULL, we.weight, we.reps, CASE WHEN we.reps = 1 THEN we.weight ELSE ROUND(we.weight * (1 + we.reps / 30.0), 2) END
+    FROM workout_entries we INNER JOIN workouts w ON w.id = we.workout_id
+    WHERE we.weight > 0 AND we.reps BETWEEN 1 AND 12
+    UNION ALL
+    SELECT w.user_id, we.exercise_id, w.id, w.created_at, we.order_index,
+        'max_duration', NULL, NULL, NULL, we.duration_seconds
+    FROM workout_entries we INNER JOIN workouts w ON w.id = we.workout_id
+    WHERE we.duration_seconds > 0
+),
+best_per_workout AS (
+    SELECT DISTINCT ON (user_id, exercise_id, record_type, key_weight, workout_id) *
+    FROM candidates
+    ORDER BY user_id, exercise_id, record_type, key_weight, workout_id, value DESC, reps DESC NULLS LAST, order_index
+),
+timeline AS (
+    SELECT *, MAX(value) OVER (
+        PARTITION BY user_id, exercise_id, record_type, key_weight
+        ORDER BY created_at, workout_id
+        ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+    ) AS previous_best
+    FROM best_per_workout
+)
+INSERT INTO personal_records (user_id, exercise_id, workout_id, record_type, value, weight, reps, achieved_at)
+SELECT user_id, exercise_id, workout_id, record_type, value, weight, reps, COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM timeline
+WHERE previous_best IS NULL OR value > previous_best;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE personal_records;
+-- +goose StatementEnd
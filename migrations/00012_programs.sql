@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS programs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    weeks INTEGER NOT NULL CHECK (weeks > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS programs_user_id_idx ON programs (user_id);
+
+-- day is the day of the week counted from the start date of an enrollment, days without a row are rest days
+CREATE TABLE IF NOT EXISTS program_days (
+    id BIGSERIAL PRIMARY KEY,
+    program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+    week INTEGER NOT NULL CHECK (week > 0),
+    day INTEGER NOT NULL CHECK (day BETWEEN 1 AND 7),
+    template_id BIGINT NOT NULL REFERENCES workout_templates(id) ON DELETE RESTRICT,
+    UNIQUE (program_id, week, day)
+);
+
+CREATE TABLE IF NOT EXISTS program_enrollments (
+    id BIGSERIAL PRIMARY KEY,
+    program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS program_enrollments_user_id_idx ON program_enrollments (user_id);
+
+-- workouts completing a planned day of an enrollment
+ALTER TABLE workouts
+ADD COLUMN enrollment_id BIGINT REFERENCES program_enrollments(id) ON DELETE SET NULL,
+ADD COLUMN program_day_id BIGINT REFERENCES program_days(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS workouts_enrollment_id_idx ON workouts (enrollment_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN program_day_id, DROP COLUMN enrollment_id;
+DROP TABLE program_enrollments;
+DROP TABLE program_days;
+DROP TABLE programs;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workouts
+ADD COLUMN performed_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'completed' CHECK (status IN ('planned', 'completed', 'skipped'));
+
+UPDATE workouts SET performed_at = COALESCE(created_at, CURRENT_TIMESTAMP);
+
+ALTER TABLE workouts
+ALTER COLUMN performed_at SET NOT NULL,
+ALTER COLUMN performed_at SET DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS workouts_user_performed_at_idx ON workouts (user_id, performed_at);
+
+-- recurring planned sessions, rrule is an iCalendar recurrence rule expanded from starts_at
+CREATE TABLE IF NOT EXISTS workout_plans (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    template_id BIGINT REFERENCES workout_templates(id) ON DELETE SET NULL,
+    title VARCHAR(255) NOT NULL,
+    description TEXT,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    duration_minutes INTEGER NOT NULL CHECK (duration_minutes > 0),
+    rrule TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS workout_plans_user_id_idx ON workout_plans (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_plans;
+DROP INDEX IF EXISTS workouts_user_performed_at_idx;
+ALTER TABLE workouts DROP COLUMN status, DROP COLUMN performed_at;
+-- +goose StatementEnd
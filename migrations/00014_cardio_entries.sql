@@ -0,0 +1,93 @@
+-- +goose Up
+-- +goose StatementBegin
+-- strength entries have reps, timed entries a duration and cardio entries a distance, a duration or both,
+-- the distance is kept in the unit it was entered in
+ALTER TABLE workout_entries
+ADD COLUMN entry_type VARCHAR(16),
+ADD COLUMN distance NUMERIC(10,3),
+ADD COLUMN distance_unit VARCHAR(2),
+ADD COLUMN avg_heart_rate INTEGER,
+ADD COLUMN max_heart_rate INTEGER,
+ADD COLUMN elevation_gain_meters NUMERIC(7,1);
+
+UPDATE workout_entries SET entry_type = CASE WHEN reps IS NOT NULL THEN 'strength' ELSE 'timed' END;
+
+ALTER TABLE workout_entries
+ALTER COLUMN entry_type SET NOT NULL,
+DROP CONSTRAINT valid_workout_entry,
+ADD CONSTRAINT valid_workout_entry CHECK (
+    (entry_type = 'strength' AND reps IS NOT NULL AND duration_seconds IS NULL) OR
+    (entry_type = 'timed' AND duration_seconds IS NOT NULL AND reps IS NULL) OR
+    (entry_type = 'cardio' AND reps IS NULL AND weight IS NULL AND (distance IS NOT NULL OR duration_seconds IS NOT NULL))
+),
+ADD CONSTRAINT valid_cardio_metrics CHECK (
+    (entry_type = 'cardio' OR (distance IS NULL AND avg_heart_rate IS NULL AND max_heart_rate IS NULL AND elevation_gain_meters IS NULL)) AND
+    ((distance IS NULL AND distance_unit IS NULL) OR (distance > 0 AND distance_unit IN ('m', 'km', 'mi'))) AND
+    (avg_heart_rate IS NULL OR avg_heart_rate BETWEEN 30 AND 250) AND
+    (max_heart_rate IS NULL OR max_heart_rate BETWEEN 30 AND 250) AND
+    (avg_heart_rate IS NULL OR max_heart_rate IS NULL OR avg_heart_rate <= max_heart_rate) AND
+    (elevation_gain_meters IS NULL OR elevation_gain_meters >= 0)
+);
+
+ALTER TABLE template_entries
+ADD COLUMN entry_type VARCHAR(16),
+ADD COLUMN distance NUMERIC(10,3),
+ADD COLUMN distance_unit VARCHAR(2),
+ADD COLUMN avg_heart_rate INTEGER,
+ADD COLUMN max_heart_rate INTEGER,
+ADD COLUMN elevation_gain_meters NUMERIC(7,1);
+
+UPDATE template_entries SET entry_type = CASE WHEN reps IS NOT NULL THEN 'strength' ELSE 'timed' END;
+
+ALTER TABLE template_entries
+ALTER COLUMN entry_type SET NOT NULL,
+DROP CONSTRAINT valid_template_entry,
+ADD CONSTRAINT valid_template_entry CHECK (
+    (entry_type = 'strength' AND reps IS NOT NULL AND duration_seconds IS NULL) OR
+    (entry_type = 'timed' AND duration_seconds IS NOT NULL AND reps IS NULL) OR
+    (entry_type = 'cardio' AND reps IS NULL AND weight IS NULL AND (distance IS NOT NULL OR duration_seconds IS NOT NULL))
+),
+ADD CONSTRAINT valid_template_cardio_metrics CHECK (
+    (entry_type = 'cardio' OR (distance IS NULL AND avg_heart_rate IS NULL AND max_heart_rate IS NULL AND elevation_gain_meters IS NULL)) AND
+    ((distance IS NULL AND distance_unit IS NULL) OR (distance > 0 AND distance_unit IN ('m', 'km', 'mi'))) AND
+    (avg_heart_rate IS NULL OR avg_heart_rate BETWEEN 30 AND 250) AND
+    (max_heart_rate IS NULL OR max_heart_rate BETWEEN 30 AND 250) AND
+    (avg_heart_rate IS NULL OR max_heart_rate IS NULL OR avg_heart_rate <= max_heart_rate) AND
+    (elevation_gain_meters IS NULL OR elevation_gain_meters >= 0)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- cardio entries that only have a distance cannot be represented without the new columns
+DELETE FROM workout_entries WHERE entry_type = 'cardio' AND duration_seconds IS NULL;
+DELETE FROM template_entries WHERE entry_type = 'cardio' AND duration_seconds IS NULL;
+
+ALTER TABLE workout_entries
+DROP CONSTRAINT valid_cardio_metrics,
+DROP CONSTRAINT valid_workout_entry,
+DROP COLUMN elevation_gain_meters,
+DROP COLUMN max_heart_rate,
+DROP COLUMN avg_heart_rate,
+DROP COLUMN distance_unit,
+DROP COLUMN distance,
+DROP COLUMN entry_type,
+ADD CONSTRAINT valid_workout_entry CHECK(
+    (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+    (reps IS NULL OR duration_seconds IS NULL)
+);
+
+ALTER TABLE template_entries
+DROP CONSTRAINT valid_template_cardio_metrics,
+DROP CONSTRAINT valid_template_entry,
+DROP COLUMN elevation_gain_meters,
+DROP COLUMN max_heart_rate,
+DROP COLUMN avg_heart_rate,
+DROP COLUMN distance_unit,
+DROP COLUMN distance,
+DROP COLUMN entry_type,
+ADD CONSTRAINT valid_template_entry CHECK(
+    (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+    (reps IS NULL OR duration_seconds IS NULL)
+);
+-- +goose StatementEnd
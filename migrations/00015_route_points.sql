@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- raw points of an imported gps track, seq keeps the order they were recorded in
+CREATE TABLE IF NOT EXISTS route_points (
+    workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    seq INTEGER NOT NULL,
+    latitude DOUBLE PRECISION NOT NULL CHECK (latitude BETWEEN -90 AND 90),
+    longitude DOUBLE PRECISION NOT NULL CHECK (longitude BETWEEN -180 AND 180),
+    elevation_meters DOUBLE PRECISION,
+    recorded_at TIMESTAMP WITH TIME ZONE,
+    heart_rate INTEGER,
+    PRIMARY KEY (workout_id, seq)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE route_points;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- when set the account is deleted by the account reaper once the time has passed, until then the deletion can be cancelled
+ALTER TABLE users ADD COLUMN deletion_scheduled_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS users_deletion_scheduled_at_idx ON users (deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS users_deletion_scheduled_at_idx;
+ALTER TABLE users DROP COLUMN deletion_scheduled_at;
+-- +goose StatementEnd
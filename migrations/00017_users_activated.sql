@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- new accounts are activated once the email is verified, the accounts created before verification existed are trusted
+ALTER TABLE users ADD COLUMN activated BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE users SET activated = TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN activated;
+-- +goose StatementEnd
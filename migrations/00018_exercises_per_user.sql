@@ -0,0 +1,67 @@
+-- +goose Up
+-- +goose StatementBegin
+-- custom exercises belong to the user who created them, only the names of the catalog are shared by everyone
+DROP INDEX IF EXISTS exercises_name_idx;
+
+CREATE UNIQUE INDEX IF NOT EXISTS exercises_catalog_name_idx ON exercises (lower(name)) WHERE created_by IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS exercises_user_name_idx ON exercises (created_by, lower(name));
+
+-- custom exercises another user already logged with are copied to that user so the creator can no longer change their history,
+-- the names 00009 backfilled from the free text of the entries have no creator and no muscle groups, every user of one
+-- gets a copy and the original leaves the catalog
+CREATE TEMPORARY TABLE shared_exercises ON COMMIT DROP AS
+SELECT DISTINCT e.id AS exercise_id, used.user_id
+FROM exercises e
+INNER JOIN (
+    SELECT we.exercise_id, w.user_id FROM workout_entries we INNER JOIN workouts w ON w.id = we.workout_id
+    UNION
+    SELECT te.exercise_id, t.user_id FROM template_entries te INNER JOIN workout_templates t ON t.id = te.template_id
+    UNION
+    SELECT exercise_id, user_id FROM personal_records
+) used ON used.exercise_id = e.id
+WHERE (e.created_by IS NOT NULL AND e.created_by <> used.user_id)
+    OR (e.created_by IS NULL AND e.muscle_groups = '{}');
+
+INSERT INTO exercises (name, aliases, muscle_groups, equipment, movement_type, created_by)
+SELECT e.name, e.aliases, e.muscle_groups, e.equipment, e.movement_type, s.user_id
+FROM shared_exercises s
+INNER JOIN exercises e ON e.id = s.exercise_id;
+
+UPDATE workout_entries we
+SET exercise_id = copy.id
+FROM workouts w, shared_exercises s, exercises e, exercises copy
+WHERE w.id = we.workout_id AND s.exercise_id = we.exercise_id AND s.user_id = w.user_id
+    AND e.id = s.exercise_id AND copy.created_by = s.user_id AND lower(copy.name) = lower(e.name);
+
+UPDATE template_entries te
+SET exercise_id = copy.id
+FROM workout_templates t, shared_exercises s, exercises e, exercises copy
+WHERE t.id = te.template_id AND s.exercise_id = te.exercise_id AND s.user_id = t.user_id
+    AND e.id = s.exercise_id AND copy.created_by = s.user_id AND lower(copy.name) = lower(e.name);
+
+UPDATE personal_records pr
+SET exercise_id = copy.id
+FROM shared_exercises s, exercises e, exercises copy
+WHERE s.exercise_id = pr.exercise_id AND s.user_id = pr.user_id
+    AND e.id = s.exercise_id AND copy.created_by = s.user_id AND lower(copy.name) = lower(e.name);
+
+DELETE FROM exercises WHERE created_by IS NULL AND muscle_groups = '{}';
+
+-- an exercise without a creator is a catalog exercise, so the exercises of a deleted user go with the user
+ALTER TABLE exercises
+DROP CONSTRAINT exercises_created_by_fkey,
+ADD CONSTRAINT exercises_created_by_fkey FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the copies are not merged back, the global index can only be restored while no two users share the name of an exercise
+ALTER TABLE exercises
+DROP CONSTRAINT exercises_created_by_fkey,
+ADD CONSTRAINT exercises_created_by_fkey FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL;
+
+DROP INDEX IF EXISTS exercises_user_name_idx;
+DROP INDEX IF EXISTS exercises_catalog_name_idx;
+
+CREATE UNIQUE INDEX IF NOT EXISTS exercises_name_idx ON exercises (lower(name));
+-- +goose StatementEnd